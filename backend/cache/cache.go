@@ -0,0 +1,265 @@
+// Package cache caches the backend's hot reads in Redis or in memory
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"nftgenie/backend/config"
+	"nftgenie/backend/repository"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrMiss is returned by Backend.Get for a missing or expired key
+var ErrMiss = errors.New("cache miss")
+
+// Backend stores values under keys until their TTL passes
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX sets the key only if it is missing and reports whether it did
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+	// DeleteIfEqual drops the key only while it holds value, and reports
+	// whether it did
+	DeleteIfEqual(ctx context.Context, key string, value []byte) (bool, error)
+}
+
+// TTLs are how long each hot read is cached
+type TTLs struct {
+	NFT             time.Duration
+	Trending        time.Duration
+	Stats           time.Duration
+	Recommendations time.Duration
+}
+
+// TTLsFromEnv reads the CACHE_*_TTL environment
+func TTLsFromEnv() TTLs {
+	return TTLs{
+		NFT:             config.Duration("CACHE_NFT_TTL", time.Minute),
+		Trending:        config.Duration("CACHE_TRENDING_TTL", time.Minute),
+		Stats:           config.Duration("CACHE_STATS_TTL", 30*time.Second),
+		Recommendations: config.Duration("CACHE_RECOMMENDATIONS_TTL", time.Minute),
+	}
+}
+
+// Cache stores JSON encoded reads in a backend. A nil Cache caches nothing,
+// so callers don't need to check whether caching is configured.
+type Cache struct {
+	// LockTTL bounds how long one request may take to recompute a key
+	// while others wait for it
+	LockTTL time.Duration
+
+	backend Backend
+	flights flightGroup
+}
+
+// New creates a cache over backend
+func New(backend Backend) *Cache {
+	return &Cache{
+		LockTTL: 5 * time.Second,
+		backend: backend,
+	}
+}
+
+// FromEnv creates the cache CACHE_DRIVER selects: memory (the default),
+// redis at REDIS_HOST, or none
+func FromEnv() (*Cache, error) {
+	var c *Cache
+	switch driver := config.String("CACHE_DRIVER", "memory"); driver {
+	case "memory":
+		c = New(NewMemory(config.Int("CACHE_MEMORY_MAX_ENTRIES", 10000)))
+	case "redis":
+		c = New(NewRedis(RedisConfigFromEnv()))
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown CACHE_DRIVER %q", driver)
+	}
+	c.LockTTL = config.Duration("CACHE_LOCK_TTL", c.LockTTL)
+	return c, nil
+}
+
+// Cache keys of the hot reads
+const StatsKey = "stats"
+
+// NFTKey is the key of an NFT read by ID
+func NFTKey(id uuid.UUID) string {
+	return "nft:" + id.String()
+}
+
+// TrendingKey is the key of a trending window's ranks
+func TrendingKey(period string) string {
+	return "trending:" + period
+}
+
+// RecommendationsKey is the key of a user's recommendations
+func RecommendationsKey(userID uuid.UUID) string {
+	return "recommendations:" + userID.String()
+}
+
+// Fetch returns the value cached under key, or loads, caches and returns it.
+// Of the requests that miss at the same time only one loads: requests in
+// this process share its result, and other processes wait for it to appear
+// for up to LockTTL. A value load returns with an error is passed on but not
+// cached. Backend errors are logged and the value is loaded uncached, so an
+// unreachable cache never fails a read.
+func Fetch[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	if c == nil {
+		return load()
+	}
+	if value, ok := lookup[T](ctx, c, key); ok {
+		return value, nil
+	}
+
+	value, err := c.flights.do(key, func() (interface{}, error) {
+		return fill(ctx, c, key, ttl, load)
+	})
+	v, _ := value.(T)
+	return v, err
+}
+
+// fill loads the key under its lock, or waits for the process holding the
+// lock to cache it
+func fill[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	lockKey := "lock:" + key
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return load()
+	}
+	token = []byte(hex.EncodeToString(token))
+	locked, err := c.backend.SetNX(ctx, lockKey, token, c.LockTTL)
+	if err != nil {
+		log.Printf("cache: locking %s: %v", key, err)
+		return load()
+	}
+
+	if locked {
+		defer c.unlock(ctx, lockKey, token)
+		// The holder of the previous lock may have cached it just now
+		if value, ok := lookup[T](ctx, c, key); ok {
+			return value, nil
+		}
+	} else {
+		deadline := time.Now().Add(c.LockTTL)
+		for time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				var zero T
+				return zero, ctx.Err()
+			case <-time.After(25 * time.Millisecond):
+			}
+			if value, ok := lookup[T](ctx, c, key); ok {
+				return value, nil
+			}
+		}
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err != nil {
+		log.Printf("cache: encoding %s: %v", key, err)
+	} else if err := c.backend.Set(ctx, key, data, ttl); err != nil {
+		log.Printf("cache: setting %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// lookup decodes the cached value of key
+func lookup[T any](ctx context.Context, c *Cache, key string) (T, bool) {
+	var value T
+	data, err := c.backend.Get(ctx, key)
+	if err != nil {
+		if err != ErrMiss {
+			log.Printf("cache: getting %s: %v", key, err)
+		}
+		return value, false
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		log.Printf("cache: decoding %s: %v", key, err)
+		return value, false
+	}
+	return value, true
+}
+
+// Delete drops keys. Failures are logged, as the write that made them stale
+// already happened; the keys expire with their TTL.
+func (c *Cache) Delete(ctx context.Context, keys ...string) {
+	if c == nil || len(keys) == 0 {
+		return
+	}
+	if err := c.backend.Delete(ctx, keys...); err != nil {
+		log.Printf("cache: deleting %v: %v", keys, err)
+	}
+}
+
+// unlock releases a lock taken with token. A load that outlived LockTTL
+// finds the lock expired and perhaps taken by another process, whose lock it
+// leaves alone.
+func (c *Cache) unlock(ctx context.Context, lockKey string, token []byte) {
+	if _, err := c.backend.DeleteIfEqual(ctx, lockKey, token); err != nil {
+		log.Printf("cache: unlocking %s: %v", lockKey, err)
+	}
+}
+
+// InvalidateNFTs drops the reads changes to the NFTs show up in: the NFTs
+// themselves, the trending ranks embedding them and the marketplace stats
+func (c *Cache) InvalidateNFTs(ctx context.Context, ids ...uuid.UUID) {
+	keys := []string{StatsKey}
+	for _, window := range repository.TrendingWindows {
+		keys = append(keys, TrendingKey(window.Period))
+	}
+	for _, id := range ids {
+		keys = append(keys, NFTKey(id))
+	}
+	c.Delete(ctx, keys...)
+}
+
+// flightGroup runs one call per key at a time, sharing its result with the
+// callers that arrive while it runs
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is a call in progress
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// do runs fn unless a call for key is in progress, in which case it waits
+// for that call's result
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	if f, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.value, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.value, f.err = fn()
+	return f.value, f.err
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+
+	if err := m.Set(ctx, "k", []byte("v"), 20*time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if value, err := m.Get(ctx, "k"); err != nil || string(value) != "v" {
+		t.Fatalf("Get = %q, %v, want v", value, err)
+	}
+	if ok, _ := m.SetNX(ctx, "k", []byte("w"), time.Minute); ok {
+		t.Error("SetNX set a key that is set")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := m.Get(ctx, "k"); err != ErrMiss {
+		t.Errorf("Get of an expired key = %v, want ErrMiss", err)
+	}
+	if ok, _ := m.SetNX(ctx, "k", []byte("w"), time.Minute); !ok {
+		t.Error("SetNX did not set an expired key")
+	}
+}
+
+func TestMemoryEviction(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+
+	m.Set(ctx, "a", []byte("1"), time.Millisecond)
+	m.Set(ctx, "b", []byte("2"), time.Minute)
+	time.Sleep(5 * time.Millisecond)
+	m.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if len(m.entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(m.entries))
+	}
+	if _, err := m.Get(ctx, "b"); err != nil {
+		t.Errorf("live entry b was evicted before expired a")
+	}
+}
+
+func TestFetchCaches(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemory(10))
+
+	var loads int
+	load := func() ([]string, error) {
+		loads++
+		return []string{"a", "b"}, nil
+	}
+	for i := 0; i < 3; i++ {
+		value, err := Fetch(ctx, c, "k", time.Minute, load)
+		if err != nil || len(value) != 2 || value[1] != "b" {
+			t.Fatalf("Fetch = %v, %v", value, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+
+	c.Delete(ctx, "k")
+	Fetch(ctx, c, "k", time.Minute, load)
+	if loads != 2 {
+		t.Errorf("loaded %d times after Delete, want 2", loads)
+	}
+}
+
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemory(10))
+	failure := errors.New("not found")
+
+	if _, err := Fetch(ctx, c, "k", time.Minute, func() (int, error) { return 0, failure }); err != failure {
+		t.Fatalf("Fetch error = %v, want %v", err, failure)
+	}
+	if value, err := Fetch(ctx, c, "k", time.Minute, func() (int, error) { return 7, nil }); err != nil || value != 7 {
+		t.Errorf("Fetch after an error = %d, %v, want 7", value, err)
+	}
+}
+
+func TestFetchLoadsOnceUnderStampede(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemory(10)
+	// Two caches over one backend stand in for two backend instances
+	caches := []*Cache{New(backend), New(backend)}
+
+	var loads atomic.Int32
+	load := func() (int, error) {
+		loads.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(c *Cache) {
+			defer wg.Done()
+			if value, err := Fetch(ctx, c, "k", time.Minute, load); err != nil || value != 42 {
+				t.Errorf("Fetch = %d, %v, want 42", value, err)
+			}
+		}(caches[i%2])
+	}
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+	if _, err := backend.Get(ctx, "lock:k"); err != ErrMiss {
+		t.Errorf("lock left behind: %v", err)
+	}
+}
+
+func TestFetchReleasesOnlyItsOwnLock(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemory(10)
+	c := New(backend)
+	c.LockTTL = 20 * time.Millisecond
+
+	// The load outlives the lock, which another process then takes
+	value, err := Fetch(ctx, c, "k", time.Minute, func() (int, error) {
+		time.Sleep(30 * time.Millisecond)
+		if ok, _ := backend.SetNX(ctx, "lock:k", []byte("other"), time.Minute); !ok {
+			t.Error("lock not expired after LockTTL")
+		}
+		return 1, nil
+	})
+	if err != nil || value != 1 {
+		t.Fatalf("Fetch = %d, %v, want 1", value, err)
+	}
+	if lock, err := backend.Get(ctx, "lock:k"); err != nil || string(lock) != "other" {
+		t.Errorf("other process's lock = %q, %v, want it kept", lock, err)
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	value, err := Fetch(context.Background(), c, "k", time.Minute, func() (int, error) { return 1, nil })
+	if err != nil || value != 1 {
+		t.Errorf("Fetch = %d, %v, want 1", value, err)
+	}
+	c.InvalidateNFTs(context.Background(), uuid.New())
+}
+
+func TestInvalidateNFTs(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemory(100)
+	c := New(backend)
+	id, other := uuid.New(), uuid.New()
+
+	for _, key := range []string{NFTKey(id), NFTKey(other), StatsKey, TrendingKey("24h")} {
+		backend.Set(ctx, key, []byte("{}"), time.Minute)
+	}
+	c.InvalidateNFTs(ctx, id)
+
+	for _, key := range []string{NFTKey(id), StatsKey, TrendingKey("24h")} {
+		if _, err := backend.Get(ctx, key); err != ErrMiss {
+			t.Errorf("%s survived invalidation", key)
+		}
+	}
+	if _, err := backend.Get(ctx, NFTKey(other)); err != nil {
+		t.Errorf("unrelated %s was invalidated", NFTKey(other))
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Backend in the process's memory. Each backend instance caches
+// on its own, so writes on one instance don't invalidate another's entries;
+// use Redis when running more than one.
+type Memory struct {
+	MaxEntries int // entries kept before expired and then arbitrary ones are evicted
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// memoryEntry is a cached value and when it expires
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates an in-memory backend holding up to maxEntries keys
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		MaxEntries: maxEntries,
+		entries:    make(map[string]memoryEntry),
+	}
+}
+
+// Get returns the value of key, or ErrMiss
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	if !time.Now().Before(entry.expires) {
+		delete(m.entries, key)
+		return nil, ErrMiss
+	}
+	return entry.value, nil
+}
+
+// Set stores value under key for ttl
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, ttl)
+	return nil
+}
+
+// SetNX stores value under key for ttl unless the key is set
+func (m *Memory) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[key]; ok && time.Now().Before(entry.expires) {
+		return false, nil
+	}
+	m.set(key, value, ttl)
+	return true, nil
+}
+
+// Delete drops keys
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// DeleteIfEqual drops key only while it holds value
+func (m *Memory) DeleteIfEqual(ctx context.Context, key string, value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok || !time.Now().Before(entry.expires) || !bytes.Equal(entry.value, value) {
+		return false, nil
+	}
+	delete(m.entries, key)
+	return true, nil
+}
+
+// set stores an entry, evicting to stay within MaxEntries. Call it with mu
+// held.
+func (m *Memory) set(key string, value []byte, ttl time.Duration) {
+	if _, ok := m.entries[key]; !ok && m.MaxEntries > 0 && len(m.entries) >= m.MaxEntries {
+		m.evict()
+	}
+	m.entries[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+}
+
+// evict drops the expired entries, and arbitrary ones if that frees no room.
+// Call it with mu held.
+func (m *Memory) evict() {
+	now := time.Now()
+	for key, entry := range m.entries {
+		if !now.Before(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+	for key := range m.entries {
+		if len(m.entries) < m.MaxEntries {
+			return
+		}
+		delete(m.entries, key)
+	}
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"net"
+	"nftgenie/backend/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisConfig is where and how to reach Redis
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	Timeout  time.Duration // per dial, read and write
+	PoolSize int
+}
+
+// RedisConfigFromEnv reads the REDIS_* environment
+func RedisConfigFromEnv() RedisConfig {
+	return RedisConfig{
+		Addr:     net.JoinHostPort(config.String("REDIS_HOST", "localhost"), config.String("REDIS_PORT", "6379")),
+		Password: config.String("REDIS_PASSWORD", ""),
+		DB:       config.NonNegativeInt("REDIS_DB", 0),
+		Timeout:  config.Duration("REDIS_TIMEOUT", 500*time.Millisecond),
+		PoolSize: config.Int("REDIS_POOL_SIZE", 10),
+	}
+}
+
+// Redis is a Backend in Redis, shared by every backend instance
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis creates a Redis backend. Connections are dialled on first use.
+func NewRedis(config RedisConfig) *Redis {
+	return &Redis{
+		client: redis.NewClient(&redis.Options{
+			Addr:         config.Addr,
+			Password:     config.Password,
+			DB:           config.DB,
+			DialTimeout:  config.Timeout,
+			ReadTimeout:  config.Timeout,
+			WriteTimeout: config.Timeout,
+			PoolSize:     max(config.PoolSize, 1),
+			// A cache read that fails is loaded instead; don't retry it
+			MaxRetries: -1,
+		}),
+	}
+}
+
+// Client returns the underlying client, for commands the Backend methods
+// don't cover
+func (r *Redis) Client() *redis.Client {
+	return r.client
+}
+
+// Get returns the value of key, or ErrMiss
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// Set stores value under key for ttl
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, expiry(ttl)).Err()
+}
+
+// SetNX stores value under key for ttl unless the key is set
+func (r *Redis) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, expiry(ttl)).Result()
+}
+
+// Delete drops keys
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// deleteIfEqualScript deletes KEYS[1] if its value is ARGV[1]
+var deleteIfEqualScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+// DeleteIfEqual drops key only while it holds value
+func (r *Redis) DeleteIfEqual(ctx context.Context, key string, value []byte) (bool, error) {
+	deleted, err := deleteIfEqualScript.Run(ctx, r.client, []string{key}, value).Int()
+	return deleted == 1, err
+}
+
+// Close closes the connection pool
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// expiry keeps a TTL from meaning "never expire", which go-redis takes zero
+// to be
+func expiry(ttl time.Duration) time.Duration {
+	return max(ttl, time.Millisecond)
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	r := NewRedis(RedisConfig{Addr: server.Addr(), Password: "secret", DB: 2, Timeout: time.Second, PoolSize: 2})
+	defer r.Close()
+	ctx := context.Background()
+
+	if _, err := r.Get(ctx, "k"); err != ErrMiss {
+		t.Fatalf("Get of a missing key = %v, want ErrMiss", err)
+	}
+	if err := r.Set(ctx, "k", []byte("line\r\nbreak"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if value, err := r.Get(ctx, "k"); err != nil || string(value) != "line\r\nbreak" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+	if ok, err := r.SetNX(ctx, "k", []byte("v"), time.Minute); err != nil || ok {
+		t.Errorf("SetNX of a set key = %v, %v, want false", ok, err)
+	}
+	if ok, err := r.SetNX(ctx, "lock", []byte("1"), time.Minute); err != nil || !ok {
+		t.Errorf("SetNX of a missing key = %v, %v, want true", ok, err)
+	}
+
+	// Keys live in the configured database and expire with their TTL
+	server.Select(2)
+	if ttl := server.TTL("lock"); ttl != time.Minute {
+		t.Errorf("lock TTL = %v, want 1m", ttl)
+	}
+	server.FastForward(time.Minute)
+	if _, err := r.Get(ctx, "lock"); err != ErrMiss {
+		t.Errorf("Get of an expired key = %v, want ErrMiss", err)
+	}
+
+	// Only the holder's value deletes the lock
+	r.SetNX(ctx, "lock", []byte("mine"), time.Minute)
+	if ok, err := r.DeleteIfEqual(ctx, "lock", []byte("theirs")); err != nil || ok {
+		t.Errorf("DeleteIfEqual with another value = %v, %v, want false", ok, err)
+	}
+	if ok, err := r.DeleteIfEqual(ctx, "lock", []byte("mine")); err != nil || !ok {
+		t.Errorf("DeleteIfEqual with the value = %v, %v, want true", ok, err)
+	}
+	if ok, err := r.DeleteIfEqual(ctx, "lock", []byte("mine")); err != nil || ok {
+		t.Errorf("DeleteIfEqual of a missing key = %v, %v, want false", ok, err)
+	}
+
+	if err := r.Delete(ctx, "k", "lock"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := r.Get(ctx, "k"); err != ErrMiss {
+		t.Errorf("Get after Delete = %v, want ErrMiss", err)
+	}
+
+	// A zero TTL still expires rather than keeping the key forever
+	if err := r.Set(ctx, "brief", []byte("v"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ttl := server.TTL("brief"); ttl <= 0 {
+		t.Errorf("TTL of a zero-TTL key = %v, want it to expire", ttl)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	ctx := context.Background()
+
+	r := NewRedis(RedisConfig{Addr: server.Addr(), Password: "wrong", Timeout: time.Second})
+	defer r.Close()
+	if _, err := r.Get(ctx, "k"); err == nil || err == ErrMiss {
+		t.Errorf("Get with a wrong password = %v, want an error", err)
+	}
+
+	// A cache that cannot be reached degrades to loading every read
+	down := New(NewRedis(RedisConfig{Addr: "127.0.0.1:1", Timeout: 100 * time.Millisecond}))
+	if value, err := Fetch(ctx, down, "k", time.Minute, func() (int, error) { return 3, nil }); err != nil || value != 3 {
+		t.Errorf("Fetch with Redis down = %d, %v, want 3", value, err)
+	}
+}
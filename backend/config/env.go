@@ -0,0 +1,123 @@
+// Package config reads the backend's settings from the environment. Each
+// reader returns its fallback when the variable is unset, and logs and
+// returns its fallback when the value is malformed or out of range.
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String reads a string, trimmed of surrounding space
+func String(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// List reads a comma-separated list, dropping empty items
+func List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Bool reads a boolean such as "true", "false", "1" or "0"
+func Bool(key string, fallback bool) bool {
+	value, ok := lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return invalid(key, value, "a boolean", fallback)
+	}
+	return v
+}
+
+// Duration reads a positive duration such as "30s"
+func Duration(key string, fallback time.Duration) time.Duration {
+	value, ok := lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := time.ParseDuration(value)
+	if err != nil || v <= 0 {
+		return invalid(key, value, "a positive duration", fallback)
+	}
+	return v
+}
+
+// Int reads a positive integer
+func Int(key string, fallback int) int {
+	value, ok := lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v <= 0 {
+		return invalid(key, value, "a positive integer", fallback)
+	}
+	return v
+}
+
+// NonNegativeInt reads an integer that may be zero, for settings where zero
+// turns something off
+func NonNegativeInt(key string, fallback int) int {
+	value, ok := lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 0 {
+		return invalid(key, value, "a non-negative integer", fallback)
+	}
+	return v
+}
+
+// Float reads a positive number
+func Float(key string, fallback float64) float64 {
+	value, ok := lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v <= 0 {
+		return invalid(key, value, "a positive number", fallback)
+	}
+	return v
+}
+
+// NonNegativeFloat reads a number that may be zero, for settings where zero
+// turns something off
+func NonNegativeFloat(key string, fallback float64) float64 {
+	value, ok := lookup(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return invalid(key, value, "a non-negative number", fallback)
+	}
+	return v
+}
+
+// lookup returns the trimmed value of key and whether it is set to anything
+func lookup(key string) (string, bool) {
+	value := strings.TrimSpace(os.Getenv(key))
+	return value, value != ""
+}
+
+// invalid logs that key holds an unusable value and returns the fallback
+func invalid[T any](key, value, want string, fallback T) T {
+	log.Printf("config: %s=%q is not %s, using %v", key, value, want, fallback)
+	return fallback
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":     time.Minute,
+		"90s":  90 * time.Second,
+		"0s":   time.Minute,
+		"-1s":  time.Minute,
+		"soon": time.Minute,
+	}
+	for value, want := range tests {
+		t.Setenv("CONFIG_TEST", value)
+		if got := Duration("CONFIG_TEST", time.Minute); got != want {
+			t.Errorf("Duration(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		value                  string
+		integer, nonNegInteger int
+		float, nonNegFloat     float64
+	}{
+		{"", 7, 7, 7, 7},
+		{"3", 3, 3, 3, 3},
+		{"0", 7, 0, 7, 0},
+		{"-2", 7, 7, 7, 7},
+		{"1.5", 7, 7, 1.5, 1.5},
+		{"many", 7, 7, 7, 7},
+	}
+	for _, tt := range tests {
+		t.Setenv("CONFIG_TEST", tt.value)
+		if got := Int("CONFIG_TEST", 7); got != tt.integer {
+			t.Errorf("Int(%q) = %d, want %d", tt.value, got, tt.integer)
+		}
+		if got := NonNegativeInt("CONFIG_TEST", 7); got != tt.nonNegInteger {
+			t.Errorf("NonNegativeInt(%q) = %d, want %d", tt.value, got, tt.nonNegInteger)
+		}
+		if got := Float("CONFIG_TEST", 7); got != tt.float {
+			t.Errorf("Float(%q) = %v, want %v", tt.value, got, tt.float)
+		}
+		if got := NonNegativeFloat("CONFIG_TEST", 7); got != tt.nonNegFloat {
+			t.Errorf("NonNegativeFloat(%q) = %v, want %v", tt.value, got, tt.nonNegFloat)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	t.Setenv("CONFIG_TEST", "  redis ")
+	if got := String("CONFIG_TEST", "memory"); got != "redis" {
+		t.Errorf("String = %q, want redis", got)
+	}
+	t.Setenv("CONFIG_TEST", " ")
+	if got := String("CONFIG_TEST", "memory"); got != "memory" {
+		t.Errorf("String of a blank value = %q, want the fallback", got)
+	}
+
+	t.Setenv("CONFIG_TEST", " 0xabc, ,0xdef ,")
+	if got := List("CONFIG_TEST"); len(got) != 2 || got[0] != "0xabc" || got[1] != "0xdef" {
+		t.Errorf("List = %q, want [0xabc 0xdef]", got)
+	}
+	t.Setenv("CONFIG_TEST", "")
+	if got := List("CONFIG_TEST"); len(got) != 0 {
+		t.Errorf("List of an unset value = %q, want none", got)
+	}
+}
+
+func TestBool(t *testing.T) {
+	tests := []struct {
+		value          string
+		fallback, want bool
+	}{
+		{"", true, true},
+		{"", false, false},
+		{"true", false, true},
+		{" TRUE ", false, true},
+		{"1", false, true},
+		{"false", true, false},
+		{"0", true, false},
+		{"yes", true, true},
+		{"yes", false, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("CONFIG_TEST", tt.value)
+		if got := Bool("CONFIG_TEST", tt.fallback); got != tt.want {
+			t.Errorf("Bool(%q, %v) = %v, want %v", tt.value, tt.fallback, got, tt.want)
+		}
+	}
+}
@@ -93,53 +93,6 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-// Migrate runs the database migrations
-func Migrate() error {
-	schemaPath := "database/schema.sql"
-	schema, err := os.ReadFile(schemaPath)
-	if err != nil {
-		return fmt.Errorf("failed to read schema file: %w", err)
-	}
-
-	_, err = DB.Exec(string(schema))
-	if err != nil {
-		// Check if it's a "already exists" error - this is okay
-		if containsAny(err.Error(), []string{"already exists", "duplicate key"}) {
-			log.Println("Database schema already up to date")
-			return nil
-		}
-		return fmt.Errorf("failed to execute schema: %w", err)
-	}
-
-	log.Println("Database migration completed successfully")
-	return nil
-}
-
-// containsAny checks if the string contains any of the substrings
-func containsAny(str string, substrings []string) bool {
-	for _, substr := range substrings {
-		if len(substr) > 0 && len(str) >= len(substr) && 
-		   (str == substr || 
-		    (len(str) > len(substr) && 
-		     (str[:len(substr)] == substr || 
-		      str[len(str)-len(substr):] == substr || 
-		      findSubstring(str, substr)))) {
-			return true
-		}
-	}
-	return false
-}
-
-// findSubstring checks if substring exists in string
-func findSubstring(str, substr string) bool {
-	for i := 0; i <= len(str)-len(substr); i++ {
-		if str[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
-
 // Helper functions for common queries
 
 // Exists checks if a record exists
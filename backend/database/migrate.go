@@ -0,0 +1,311 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the pg advisory lock held while migrating so that
+// concurrent backend instances never migrate at the same time
+const migrationLockKey int64 = 7238412905
+
+// Migration is a numbered schema change with its up and down SQL
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int64      `db:"version" json:"version"`
+	Name      string     `db:"name" json:"name"`
+	AppliedAt *time.Time `db:"applied_at" json:"applied_at,omitempty"`
+}
+
+// Migrate applies all pending migrations
+func Migrate() error {
+	return MigrateUp()
+}
+
+// MigrateUp applies all pending migrations in order
+func MigrateUp() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+			log.Printf("Applying migration %06d_%s", m.Version, m.Name)
+			err := runMigration(conn, m.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("migration %06d_%s failed: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+
+		if count == 0 {
+			log.Println("Database schema already up to date")
+		} else {
+			log.Printf("Applied %d migration(s)", count)
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the given number of most recent migrations
+func MigrateDown(steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if !applied[m.Version] {
+				continue
+			}
+			if strings.TrimSpace(m.Down) == "" {
+				return fmt.Errorf("migration %06d_%s has no down script", m.Version, m.Name)
+			}
+			log.Printf("Reverting migration %06d_%s", m.Version, m.Name)
+			err := runMigration(conn, m.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			if err != nil {
+				return fmt.Errorf("rollback of %06d_%s failed: %w", m.Version, m.Name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatuses lists every known migration and when it was applied
+func MigrationStatuses() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := DB.Exec(createMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	var applied []MigrationStatus
+	if err := DB.Select(&applied, `SELECT version, name, applied_at FROM schema_migrations`); err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int64]*time.Time, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			AppliedAt: appliedAt[m.Version],
+		}
+	}
+	return statuses, nil
+}
+
+// ForceVersion records the schema as migrated exactly up to version without
+// running any SQL, for recovering from a manually repaired database
+func ForceVersion(version int64) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(func(conn *sql.Conn) error {
+		ctx := context.Background()
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > $1`, version); err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if m.Version > version {
+				break
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+				ON CONFLICT (version) DO NOTHING`, m.Version, m.Name)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+// withMigrationLock runs fn on a single connection holding the advisory lock
+func withMigrationLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns the set of applied migration versions
+func appliedVersions(conn *sql.Conn) (map[int64]bool, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes a migration script and its bookkeeping in one transaction
+func runMigration(conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadMigrations reads the embedded migration files sorted by version
+func loadMigrations() ([]*Migration, error) {
+	return parseMigrations(migrationFiles)
+}
+
+// parseMigrations reads the migrations/*.sql files of fsys sorted by version.
+// Every version needs an up script and may have a down script.
+func parseMigrations(fsys fs.FS) ([]*Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+		direction := ""
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", base)
+		}
+
+		versionStr, name, ok := strings.Cut(strings.TrimSuffix(base, "."+direction+".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s must be named <version>_<name>", base)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s must have a positive version", base)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, name)
+		}
+		script := &m.Up
+		if direction == "down" {
+			script = &m.Down
+		}
+		if *script != "" {
+			return nil, fmt.Errorf("migration %06d_%s has more than one %s script", version, name, direction)
+		}
+		*script = string(content)
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %06d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func migrationFS(files ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range files {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return fsys
+}
+
+func TestParseMigrations(t *testing.T) {
+	migrations, err := parseMigrations(migrationFS(
+		"000010_tenth.up.sql", "000010_tenth.down.sql",
+		"000002_second.up.sql",
+		"000001_first_one.up.sql", "000001_first_one.down.sql",
+	))
+	if err != nil {
+		t.Fatalf("parseMigrations: %v", err)
+	}
+
+	// Sorted by version, not by name or file order
+	want := []struct {
+		version int64
+		name    string
+		down    bool
+	}{
+		{1, "first_one", true},
+		{2, "second", false},
+		{10, "tenth", true},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(migrations), len(want))
+	}
+	for i, w := range want {
+		m := migrations[i]
+		if m.Version != w.version || m.Name != w.name || (m.Down != "") != w.down {
+			t.Errorf("migration %d = %+v, want version %d %s (down: %v)", i, m, w.version, w.name, w.down)
+		}
+		if want := fmt.Sprintf("-- %06d_%s.up.sql", w.version, w.name); m.Up != want {
+			t.Errorf("migration %d up = %q, want %q", i, m.Up, want)
+		}
+	}
+}
+
+func TestParseMigrationsRejects(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"down without up":     migrationFS("000001_first.up.sql", "000002_second.down.sql"),
+		"version used twice":  migrationFS("000001_first.up.sql", "000001_other.up.sql"),
+		"same script twice":   migrationFS("000001_first.up.sql", "1_first.up.sql"),
+		"no version":          migrationFS("first.up.sql"),
+		"non-numeric version": migrationFS("v1_first.up.sql"),
+		"no direction":        migrationFS("000001_first.sql"),
+		"unknown direction":   migrationFS("000001_first.sideways.sql"),
+		"negative version":    migrationFS("-1_first.up.sql"),
+		"version zero":        migrationFS("000000_first.up.sql"),
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if migrations, err := parseMigrations(fsys); err == nil {
+				t.Errorf("parsed %d migrations, want an error", len(migrations))
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	// Versions run 1, 2, 3... with no gaps, and each can be rolled back
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d has version %d, want %d", i, m.Version, i+1)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %06d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+-- Revert the NFTGenie baseline schema
+
+DROP TABLE IF EXISTS analytics;
+DROP TABLE IF EXISTS recommendations;
+DROP TABLE IF EXISTS user_preferences;
+DROP TABLE IF EXISTS user_interactions;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS marketplace_listings;
+DROP TABLE IF EXISTS nfts;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS users;
+
+DROP FUNCTION IF EXISTS update_updated_at_column();
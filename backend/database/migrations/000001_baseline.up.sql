@@ -141,6 +141,7 @@ CREATE INDEX IF NOT EXISTS idx_nfts_tags ON nfts USING GIN(tags);
 CREATE INDEX IF NOT EXISTS idx_nfts_attributes ON nfts USING GIN(attributes);
 CREATE INDEX IF NOT EXISTS idx_marketplace_status ON marketplace_listings(status);
 CREATE INDEX IF NOT EXISTS idx_marketplace_seller ON marketplace_listings(seller_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_marketplace_active_nft ON marketplace_listings(nft_id) WHERE status = 'active';
 CREATE INDEX IF NOT EXISTS idx_transactions_user ON transactions(from_user_id, to_user_id);
 CREATE INDEX IF NOT EXISTS idx_interactions_user ON user_interactions(user_id);
 CREATE INDEX IF NOT EXISTS idx_interactions_nft ON user_interactions(nft_id);
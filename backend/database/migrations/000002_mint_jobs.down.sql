@@ -0,0 +1,3 @@
+-- Revert mint jobs
+
+DROP TABLE IF EXISTS mint_jobs;
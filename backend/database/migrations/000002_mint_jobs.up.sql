@@ -0,0 +1,30 @@
+-- Mint jobs: durable queue of mints processed by the background worker
+CREATE TABLE IF NOT EXISTS mint_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    creator_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    image_url VARCHAR(500) NOT NULL,
+    tags TEXT[],
+    recipient_address VARCHAR(42) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued, submitted, confirmed, failed
+    provider VARCHAR(20),
+    chain VARCHAR(50),
+    transaction_hash VARCHAR(66),
+    contract_address VARCHAR(42),
+    token_id VARCHAR(255),
+    nft_id UUID REFERENCES nfts(id) ON DELETE SET NULL,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_after TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Earliest time a worker may pick the job up
+    locked_until TIMESTAMP, -- Lease held by the worker processing the job
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mint_jobs_pending ON mint_jobs(run_after) WHERE status IN ('queued', 'submitted');
+CREATE INDEX IF NOT EXISTS idx_mint_jobs_creator ON mint_jobs(creator_id);
+
+DROP TRIGGER IF EXISTS update_mint_jobs_updated_at ON mint_jobs;
+CREATE TRIGGER update_mint_jobs_updated_at BEFORE UPDATE ON mint_jobs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,9 @@
+-- Revert transaction confirmations
+
+DROP INDEX IF EXISTS idx_transactions_confirmed_at;
+DROP INDEX IF EXISTS idx_transactions_pending;
+
+ALTER TABLE nfts DROP COLUMN IF EXISTS mint_status;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS confirmed_at;
+ALTER TABLE transactions DROP COLUMN IF EXISTS block_hash;
@@ -0,0 +1,9 @@
+-- Transaction confirmations: receipt details tracked by the confirmation worker
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS block_hash VARCHAR(66);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP; -- When the status became confirmed or failed
+
+-- NFTs minted before the tracker existed are treated as confirmed
+ALTER TABLE nfts ADD COLUMN IF NOT EXISTS mint_status VARCHAR(20) NOT NULL DEFAULT 'confirmed'; -- pending, confirmed, failed
+
+CREATE INDEX IF NOT EXISTS idx_transactions_pending ON transactions(created_at) WHERE status = 'pending' AND transaction_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_confirmed_at ON transactions(confirmed_at) WHERE transaction_hash IS NOT NULL;
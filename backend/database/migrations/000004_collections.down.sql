@@ -0,0 +1,5 @@
+-- Revert collections
+
+ALTER TABLE mint_jobs DROP COLUMN IF EXISTS collection_id;
+
+DROP INDEX IF EXISTS idx_collections_creator;
@@ -0,0 +1,4 @@
+-- Collections: creator lookups and minting into a collection
+CREATE INDEX IF NOT EXISTS idx_collections_creator ON collections(creator_id);
+
+ALTER TABLE mint_jobs ADD COLUMN IF NOT EXISTS collection_id UUID REFERENCES collections(id) ON DELETE SET NULL;
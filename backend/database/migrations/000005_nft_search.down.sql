@@ -0,0 +1,6 @@
+-- Revert NFT search
+
+DROP INDEX IF EXISTS idx_nfts_search;
+DROP TRIGGER IF EXISTS update_nfts_search_vector ON nfts;
+DROP FUNCTION IF EXISTS update_nfts_search_vector();
+ALTER TABLE nfts DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,24 @@
+-- NFT search: weighted full-text document over name, tags and description
+ALTER TABLE nfts ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION update_nfts_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', coalesce(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(array_to_string(NEW.tags, ' '), '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(NEW.description, '')), 'C');
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_nfts_search_vector ON nfts;
+CREATE TRIGGER update_nfts_search_vector BEFORE INSERT OR UPDATE OF name, description, tags ON nfts
+    FOR EACH ROW EXECUTE FUNCTION update_nfts_search_vector();
+
+-- Backfill existing rows without touching updated_at
+ALTER TABLE nfts DISABLE TRIGGER update_nfts_updated_at;
+UPDATE nfts SET name = name;
+ALTER TABLE nfts ENABLE TRIGGER update_nfts_updated_at;
+
+CREATE INDEX IF NOT EXISTS idx_nfts_search ON nfts USING GIN(search_vector);
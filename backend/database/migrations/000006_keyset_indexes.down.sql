@@ -0,0 +1,7 @@
+-- Revert keyset pagination indexes
+
+DROP INDEX IF EXISTS idx_transactions_created;
+DROP INDEX IF EXISTS idx_marketplace_created;
+DROP INDEX IF EXISTS idx_collections_created;
+DROP INDEX IF EXISTS idx_users_created;
+DROP INDEX IF EXISTS idx_nfts_created;
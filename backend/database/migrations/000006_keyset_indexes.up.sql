@@ -0,0 +1,6 @@
+-- Keyset pagination: list endpoints page by (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_nfts_created ON nfts(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_created ON users(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_collections_created ON collections(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_marketplace_created ON marketplace_listings(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_created ON transactions(created_at, id);
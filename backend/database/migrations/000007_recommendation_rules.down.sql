@@ -0,0 +1,5 @@
+-- Revert recommendation rules
+
+DROP INDEX IF EXISTS idx_recommendations_fresh;
+
+ALTER TABLE recommendations DROP COLUMN IF EXISTS rule;
@@ -0,0 +1,7 @@
+-- Recommendations cache: record which rule produced each row. Cached rows
+-- predate the column and are simply recomputed.
+DELETE FROM recommendations;
+
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS rule VARCHAR(20) NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_recommendations_fresh ON recommendations(user_id, algorithm_version, expires_at);
@@ -0,0 +1,10 @@
+-- Revert training jobs and the model registry
+
+ALTER TABLE recommendations DROP CONSTRAINT IF EXISTS recommendations_algorithm_version_fkey;
+DELETE FROM recommendations WHERE length(algorithm_version) > 20;
+ALTER TABLE recommendations ALTER COLUMN algorithm_version DROP NOT NULL;
+ALTER TABLE recommendations ALTER COLUMN algorithm_version TYPE VARCHAR(20);
+
+ALTER TABLE model_versions DROP CONSTRAINT IF EXISTS model_versions_training_job_id_fkey;
+DROP TABLE IF EXISTS training_jobs;
+DROP TABLE IF EXISTS model_versions;
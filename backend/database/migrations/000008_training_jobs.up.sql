@@ -0,0 +1,56 @@
+-- Recommendation model registry: every algorithm_version a cached
+-- recommendation can carry, from the SQL rules or an ai-engine training
+CREATE TABLE IF NOT EXISTS model_versions (
+    version VARCHAR(64) PRIMARY KEY,
+    source VARCHAR(20) NOT NULL, -- sql, ai-engine
+    engine_version VARCHAR(20), -- model_version reported by the ai-engine
+    trained_at TIMESTAMP, -- last_trained reported by the ai-engine
+    training_job_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO model_versions (version, source) VALUES ('sql-v1', 'sql') ON CONFLICT (version) DO NOTHING;
+
+-- Rows cached before the registry existed name unregistered versions
+DELETE FROM recommendations WHERE algorithm_version IS NULL
+    OR algorithm_version NOT IN (SELECT version FROM model_versions);
+
+ALTER TABLE recommendations ALTER COLUMN algorithm_version TYPE VARCHAR(64);
+ALTER TABLE recommendations ALTER COLUMN algorithm_version SET NOT NULL;
+ALTER TABLE recommendations DROP CONSTRAINT IF EXISTS recommendations_algorithm_version_fkey;
+ALTER TABLE recommendations ADD CONSTRAINT recommendations_algorithm_version_fkey
+    FOREIGN KEY (algorithm_version) REFERENCES model_versions(version);
+
+-- Training jobs: durable queue of ai-engine trainings run by the training worker
+CREATE TABLE IF NOT EXISTS training_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued, training, completed, failed
+    force_retrain BOOLEAN NOT NULL DEFAULT FALSE,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    dataset_path TEXT, -- Snapshot of the data exported for the training
+    interaction_count INTEGER,
+    nft_count INTEGER,
+    preference_count INTEGER,
+    previous_trained_at TEXT, -- Engine's last_trained when training was triggered
+    model_version VARCHAR(64) REFERENCES model_versions(version),
+    message TEXT,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_after TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP,
+    triggered_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one training is queued or running at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_training_jobs_active ON training_jobs((true)) WHERE status IN ('queued', 'training');
+
+ALTER TABLE model_versions DROP CONSTRAINT IF EXISTS model_versions_training_job_id_fkey;
+ALTER TABLE model_versions ADD CONSTRAINT model_versions_training_job_id_fkey
+    FOREIGN KEY (training_job_id) REFERENCES training_jobs(id) ON DELETE SET NULL;
+
+DROP TRIGGER IF EXISTS update_training_jobs_updated_at ON training_jobs;
+CREATE TRIGGER update_training_jobs_updated_at BEFORE UPDATE ON training_jobs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,5 @@
+-- Revert precomputed trending scores
+
+DROP INDEX IF EXISTS idx_transactions_purchases;
+DROP INDEX IF EXISTS idx_user_interactions_created;
+DROP TABLE IF EXISTS trending_scores;
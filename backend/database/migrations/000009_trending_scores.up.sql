@@ -0,0 +1,20 @@
+-- Trending NFTs precomputed per window by the trending worker; each refresh
+-- replaces a window's rows in one transaction
+CREATE TABLE IF NOT EXISTS trending_scores (
+    period VARCHAR(8) NOT NULL, -- 1h, 24h, 7d, 30d
+    nft_id UUID NOT NULL REFERENCES nfts(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL,
+    score DOUBLE PRECISION NOT NULL, -- Time-decayed interaction and sales score
+    interactions INTEGER NOT NULL DEFAULT 0,
+    sales INTEGER NOT NULL DEFAULT 0,
+    volume DECIMAL(20, 8) NOT NULL DEFAULT 0,
+    computed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (period, nft_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trending_scores_rank ON trending_scores(period, rank);
+
+-- Windowed scans of interactions and sales
+CREATE INDEX IF NOT EXISTS idx_user_interactions_created ON user_interactions(created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_purchases ON transactions(COALESCE(confirmed_at, created_at))
+    WHERE type = 'purchase' AND status = 'confirmed';
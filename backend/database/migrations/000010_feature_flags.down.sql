@@ -0,0 +1,3 @@
+-- Revert feature flag overrides
+
+DROP TABLE IF EXISTS feature_flags;
@@ -0,0 +1,9 @@
+-- Runtime overrides of the ENABLE_* feature flag defaults, set by admins.
+-- A disabled feature stays available to the wallets allowed on it.
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name VARCHAR(64) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    allowed_wallets TEXT[] NOT NULL DEFAULT '{}',
+    updated_by VARCHAR(42),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Revert lowercase wallet addresses; the addresses stay lowercased
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_wallet_address_lowercase;
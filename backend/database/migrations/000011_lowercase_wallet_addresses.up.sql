@@ -0,0 +1,9 @@
+-- Wallet addresses are stored lowercased so checksummed and lowercase forms
+-- of one wallet resolve to the same user. If this fails on the unique
+-- constraint, the same wallet already has two users that must be merged by
+-- hand first.
+UPDATE users SET wallet_address = lower(wallet_address)
+WHERE wallet_address <> lower(wallet_address);
+
+ALTER TABLE users ADD CONSTRAINT users_wallet_address_lowercase
+    CHECK (wallet_address = lower(wallet_address));
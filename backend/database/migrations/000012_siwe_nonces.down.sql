@@ -0,0 +1,4 @@
+-- Revert per-challenge sign-in nonces
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS nonce VARCHAR(255);
+DROP TABLE IF EXISTS siwe_nonces;
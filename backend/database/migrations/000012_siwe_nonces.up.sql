@@ -0,0 +1,13 @@
+-- Sign-in challenges, one row per issued nonce, replacing the single nonce
+-- per user that every challenge request overwrote. Rows are deleted when a
+-- login consumes them or, once expired, when later challenges are issued.
+CREATE TABLE IF NOT EXISTS siwe_nonces (
+    nonce VARCHAR(64) PRIMARY KEY,
+    wallet_address VARCHAR(42) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_siwe_nonces_expires ON siwe_nonces(expires_at);
+
+ALTER TABLE users DROP COLUMN IF EXISTS nonce;
@@ -0,0 +1,3 @@
+-- Revert unique purchase hashes
+
+DROP INDEX IF EXISTS idx_transactions_purchase_hash;
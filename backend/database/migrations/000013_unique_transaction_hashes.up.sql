@@ -0,0 +1,3 @@
+-- A payment backs at most one purchase. Mints are left out: one batch mint
+-- transaction can create several NFTs.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_purchase_hash ON transactions(LOWER(transaction_hash)) WHERE type = 'purchase';
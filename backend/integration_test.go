@@ -0,0 +1,2080 @@
+//go:build integration
+
+// Integration tests drive the HTTP handlers end to end against a throwaway
+// Postgres database and the in-process fake Verbwire server.
+//
+// Run from backend/ with DB_* pointing at a Postgres server the user can
+// create databases on:
+//
+//	go test -tags integration .
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nftgenie/backend/cache"
+	"nftgenie/backend/database"
+	"nftgenie/backend/middleware"
+	"nftgenie/backend/models"
+	"nftgenie/backend/repository"
+	"nftgenie/backend/services"
+	"nftgenie/backend/services/verbwiretest"
+	"nftgenie/backend/workers"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/joho/godotenv"
+	"github.com/lib/pq"
+	gofrerrors "gofr.dev/pkg/errors"
+	"gofr.dev/pkg/gofr"
+	"gofr.dev/pkg/gofr/request"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	testApp  *gofr.Gofr
+	verbwire *verbwiretest.Server
+	tracker  *workers.ConfirmationTracker
+)
+
+// TestMain creates a throwaway database, migrates it and points the
+// Verbwire client at the fake server
+func TestMain(m *testing.M) {
+	godotenv.Load()
+
+	adminDSN := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=postgres sslmode=%s",
+		envOr("DB_HOST", "localhost"), envOr("DB_PORT", "5432"), envOr("DB_USER", "postgres"),
+		os.Getenv("DB_PASSWORD"), envOr("DB_SSLMODE", "disable"))
+	admin, err := sql.Open("postgres", adminDSN)
+	if err == nil {
+		err = admin.Ping()
+	}
+	if err != nil {
+		fmt.Printf("skipping integration tests, Postgres unavailable: %v\n", err)
+		os.Exit(0)
+	}
+
+	dbName := fmt.Sprintf("nftgenie_it_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + dbName); err != nil {
+		fmt.Printf("failed to create test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	verbwire = verbwiretest.NewServer()
+	os.Setenv("DB_NAME", dbName)
+	os.Setenv("VERBWIRE_BASE_URL", verbwire.URL)
+	os.Setenv("VERBWIRE_API_KEY", verbwiretest.APIKey)
+	os.Setenv("CHAIN", "polygonAmoy")
+	// Surface the first injected Verbwire failure instead of retrying it
+	os.Setenv("VERBWIRE_MAX_RETRIES", "0")
+	os.Setenv("JWT_SECRET", "integration-test-secret-with-32-plus-chars")
+	// Recommendations come from the SQL rules unless a test starts a fake engine
+	os.Setenv("AI_ENGINE_URL", "")
+
+	code := 1
+	if err := database.Initialize(); err != nil {
+		fmt.Printf("failed to connect to test database: %v\n", err)
+	} else if err := database.Migrate(); err != nil {
+		fmt.Printf("failed to migrate test database: %v\n", err)
+	} else {
+		testApp = gofr.New()
+		mintWorker = workers.NewMintWorker(services.NewVerbwireService())
+		tracker = workers.NewConfirmationTracker(services.NewVerbwireService())
+		aiEngine = services.NewAIEngineClient()
+		recommender = services.NewRecommender(aiEngine)
+		trainingWorker = workers.NewTrainingWorker(aiEngine)
+		viewPipeline = workers.NewViewPipeline()
+		featureFlags = services.NewFeatureFlags()
+		// readCache stays nil so handlers read through; see useReadCache
+		code = m.Run()
+	}
+
+	verbwire.Close()
+	database.Close()
+	admin.Exec("DROP DATABASE IF EXISTS " + dbName + " WITH (FORCE)")
+	admin.Close()
+	os.Exit(code)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// newContext builds a handler context, authenticated as wallet when set
+func newContext(t *testing.T, method, target string, body interface{}, pathParams map[string]string, wallet string) *gofr.Context {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if pathParams != nil {
+		req = mux.SetURLVars(req, pathParams)
+	}
+	if wallet != "" {
+		req = req.WithContext(middleware.WithWallet(req.Context(), wallet))
+	}
+
+	return gofr.NewContext(nil, request.NewHTTPRequest(req), testApp)
+}
+
+// statusCode returns the HTTP status gofr answers a handler error with
+func statusCode(err error) int {
+	var response *gofrerrors.Response
+	if errors.As(err, &response) {
+		return response.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+// testWallet is a freshly generated Ethereum account
+type testWallet struct {
+	key     *secp256k1.PrivateKey
+	address string
+}
+
+func newTestWallet(t *testing.T) *testWallet {
+	t.Helper()
+
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	h := sha3.NewLegacyKeccak256()
+	h.Write(key.PubKey().SerializeUncompressed()[1:])
+	return &testWallet{key: key, address: "0x" + hex.EncodeToString(h.Sum(nil)[12:])}
+}
+
+// personalSign signs message the way a wallet's personal_sign does
+func (w *testWallet) personalSign(message string) string {
+	h := sha3.NewLegacyKeccak256()
+	fmt.Fprintf(h, "\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	compact := ecdsa.SignCompact(w.key, h.Sum(nil), false)
+
+	// Reorder <v> || r || s into r || s || <v>
+	sig := append(compact[1:65:65], compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+// newTxHash returns a random transaction hash
+func newTxHash() string {
+	a, b := uuid.New(), uuid.New()
+	return "0x" + hex.EncodeToString(a[:]) + hex.EncodeToString(b[:])
+}
+
+// queueMint queues a mint for the wallet and returns the job ID
+func queueMint(t *testing.T, wallet *testWallet, name string) uuid.UUID {
+	t.Helper()
+
+	ctx := newContext(t, "POST", "/api/nfts/mint", map[string]interface{}{
+		"name":      name,
+		"image_url": "https://example.com/" + name + ".png",
+		"creator":   wallet.address,
+		"tags":      []string{"art", "genie"},
+	}, nil, wallet.address)
+
+	res, err := mintNFT(ctx)
+	if err != nil {
+		t.Fatalf("mintNFT: %v", err)
+	}
+	body := res.(map[string]interface{})
+	if body["success"] != true || body["status"] != models.MintJobQueued {
+		t.Fatalf("mint not queued: %v", body)
+	}
+	return body["job_id"].(uuid.UUID)
+}
+
+// drainMintJobs runs the mint worker until no job is runnable
+func drainMintJobs(t *testing.T) {
+	t.Helper()
+
+	for {
+		found, err := mintWorker.RunOnce(context.Background())
+		if err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+// settleTransactions runs the confirmation tracker once
+func settleTransactions(t *testing.T) {
+	t.Helper()
+
+	if err := tracker.RunOnce(context.Background()); err != nil {
+		t.Fatalf("tracker RunOnce: %v", err)
+	}
+}
+
+// setTransactionDetails replaces the fake transaction details until the test
+// ends
+func setTransactionDetails(t *testing.T, details string) {
+	t.Helper()
+
+	verbwire.SetResponse(verbwiretest.PathTxDetails, []byte(`{"transaction_details": `+details+`}`))
+	t.Cleanup(func() {
+		verbwire.SetResponse(verbwiretest.PathTxDetails, verbwiretest.Fixture("transaction_details.json"))
+	})
+}
+
+// getJob fetches a mint job through the status endpoint
+func getJob(t *testing.T, jobID uuid.UUID) *models.MintJob {
+	t.Helper()
+
+	ctx := newContext(t, "GET", "/api/mints/"+jobID.String(), nil, map[string]string{"jobId": jobID.String()}, "")
+	res, err := getMintJob(ctx)
+	if err != nil {
+		t.Fatalf("getMintJob: %v", err)
+	}
+	return res.(*models.MintJob)
+}
+
+// mint mints an NFT owned by the wallet and returns its ID
+func mint(t *testing.T, wallet *testWallet, name string) uuid.UUID {
+	t.Helper()
+
+	jobID := queueMint(t, wallet, name)
+	drainMintJobs(t)
+	settleTransactions(t)
+
+	job := getJob(t, jobID)
+	if job.Status != models.MintJobConfirmed || job.NFTID == nil {
+		t.Fatalf("mint job = %+v, want confirmed with an NFT", job)
+	}
+	return *job.NFTID
+}
+
+func TestMintNFT(t *testing.T) {
+	wallet := newTestWallet(t)
+	before := len(verbwire.Requests(verbwiretest.PathQuickMint))
+
+	nftID := mint(t, wallet, "lamp")
+
+	reqs := verbwire.Requests(verbwiretest.PathQuickMint)
+	if len(reqs) != before+1 {
+		t.Fatalf("got %d new mint calls, want 1", len(reqs)-before)
+	}
+	if got := reqs[len(reqs)-1].Form.Get("recipientAddress"); got != wallet.address {
+		t.Errorf("recipientAddress = %q, want %q", got, wallet.address)
+	}
+
+	nft, err := repository.NewNFTRepository().GetByID(nftID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if nft.TokenID == nil || *nft.TokenID != "42" {
+		t.Errorf("token_id = %v, want 42", nft.TokenID)
+	}
+	if nft.Creator == nil || nft.Creator.WalletAddress != wallet.address {
+		t.Errorf("creator = %+v, want %s", nft.Creator, wallet.address)
+	}
+}
+
+func TestMintNFTVerbwireFailures(t *testing.T) {
+	tests := map[string]struct {
+		failure    verbwiretest.Failure
+		wantStatus string
+	}{
+		// Rate limiting means Verbwire did not mint, so the job is retried later
+		"rate limited":   {verbwiretest.RateLimited, models.MintJobQueued},
+		"server error":   {verbwiretest.ServerError, models.MintJobFailed},
+		"malformed json": {verbwiretest.MalformedJSON, models.MintJobFailed},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			wallet := newTestWallet(t)
+			verbwire.FailNext(verbwiretest.PathQuickMint, tc.failure)
+
+			jobID := queueMint(t, wallet, "broken")
+			drainMintJobs(t)
+
+			job := getJob(t, jobID)
+			if job.Status != tc.wantStatus || job.Error == nil {
+				t.Errorf("job status = %s, error = %v, want %s with an error", job.Status, job.Error, tc.wantStatus)
+			}
+
+			nfts, err := repository.NewNFTRepository().GetByCreator(job.CreatorID)
+			if err != nil {
+				t.Fatalf("GetByCreator: %v", err)
+			}
+			if len(nfts) != 0 {
+				t.Errorf("got %d NFTs after failed mint, want 0", len(nfts))
+			}
+		})
+	}
+}
+
+func TestMintJobResumesAfterRestart(t *testing.T) {
+	wallet := newTestWallet(t)
+	jobID := queueMint(t, wallet, "interrupted")
+
+	// Simulate a worker that claimed the job and died before finishing
+	if _, err := database.DB.Exec(`
+		UPDATE mint_jobs SET attempts = 1, locked_until = NOW() - INTERVAL '1 second'
+		WHERE id = $1`, jobID); err != nil {
+		t.Fatalf("expire lease: %v", err)
+	}
+
+	drainMintJobs(t)
+	settleTransactions(t)
+
+	job := getJob(t, jobID)
+	if job.Status != models.MintJobConfirmed || job.NFTID == nil || job.Attempts != 2 {
+		t.Errorf("job = %+v, want confirmed on the second attempt", job)
+	}
+}
+
+func TestSubmittedMintJobIsRecordedWithoutReminting(t *testing.T) {
+	wallet := newTestWallet(t)
+	jobID := queueMint(t, wallet, "orphan")
+
+	// Simulate a job whose token was minted but whose NFT row was never written
+	if _, err := database.DB.Exec(`
+		UPDATE mint_jobs SET
+			status = 'submitted', attempts = 1, provider = 'verbwire', chain = 'polygonAmoy',
+			transaction_hash = '0xabc', contract_address = '0xdef', token_id = '7'
+		WHERE id = $1`, jobID); err != nil {
+		t.Fatalf("mark submitted: %v", err)
+	}
+
+	drainMintJobs(t)
+	for _, req := range verbwire.Requests(verbwiretest.PathQuickMint) {
+		if req.Form.Get("recipientAddress") == wallet.address {
+			t.Errorf("submitted job was minted again")
+		}
+	}
+	settleTransactions(t)
+
+	job := getJob(t, jobID)
+	if job.Status != models.MintJobConfirmed || job.NFTID == nil {
+		t.Fatalf("job = %+v, want confirmed with an NFT", job)
+	}
+
+	nft, err := repository.NewNFTRepository().GetByID(*job.NFTID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if nft.TokenID == nil || *nft.TokenID != "7" {
+		t.Errorf("token_id = %v, want 7", nft.TokenID)
+	}
+
+	txs, err := repository.NewTransactionRepository().GetByNFT(nft.ID)
+	if err != nil {
+		t.Fatalf("GetByNFT: %v", err)
+	}
+	if len(txs) != 1 || txs[0].Type != "mint" || *txs[0].TransactionHash != "0xabc" {
+		t.Errorf("transactions = %+v, want one mint with the job's hash", txs)
+	}
+}
+
+// failMarkSubmitted makes the next n updates marking a mint job submitted
+// fail, as a brief database outage would. Sequence values survive the
+// rollback of the failing statement, so they count the attempts.
+func failMarkSubmitted(t *testing.T, n int) {
+	t.Helper()
+
+	_, err := database.DB.Exec(fmt.Sprintf(`
+		CREATE SEQUENCE mark_submitted_attempts;
+		CREATE FUNCTION fail_mark_submitted() RETURNS trigger AS $$
+		BEGIN
+			IF nextval('mark_submitted_attempts') <= %d THEN
+				RAISE EXCEPTION 'injected failure';
+			END IF;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;
+		CREATE TRIGGER fail_mark_submitted BEFORE UPDATE ON mint_jobs
+			FOR EACH ROW WHEN (OLD.status = 'queued' AND NEW.status = 'submitted')
+			EXECUTE FUNCTION fail_mark_submitted();`, n))
+	if err != nil {
+		t.Fatalf("install failing trigger: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Exec(`
+			DROP TRIGGER IF EXISTS fail_mark_submitted ON mint_jobs;
+			DROP FUNCTION IF EXISTS fail_mark_submitted();
+			DROP SEQUENCE IF EXISTS mark_submitted_attempts;`)
+	})
+}
+
+func TestMintResultSavedAfterTransientFailure(t *testing.T) {
+	wallet := newTestWallet(t)
+	jobID := queueMint(t, wallet, "flaky")
+	failMarkSubmitted(t, 2)
+
+	drainMintJobs(t)
+
+	var attempts int
+	if err := database.DB.Get(&attempts, `SELECT last_value FROM mark_submitted_attempts`); err != nil {
+		t.Fatalf("read attempts: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("marked submitted in %d attempts, want 3", attempts)
+	}
+	mints := 0
+	for _, req := range verbwire.Requests(verbwiretest.PathQuickMint) {
+		if req.Form.Get("recipientAddress") == wallet.address {
+			mints++
+		}
+	}
+	if mints != 1 {
+		t.Errorf("minted %d times, want once", mints)
+	}
+
+	job := getJob(t, jobID)
+	if job.Status != models.MintJobSubmitted || job.NFTID == nil || job.Attempts != 1 || job.TransactionHash == nil {
+		t.Errorf("job = %+v, want submitted with an NFT on the first attempt", job)
+	}
+}
+
+func TestMintConfirmation(t *testing.T) {
+	wallet := newTestWallet(t)
+	jobID := queueMint(t, wallet, "pending")
+	drainMintJobs(t)
+
+	job := getJob(t, jobID)
+	if job.Status != models.MintJobSubmitted || job.NFTID == nil {
+		t.Fatalf("job = %+v, want submitted with an NFT", job)
+	}
+	nft, err := repository.NewNFTRepository().GetByID(*job.NFTID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if nft.MintStatus != models.TransactionPending {
+		t.Errorf("mint_status = %q, want pending", nft.MintStatus)
+	}
+
+	// Mined but not deep enough yet
+	setTransactionDetails(t, `{"blockNumber": 6137731, "blockHash": "0x01", "status": 1, "gasUsed": "21000", "effectiveGasPrice": "1000000000", "confirmations": 3}`)
+	settleTransactions(t)
+
+	txs, err := repository.NewTransactionRepository().GetByNFT(nft.ID)
+	if err != nil {
+		t.Fatalf("GetByNFT: %v", err)
+	}
+	if len(txs) != 1 || txs[0].Status != models.TransactionPending || txs[0].BlockNumber == nil || *txs[0].BlockNumber != 6137731 {
+		t.Fatalf("transactions = %+v, want one pending mint in block 6137731", txs)
+	}
+	if txs[0].GasFee == nil || *txs[0].GasFee != 0.000021 {
+		t.Errorf("gas_fee = %v, want 0.000021", txs[0].GasFee)
+	}
+
+	setTransactionDetails(t, `{"blockNumber": 6137731, "blockHash": "0x01", "status": 1, "gasUsed": "21000", "effectiveGasPrice": "1000000000", "confirmations": 12}`)
+	settleTransactions(t)
+
+	if job := getJob(t, jobID); job.Status != models.MintJobConfirmed {
+		t.Errorf("job status = %q, want confirmed", job.Status)
+	}
+	tx, err := repository.NewTransactionRepository().GetByID(txs[0].ID)
+	if err != nil {
+		t.Fatalf("GetByID transaction: %v", err)
+	}
+	if tx.Status != models.TransactionConfirmed || tx.ConfirmedAt == nil {
+		t.Errorf("transaction = %+v, want confirmed", tx)
+	}
+	if nft, _ = repository.NewNFTRepository().GetByID(nft.ID); nft.MintStatus != models.TransactionConfirmed {
+		t.Errorf("mint_status = %q, want confirmed", nft.MintStatus)
+	}
+
+	// A reorg moves the transaction to another block: it is reopened and
+	// confirmed again in its new block
+	setTransactionDetails(t, `{"blockNumber": 6137735, "blockHash": "0x02", "status": 1, "gasUsed": "21000", "effectiveGasPrice": "1000000000", "confirmations": 2}`)
+	settleTransactions(t)
+
+	if job := getJob(t, jobID); job.Status != models.MintJobSubmitted {
+		t.Errorf("job status after reorg = %q, want submitted", job.Status)
+	}
+	if nft, _ = repository.NewNFTRepository().GetByID(nft.ID); nft.MintStatus != models.TransactionPending {
+		t.Errorf("mint_status after reorg = %q, want pending", nft.MintStatus)
+	}
+
+	setTransactionDetails(t, `{"blockNumber": 6137735, "blockHash": "0x02", "status": 1, "gasUsed": "21000", "effectiveGasPrice": "1000000000", "confirmations": 12}`)
+	settleTransactions(t)
+
+	tx, err = repository.NewTransactionRepository().GetByID(tx.ID)
+	if err != nil {
+		t.Fatalf("GetByID transaction: %v", err)
+	}
+	if tx.Status != models.TransactionConfirmed || *tx.BlockNumber != 6137735 || *tx.BlockHash != "0x02" {
+		t.Errorf("transaction = %+v, want confirmed in block 6137735", tx)
+	}
+}
+
+func TestMintFailedOnChain(t *testing.T) {
+	wallet := newTestWallet(t)
+	jobID := queueMint(t, wallet, "reverted")
+	drainMintJobs(t)
+
+	setTransactionDetails(t, `{"blockNumber": 6137731, "blockHash": "0x03", "status": 0, "gasUsed": "21000", "effectiveGasPrice": "1000000000", "confirmations": 12}`)
+	settleTransactions(t)
+
+	job := getJob(t, jobID)
+	if job.Status != models.MintJobFailed || job.NFTID == nil {
+		t.Fatalf("job = %+v, want failed with an NFT", job)
+	}
+	nft, err := repository.NewNFTRepository().GetByID(*job.NFTID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if nft.MintStatus != models.TransactionFailed {
+		t.Errorf("mint_status = %q, want failed", nft.MintStatus)
+	}
+
+	_, err = listNFTForSale(newContext(t, "POST", "/api/marketplace/list", map[string]interface{}{
+		"nft_id": nft.ID.String(),
+		"price":  1.5,
+		"seller": wallet.address,
+	}, nil, wallet.address))
+	if err == nil {
+		t.Error("expected listing a failed mint to fail")
+	}
+}
+
+func TestFailedPurchaseIsReverted(t *testing.T) {
+	seller := newTestWallet(t)
+	nftID := mint(t, seller, "bounced")
+
+	res, err := listNFTForSale(newContext(t, "POST", "/api/marketplace/list", map[string]interface{}{
+		"nft_id": nftID.String(),
+		"price":  2.0,
+		"seller": seller.address,
+	}, nil, seller.address))
+	if err != nil {
+		t.Fatalf("listNFTForSale: %v", err)
+	}
+	listingID := res.(map[string]interface{})["listing_id"].(uuid.UUID)
+
+	buyer := newTestWallet(t)
+	res, err = buyNFT(newContext(t, "POST", "/api/marketplace/buy", map[string]interface{}{
+		"nft_id":           nftID.String(),
+		"buyer":            buyer.address,
+		"price":            "2.0",
+		"transaction_hash": newTxHash(),
+	}, nil, buyer.address))
+	if err != nil {
+		t.Fatalf("buyNFT: %v", err)
+	}
+	purchaseID := res.(map[string]interface{})["transaction_id"].(uuid.UUID)
+
+	setTransactionDetails(t, `{"blockNumber": 6137740, "blockHash": "0x04", "status": 0, "gasUsed": "21000", "effectiveGasPrice": "1000000000", "confirmations": 12}`)
+	settleTransactions(t)
+
+	purchase, err := repository.NewTransactionRepository().GetByID(purchaseID)
+	if err != nil {
+		t.Fatalf("GetByID transaction: %v", err)
+	}
+	if purchase.Status != models.TransactionFailed {
+		t.Errorf("purchase status = %q, want failed", purchase.Status)
+	}
+
+	listing, err := repository.NewMarketplaceRepository().GetByID(listingID)
+	if err != nil {
+		t.Fatalf("GetByID listing: %v", err)
+	}
+	if listing.Status != "active" || listing.BuyerID != nil {
+		t.Errorf("listing = %+v, want active again", listing)
+	}
+	owner, err := repository.NewUserRepository().GetByWalletAddress(seller.address)
+	if err != nil {
+		t.Fatalf("GetByWalletAddress: %v", err)
+	}
+	if listing.NFT.OwnerID != owner.ID {
+		t.Errorf("NFT owner = %s, want seller %s", listing.NFT.OwnerID, owner.ID)
+	}
+}
+
+func TestGetMintJobNotFound(t *testing.T) {
+	id := uuid.New()
+	ctx := newContext(t, "GET", "/api/mints/"+id.String(), nil, map[string]string{"jobId": id.String()}, "")
+	if _, err := getMintJob(ctx); !errors.Is(err, repository.ErrMintJobNotFound) {
+		t.Errorf("err = %v, want ErrMintJobNotFound", err)
+	}
+}
+
+func TestMintNFTRequiresMatchingWallet(t *testing.T) {
+	creator := newTestWallet(t)
+	other := newTestWallet(t)
+
+	ctx := newContext(t, "POST", "/api/nfts/mint", map[string]interface{}{
+		"name":      "stolen",
+		"image_url": "https://example.com/stolen.png",
+		"creator":   creator.address,
+	}, nil, other.address)
+
+	if _, err := mintNFT(ctx); !errors.Is(err, middleware.ErrForbidden) || statusCode(err) != http.StatusForbidden {
+		t.Errorf("err = %v (status %d), want ErrForbidden with a 403", err, statusCode(err))
+	}
+}
+
+// signIn requests a challenge for the wallet and returns the signed login
+// request answering it
+func signIn(t *testing.T, wallet *testWallet) map[string]interface{} {
+	t.Helper()
+
+	res, err := createNonce(newContext(t, "POST", "/api/users/"+wallet.address+"/nonce", nil,
+		map[string]string{"address": wallet.address}, ""))
+	if err != nil {
+		t.Fatalf("createNonce: %v", err)
+	}
+	challenge := res.(map[string]interface{})
+
+	message := fmt.Sprintf("%s wants you to sign in with your Ethereum account:\n%s\n\nSign in to NFTGenie\n\n"+
+		"URI: http://%s\nVersion: 1\nChain ID: %d\nNonce: %s\nIssued At: %s",
+		challenge["domain"], wallet.address, challenge["domain"], challenge["chain_id"],
+		challenge["nonce"], challenge["issued_at"])
+	return map[string]interface{}{
+		"address":   wallet.address,
+		"message":   message,
+		"signature": wallet.personalSign(message),
+	}
+}
+
+func TestWalletLogin(t *testing.T) {
+	wallet := newTestWallet(t)
+
+	login := signIn(t, wallet)
+	// Requesting a challenge creates no user
+	if user, err := repository.NewUserRepository().GetByWalletAddress(wallet.address); err != nil || user != nil {
+		t.Fatalf("user after requesting a nonce = %+v, %v, want none", user, err)
+	}
+	// nor invalidates another login in progress
+	signIn(t, wallet)
+
+	res, err := connectWallet(newContext(t, "POST", "/api/users/connect", login, nil, ""))
+	if err != nil {
+		t.Fatalf("connectWallet: %v", err)
+	}
+	tokens := res.(map[string]interface{})["tokens"].(*services.TokenPair)
+	claims, err := services.NewTokenService().Parse(tokens.AccessToken, services.AccessToken)
+	if err != nil {
+		t.Fatalf("Parse access token: %v", err)
+	}
+	if claims.Wallet != wallet.address {
+		t.Errorf("token wallet = %q, want %q", claims.Wallet, wallet.address)
+	}
+	if user, err := repository.NewUserRepository().GetByWalletAddress(wallet.address); err != nil || user == nil {
+		t.Errorf("user after login = %+v, %v, want one", user, err)
+	}
+
+	// The challenge was consumed, so the same signature cannot log in again
+	_, err = connectWallet(newContext(t, "POST", "/api/users/connect", login, nil, ""))
+	if !errors.Is(err, services.ErrNonceMismatch) {
+		t.Errorf("replayed login err = %v, want ErrNonceMismatch", err)
+	}
+
+	// A challenge only answers for the wallet it was issued to
+	other := newTestWallet(t)
+	stolen := signIn(t, wallet)
+	stolen["address"] = other.address
+	_, err = connectWallet(newContext(t, "POST", "/api/users/connect", stolen, nil, ""))
+	if !errors.Is(err, services.ErrNonceMismatch) {
+		t.Errorf("login with another wallet's challenge err = %v, want ErrNonceMismatch", err)
+	}
+}
+
+func TestWalletAddressCase(t *testing.T) {
+	wallet := newTestWallet(t)
+	checksummed := &testWallet{key: wallet.key, address: "0x" + strings.ToUpper(wallet.address[2:])}
+
+	// Minting as the upper-case form and reading as the lower-case one
+	// resolve to one user
+	queueMint(t, checksummed, "cased")
+	res, err := getUserProfile(newContext(t, "GET", "/api/users/"+wallet.address, nil,
+		map[string]string{"address": wallet.address}, ""))
+	if err != nil {
+		t.Fatalf("getUserProfile: %v", err)
+	}
+	if user := res.(map[string]interface{})["user"].(*models.User); user.WalletAddress != wallet.address {
+		t.Errorf("wallet_address = %q, want lowercased %q", user.WalletAddress, wallet.address)
+	}
+
+	var users int
+	if err := database.DB.Get(&users, `SELECT COUNT(*) FROM users WHERE lower(wallet_address) = $1`, wallet.address); err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if users != 1 {
+		t.Errorf("got %d users for the wallet, want 1", users)
+	}
+}
+
+func TestListAndBuyNFT(t *testing.T) {
+	seller := newTestWallet(t)
+	nftID := mint(t, seller, "for-sale")
+
+	res, err := listNFTForSale(newContext(t, "POST", "/api/marketplace/list", map[string]interface{}{
+		"nft_id": nftID.String(),
+		"price":  1.5,
+		"seller": seller.address,
+	}, nil, seller.address))
+	if err != nil {
+		t.Fatalf("listNFTForSale: %v", err)
+	}
+	listingID := res.(map[string]interface{})["listing_id"].(uuid.UUID)
+
+	res, err = getMarketplaceListings(newContext(t, "GET", "/api/marketplace/listings?seller="+seller.address, nil, nil, ""))
+	if err != nil {
+		t.Fatalf("getMarketplaceListings: %v", err)
+	}
+	if listings := res.(map[string]interface{})["data"].([]*models.MarketplaceListing); len(listings) != 1 {
+		t.Errorf("got %d seller listings, want 1", len(listings))
+	}
+
+	// Underpaying is rejected
+	buyer := newTestWallet(t)
+	_, err = buyNFT(newContext(t, "POST", "/api/marketplace/buy", map[string]interface{}{
+		"nft_id":           nftID.String(),
+		"buyer":            buyer.address,
+		"price":            "1.0",
+		"transaction_hash": newTxHash(),
+	}, nil, buyer.address))
+	if err == nil {
+		t.Fatal("expected underpriced purchase to fail")
+	}
+
+	// The payment hash is required, well formed and not already recorded
+	minted, err := repository.NewTransactionRepository().GetByNFT(nftID)
+	if err != nil || len(minted) != 1 || minted[0].TransactionHash == nil {
+		t.Fatalf("GetByNFT = %+v, %v, want the mint transaction", minted, err)
+	}
+	for _, hash := range []string{"", "0xfeed", newTxHash()[:65] + "z", "0x" + strings.ToUpper((*minted[0].TransactionHash)[2:])} {
+		_, err = buyNFT(newContext(t, "POST", "/api/marketplace/buy", map[string]interface{}{
+			"nft_id":           nftID.String(),
+			"buyer":            buyer.address,
+			"price":            "1.5",
+			"transaction_hash": hash,
+		}, nil, buyer.address))
+		if err == nil {
+			t.Errorf("purchase with transaction_hash %q succeeded", hash)
+		}
+	}
+	if !errors.Is(err, repository.ErrTransactionHashUsed) {
+		t.Errorf("purchase with a recorded hash: %v, want ErrTransactionHashUsed", err)
+	}
+
+	// Two concurrent buyers: exactly one succeeds
+	buyers := []*testWallet{buyer, newTestWallet(t)}
+	var wg sync.WaitGroup
+	errs := make([]error, len(buyers))
+	for i, b := range buyers {
+		wg.Add(1)
+		go func(i int, b *testWallet) {
+			defer wg.Done()
+			_, errs[i] = buyNFT(newContext(t, "POST", "/api/marketplace/buy", map[string]interface{}{
+				"nft_id":           nftID.String(),
+				"buyer":            b.address,
+				"price":            "1.5",
+				"transaction_hash": newTxHash(),
+			}, nil, b.address))
+		}(i, b)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		if err == nil {
+			if winner != -1 {
+				t.Fatal("both concurrent buyers succeeded")
+			}
+			winner = i
+		} else if !errors.Is(err, repository.ErrListingNotFound) {
+			t.Errorf("losing buyer err = %v, want ErrListingNotFound", err)
+		}
+	}
+	if winner == -1 {
+		t.Fatalf("no buyer succeeded: %v", errs)
+	}
+
+	listing, err := repository.NewMarketplaceRepository().GetByID(listingID)
+	if err != nil {
+		t.Fatalf("GetByID listing: %v", err)
+	}
+	if listing.Status != "sold" || listing.BuyerID == nil {
+		t.Errorf("listing = %+v, want sold with buyer", listing)
+	}
+	owner, err := repository.NewUserRepository().GetByWalletAddress(buyers[winner].address)
+	if err != nil {
+		t.Fatalf("GetByWalletAddress: %v", err)
+	}
+	if listing.NFT.OwnerID != owner.ID {
+		t.Errorf("NFT owner = %s, want winning buyer %s", listing.NFT.OwnerID, owner.ID)
+	}
+
+	history, err := repository.NewTransactionRepository().GetByNFT(nftID)
+	if err != nil {
+		t.Fatalf("GetByNFT: %v", err)
+	}
+	if len(history) != 2 || history[0].Type != "purchase" || history[1].Type != "mint" {
+		t.Errorf("transactions = %+v, want the mint and one purchase", history)
+	}
+}
+
+func TestGetUserNFTs(t *testing.T) {
+	wallet := newTestWallet(t)
+	mint(t, wallet, "mine-1")
+	mint(t, wallet, "mine-2")
+
+	res, err := getUserNFTs(newContext(t, "GET", "/api/nfts/user/"+wallet.address, nil,
+		map[string]string{"address": wallet.address}, ""))
+	if err != nil {
+		t.Fatalf("getUserNFTs: %v", err)
+	}
+	if got := len(res.([]*models.NFT)); got != 2 {
+		t.Errorf("got %d NFTs, want 2", got)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	creator := newTestWallet(t)
+
+	res, err := createCollection(newContext(t, "POST", "/api/collections", map[string]interface{}{
+		"name":        "Lamps",
+		"description": "Things that glow",
+		"creator":     creator.address,
+	}, nil, creator.address))
+	if err != nil {
+		t.Fatalf("createCollection: %v", err)
+	}
+	collection := res.(map[string]interface{})["collection"].(*models.Collection)
+	id := collection.ID.String()
+
+	// Only the creator may edit the collection or mint into it
+	other := newTestWallet(t)
+	mint(t, other, "outsider")
+	_, err = updateCollection(newContext(t, "PUT", "/api/collections/"+id, map[string]interface{}{
+		"name":    "Mine now",
+		"creator": other.address,
+	}, map[string]string{"id": id}, other.address))
+	if statusCode(err) != http.StatusForbidden {
+		t.Errorf("update by another user: %v, want a 403", err)
+	}
+	_, err = mintNFT(newContext(t, "POST", "/api/nfts/mint", map[string]interface{}{
+		"name":          "intruder",
+		"image_url":     "https://example.com/intruder.png",
+		"creator":       other.address,
+		"collection_id": id,
+	}, nil, other.address))
+	if statusCode(err) != http.StatusForbidden {
+		t.Errorf("minting into another user's collection: %v, want a 403", err)
+	}
+
+	if _, err := updateCollection(newContext(t, "PUT", "/api/collections/"+id, map[string]interface{}{
+		"name":    "Glowing lamps",
+		"creator": creator.address,
+	}, map[string]string{"id": id}, creator.address)); err != nil {
+		t.Fatalf("updateCollection: %v", err)
+	}
+
+	for _, name := range []string{"desk-lamp", "floor-lamp", "lava-lamp"} {
+		if _, err := mintNFT(newContext(t, "POST", "/api/nfts/mint", map[string]interface{}{
+			"name":          name,
+			"image_url":     "https://example.com/" + name + ".png",
+			"creator":       creator.address,
+			"collection_id": id,
+		}, nil, creator.address)); err != nil {
+			t.Fatalf("mintNFT: %v", err)
+		}
+	}
+	drainMintJobs(t)
+
+	res, err = getCollection(newContext(t, "GET", "/api/collections/"+id, nil, map[string]string{"id": id}, ""))
+	if err != nil {
+		t.Fatalf("getCollection: %v", err)
+	}
+	if got := res.(*models.Collection); got.Name != "Glowing lamps" || *got.Description != "Things that glow" {
+		t.Errorf("collection = %+v, want renamed with its description kept", got)
+	}
+
+	nftsPage := func(query string) ([]*models.NFT, map[string]interface{}) {
+		t.Helper()
+		res, err := getCollectionNFTs(newContext(t, "GET", "/api/collections/"+id+"/nfts?"+query, nil, map[string]string{"id": id}, ""))
+		if err != nil {
+			t.Fatalf("getCollectionNFTs(%s): %v", query, err)
+		}
+		page := res.(map[string]interface{})
+		return page["data"].([]*models.NFT), page
+	}
+
+	first, page := nftsPage("limit=2")
+	if len(first) != 2 || page["prev_cursor"] != nil || page["next_cursor"] == nil {
+		t.Fatalf("first page = %v, want 2 NFTs and only a next cursor", page)
+	}
+	last, page := nftsPage("limit=2&cursor=" + page["next_cursor"].(string))
+	if len(last) != 1 || page["next_cursor"] != nil || page["prev_cursor"] == nil {
+		t.Fatalf("last page = %v, want 1 NFT and only a prev cursor", page)
+	}
+	if last[0].ID == first[0].ID || last[0].ID == first[1].ID {
+		t.Errorf("pages overlap")
+	}
+	back, _ := nftsPage("limit=2&cursor=" + page["prev_cursor"].(string))
+	if len(back) != 2 || back[0].ID != first[0].ID || back[1].ID != first[1].ID {
+		t.Errorf("prev page = %v, want the first page again", back)
+	}
+
+	res, err = getCollections(newContext(t, "GET", "/api/collections?creator="+creator.address, nil, nil, ""))
+	if err != nil {
+		t.Fatalf("getCollections: %v", err)
+	}
+	if collections := res.(map[string]interface{})["data"].([]*models.Collection); len(collections) != 1 {
+		t.Errorf("got %d creator collections, want 1", len(collections))
+	}
+
+	missing := uuid.New().String()
+	_, err = getCollection(newContext(t, "GET", "/api/collections/"+missing, nil, map[string]string{"id": missing}, ""))
+	if !errors.Is(err, repository.ErrCollectionNotFound) {
+		t.Errorf("err = %v, want ErrCollectionNotFound", err)
+	}
+}
+
+func TestSearchNFTs(t *testing.T) {
+	wallet := newTestWallet(t)
+	lampID := mint(t, wallet, "quasar-lantern")
+	mint(t, wallet, "quasar-teapot")
+	mint(t, wallet, "nebula-lantern")
+
+	if _, err := database.DB.Exec(`
+		UPDATE nfts SET description = 'A lantern that hums', attributes = '{"rarity": "rare", "power": 100}'
+		WHERE id = $1`, lampID); err != nil {
+		t.Fatalf("set attributes: %v", err)
+	}
+
+	search := func(query string) map[string]interface{} {
+		t.Helper()
+		res, err := searchNFTs(newContext(t, "GET", "/api/nfts/search?"+query, nil, nil, ""))
+		if err != nil {
+			t.Fatalf("searchNFTs(%s): %v", query, err)
+		}
+		return res.(map[string]interface{})
+	}
+
+	res := search("q=lantern&creator=" + wallet.address)
+	hits := res["results"].([]*repository.NFTSearchHit)
+	if res["total"] != 2 || len(hits) != 2 {
+		t.Fatalf("results = %v, want the two lanterns", res)
+	}
+	// The lantern matching in both name and description ranks first
+	if hits[0].NFT.ID != lampID || hits[0].Rank <= hits[1].Rank {
+		t.Errorf("first hit = %s (rank %v), want %s", hits[0].NFT.ID, hits[0].Rank, lampID)
+	}
+	if got := hits[0].Highlights["description"]; got != "A <mark>lantern</mark> that hums" {
+		t.Errorf("description highlight = %q", got)
+	}
+
+	facets := res["facets"].(repository.SearchFacets)
+	if len(facets.Creators) != 1 || facets.Creators[0].Value != wallet.address || facets.Creators[0].Count != 2 {
+		t.Errorf("creator facets = %+v, want the wallet with 2 NFTs", facets.Creators)
+	}
+	if len(facets.Tags) != 2 || facets.Tags[0].Count != 2 {
+		t.Errorf("tag facets = %+v, want art and genie with 2 NFTs each", facets.Tags)
+	}
+
+	res = search("creator=" + wallet.address + "&attributes=" + url.QueryEscape(`{"power":100}`))
+	if hits := res["results"].([]*repository.NFTSearchHit); len(hits) != 1 || hits[0].NFT.ID != lampID {
+		t.Errorf("attribute results = %v, want the rare lantern", res)
+	}
+
+	res = search("q=lantern&creator=" + newTestWallet(t).address)
+	if res["total"] != 0 {
+		t.Errorf("unknown creator total = %v, want 0", res["total"])
+	}
+
+	if _, err := searchNFTs(newContext(t, "GET", "/api/nfts/search?attributes=rare", nil, nil, "")); err == nil {
+		t.Error("expected invalid attributes to fail")
+	}
+	for _, query := range []string{"limit=ten", "limit=0", "offset=-1", "offset=5x"} {
+		if _, err := searchNFTs(newContext(t, "GET", "/api/nfts/search?q=lantern&"+query, nil, nil, "")); err == nil {
+			t.Errorf("expected %s to fail", query)
+		}
+	}
+}
+
+func TestListCursors(t *testing.T) {
+	wallet := newTestWallet(t)
+	nftID := mint(t, wallet, "paged")
+
+	// Every list endpoint pages through the same envelope
+	res, err := getAllNFTs(newContext(t, "GET", "/api/nfts?limit=1", nil, nil, ""))
+	if err != nil {
+		t.Fatalf("getAllNFTs: %v", err)
+	}
+	page := res.(map[string]interface{})
+	if nfts := page["data"].([]*models.NFT); len(nfts) != 1 || page["limit"] != 1 {
+		t.Errorf("page = %v, want one NFT", page)
+	}
+	next := page["next_cursor"].(string)
+
+	// Cursors are bound to their list and can't be edited
+	if _, err := getUsers(newContext(t, "GET", "/api/users?cursor="+next, nil, nil, "")); !errors.Is(err, services.ErrInvalidCursor) {
+		t.Errorf("NFT cursor on users: err = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := getAllNFTs(newContext(t, "GET", "/api/nfts?cursor=x"+next, nil, nil, "")); !errors.Is(err, services.ErrInvalidCursor) {
+		t.Errorf("tampered cursor: err = %v, want ErrInvalidCursor", err)
+	}
+
+	res, err = getUsers(newContext(t, "GET", "/api/users?limit=1000", nil, nil, ""))
+	if err != nil {
+		t.Fatalf("getUsers: %v", err)
+	}
+	if limit := res.(map[string]interface{})["limit"]; limit != repository.MaxPageSize {
+		t.Errorf("limit = %v, want capped at %d", limit, repository.MaxPageSize)
+	}
+
+	res, err = getTransactions(newContext(t, "GET", "/api/transactions?nft_id="+nftID.String(), nil, nil, ""))
+	if err != nil {
+		t.Fatalf("getTransactions: %v", err)
+	}
+	txs := res.(map[string]interface{})["data"].([]*models.Transaction)
+	if len(txs) != 1 || txs[0].Type != "mint" {
+		t.Errorf("transactions = %+v, want the mint", txs)
+	}
+
+	res, err = getTransactions(newContext(t, "GET", "/api/transactions?user="+wallet.address, nil, nil, ""))
+	if err != nil {
+		t.Fatalf("getTransactions: %v", err)
+	}
+	if txs := res.(map[string]interface{})["data"].([]*models.Transaction); len(txs) != 1 {
+		t.Errorf("got %d transactions for the wallet, want 1", len(txs))
+	}
+}
+
+func TestRecommendations(t *testing.T) {
+	creator := newTestWallet(t)
+	liked := mint(t, creator, "affine-1")
+	sibling := mint(t, creator, "affine-2")
+	mint(t, newTestWallet(t), "stranger")
+
+	collector := newUser(t)
+	if err := repository.NewInteractionRepository().Record(models.NewUserInteraction(collector.ID, liked, "like", 1)); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	recommend := func() []*models.Recommendation {
+		t.Helper()
+		res, err := getRecommendations(newContext(t, "GET", "/api/recommendations/"+collector.WalletAddress, nil,
+			map[string]string{"userId": collector.WalletAddress}, ""))
+		if err != nil {
+			t.Fatalf("getRecommendations: %v", err)
+		}
+		return res.([]*models.Recommendation)
+	}
+
+	recs := recommend()
+	if len(recs) < 3 {
+		t.Fatalf("got %d recommendations, want at least 3", len(recs))
+	}
+	// Both of the creator's NFTs share the liked tags and the creator
+	for _, rec := range recs[:2] {
+		if (rec.NFTID != liked && rec.NFTID != sibling) || rec.Rule != models.RuleCreatorAffinity || rec.Score < 5 {
+			t.Errorf("recommendation = %+v, want the creator's NFT scored by creator affinity", rec)
+		}
+		if rec.Reason == nil || rec.AlgorithmVersion != services.SQLAlgorithmVersion || rec.ExpiresAt == nil {
+			t.Errorf("recommendation = %+v, want a reason, version and expiry", rec)
+		}
+	}
+	if recs[2].Rule != models.RuleTagOverlap || recs[2].Score >= recs[1].Score {
+		t.Errorf("third recommendation = %+v, want a lower tag overlap match", recs[2])
+	}
+
+	// Later requests are served from the cache until it expires
+	newest := mint(t, creator, "affine-3")
+	contains := func(recs []*models.Recommendation, id uuid.UUID) bool {
+		for _, rec := range recs {
+			if rec.NFTID == id {
+				return true
+			}
+		}
+		return false
+	}
+	cached := recommend()
+	if len(cached) != len(recs) || cached[0].ID != recs[0].ID || contains(cached, newest) {
+		t.Errorf("second request recomputed recommendations, want the cached set")
+	}
+
+	if _, err := database.DB.Exec(`UPDATE recommendations SET expires_at = NOW() - INTERVAL '1 second' WHERE user_id = $1`, collector.ID); err != nil {
+		t.Fatalf("expire cache: %v", err)
+	}
+	if !contains(recommend(), newest) {
+		t.Error("expired cache was served, want the new NFT recommended")
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	favourite := newTestWallet(t)
+	pick := mint(t, favourite, "pref-pick")
+	excluded := mint(t, favourite, "pref-excluded")
+	pricey := mint(t, favourite, "pref-pricey")
+	bargain := mint(t, favourite, "pref-bargain")
+	category := mint(t, newTestWallet(t), "pref-category")
+
+	// Unique tags keep other tests' NFTs out of the preference rules
+	suffix := uuid.NewString()[:8]
+	music, hidden := "music-"+suffix, "hidden-"+suffix
+	for id, tags := range map[uuid.UUID][]string{excluded: {"art", hidden}, category: {"art", music}} {
+		if _, err := database.DB.Exec(`UPDATE nfts SET tags = $1 WHERE id = $2`, pq.StringArray(tags), id); err != nil {
+			t.Fatalf("set tags: %v", err)
+		}
+	}
+	for id, price := range map[uuid.UUID]float64{pricey: 10, bargain: 3} {
+		if _, err := listNFTForSale(newContext(t, "POST", "/api/marketplace/list", map[string]interface{}{
+			"nft_id": id.String(),
+			"price":  price,
+			"seller": favourite.address,
+		}, nil, favourite.address)); err != nil {
+			t.Fatalf("listNFTForSale: %v", err)
+		}
+	}
+
+	fan := newUser(t)
+	params := map[string]string{"address": fan.WalletAddress}
+	put := func(body map[string]interface{}, wallet string) (interface{}, error) {
+		return updateUserPreferences(newContext(t, "PUT", "/api/users/"+fan.WalletAddress+"/preferences", body, params, wallet))
+	}
+
+	for _, priceRange := range []models.PriceRange{{Min: 5, Max: 1}, {Min: -1, Max: 1}, {Min: 0, Max: 0}} {
+		if _, err := put(map[string]interface{}{"preferred_price_range": priceRange}, fan.WalletAddress); err == nil {
+			t.Errorf("price range %+v accepted", priceRange)
+		}
+	}
+	if _, err := put(map[string]interface{}{"preferred_creators": []string{newTestWallet(t).address}}, fan.WalletAddress); err == nil {
+		t.Error("unknown preferred creator accepted")
+	}
+	if _, err := put(map[string]interface{}{}, newTestWallet(t).address); !errors.Is(err, middleware.ErrForbidden) {
+		t.Errorf("updating another user's preferences err = %v, want ErrForbidden", err)
+	}
+
+	// Recommendations cached before the preferences are dropped on save
+	recommend := func() []*models.Recommendation {
+		t.Helper()
+		res, err := getRecommendations(newContext(t, "GET", "/api/recommendations/"+fan.WalletAddress, nil,
+			map[string]string{"userId": fan.WalletAddress}, ""))
+		if err != nil {
+			t.Fatalf("getRecommendations: %v", err)
+		}
+		return res.([]*models.Recommendation)
+	}
+	recommend()
+
+	res, err := put(map[string]interface{}{
+		"preferred_categories":  []string{music, " " + music},
+		"preferred_price_range": models.PriceRange{Min: 1, Max: 5},
+		"preferred_creators":    []string{favourite.address},
+		"excluded_tags":         []string{hidden},
+	}, fan.WalletAddress)
+	if err != nil {
+		t.Fatalf("updateUserPreferences: %v", err)
+	}
+	saved := res.(*models.UserPreferences)
+	if len(saved.PreferredCategories) != 1 || len(saved.PreferredCreators) != 1 || saved.PreferredCreators[0] == favourite.address {
+		t.Errorf("saved preferences = %+v, want deduped categories and creators stored by ID", saved)
+	}
+
+	res, err = getUserPreferences(newContext(t, "GET", "/api/users/"+fan.WalletAddress+"/preferences", nil, params, fan.WalletAddress))
+	if err != nil {
+		t.Fatalf("getUserPreferences: %v", err)
+	}
+	var priceRange models.PriceRange
+	if err := json.Unmarshal(res.(*models.UserPreferences).PreferredPriceRange, &priceRange); err != nil || priceRange.Max != 5 {
+		t.Errorf("stored price range = %s, want max 5", res.(*models.UserPreferences).PreferredPriceRange)
+	}
+
+	rules := map[uuid.UUID]string{}
+	for _, rec := range recommend() {
+		rules[rec.NFTID] = rec.Rule
+	}
+	for id, want := range map[uuid.UUID]string{
+		pick:     models.RulePreferredCreator,
+		bargain:  models.RulePreferredCreator,
+		category: models.RulePreferredCategory,
+	} {
+		if rules[id] != want {
+			t.Errorf("NFT %s recommended by %q, want %q", id, rules[id], want)
+		}
+	}
+	if _, ok := rules[excluded]; ok {
+		t.Error("NFT with an excluded tag was recommended")
+	}
+	if _, ok := rules[pricey]; ok {
+		t.Error("NFT listed above the price range was recommended")
+	}
+}
+
+// useAIEngine points the recommender at the engine at url until the test ends
+func useAIEngine(t *testing.T, url string) {
+	t.Helper()
+
+	t.Setenv("AI_ENGINE_URL", url)
+	t.Setenv("AI_ENGINE_MAX_RETRIES", "0")
+	prevEngine, prevRecommender := aiEngine, recommender
+	aiEngine = services.NewAIEngineClient()
+	recommender = services.NewRecommender(aiEngine)
+	t.Cleanup(func() {
+		aiEngine, recommender = prevEngine, prevRecommender
+	})
+}
+
+func TestRecommendationsFromAIEngine(t *testing.T) {
+	creator := newTestWallet(t)
+	first := mint(t, creator, "engine-1")
+	second := mint(t, creator, "engine-2")
+
+	collector := newUser(t)
+
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `{"status": "healthy", "model_version": "1.0.0", "cache_status": "healthy"}`)
+		case "/recommend":
+			var req services.AIRecommendRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			// The engine keys users by their ID, not their wallet
+			if req.UserID != collector.ID.String() {
+				http.Error(w, "unknown user", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `[
+				{"nft_id": %q, "score": 0.9, "reason": "Matches your historical preferences"},
+				{"nft_id": %q, "score": 0.7, "reason": "Deleted NFT"},
+				{"nft_id": %q, "score": 0.5, "reason": "Trending"}
+			]`, second, uuid.New(), first)
+		case "/explain/" + collector.ID.String() + "/" + first.String():
+			fmt.Fprintf(w, `{"user_id": %q, "nft_id": %q, "explanation": {"factors": [
+				{"type": "trending", "score": 0.7, "description": "Currently trending in the marketplace"}
+			]}}`, collector.ID, first)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+	useAIEngine(t, engine.URL)
+
+	res, err := getRecommendations(newContext(t, "GET", "/api/recommendations/"+collector.ID.String(), nil,
+		map[string]string{"userId": collector.ID.String()}, ""))
+	if err != nil {
+		t.Fatalf("getRecommendations: %v", err)
+	}
+	recs := res.([]*models.Recommendation)
+	if len(recs) != 2 || recs[0].NFTID != second || recs[1].NFTID != first {
+		t.Fatalf("recommendations = %+v, want the engine's order without the unknown NFT", recs)
+	}
+	if recs[0].Rule != models.RuleAIEngine || recs[0].AlgorithmVersion != "ai-1.0.0@untrained" || recs[0].Score != 0.9 || recs[0].NFT == nil {
+		t.Errorf("recommendation = %+v, want the engine's score and version with the NFT", recs[0])
+	}
+
+	res, err = explainRecommendation(newContext(t, "GET", "/api/recommendations/"+collector.WalletAddress+"/explain/"+first.String(), nil,
+		map[string]string{"userId": collector.WalletAddress, "nftId": first.String()}, ""))
+	if err != nil {
+		t.Fatalf("explainRecommendation: %v", err)
+	}
+	if factors := res.(*services.AIExplanation).Explanation.Factors; len(factors) != 1 || factors[0].Type != "trending" {
+		t.Errorf("factors = %+v, want the engine's trending factor", factors)
+	}
+
+	// A down engine falls back to the SQL rules
+	engine.Close()
+	useAIEngine(t, engine.URL)
+	res, err = getRecommendations(newContext(t, "GET", "/api/recommendations/"+collector.ID.String(), nil,
+		map[string]string{"userId": collector.ID.String()}, ""))
+	if err != nil {
+		t.Fatalf("getRecommendations with engine down: %v", err)
+	}
+	for _, rec := range res.([]*models.Recommendation) {
+		if rec.AlgorithmVersion != services.SQLAlgorithmVersion {
+			t.Errorf("recommendation = %+v, want a SQL fallback", rec)
+		}
+	}
+
+	if _, err := explainRecommendation(newContext(t, "GET", "/api/recommendations/"+collector.WalletAddress+"/explain/"+first.String(), nil,
+		map[string]string{"userId": collector.WalletAddress, "nftId": first.String()}, "")); err == nil {
+		t.Error("expected explain to fail with the engine down")
+	}
+}
+
+func TestTrainRecommendationModel(t *testing.T) {
+	requester := newTestWallet(t)
+	sampleID := mint(t, requester, "training-sample")
+
+	// The fake engine reports a new last_trained once /train is called
+	var mu sync.Mutex
+	var lastTrained *string
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			json.NewEncoder(w).Encode(services.AIEngineHealth{Status: "healthy", ModelVersion: "1.0.0", LastTrained: lastTrained})
+		case "/train":
+			trained := "2026-10-16T12:00:00.000001"
+			lastTrained = &trained
+			fmt.Fprint(w, `{"status": "training", "message": "Model training initiated in background"}`)
+		case "/recommend":
+			fmt.Fprintf(w, `[{"nft_id": %q, "score": 0.8, "reason": "Matches your historical preferences"}]`, sampleID)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer engine.Close()
+	useAIEngine(t, engine.URL)
+	datasetDir := t.TempDir()
+	t.Setenv("TRAINING_DATASET_DIR", datasetDir)
+	worker := workers.NewTrainingWorker(aiEngine)
+
+	// A snapshot past the retention is deleted on the next export
+	stale := filepath.Join(datasetDir, uuid.NewString()+".json")
+	if err := os.WriteFile(stale, []byte("{}"), 0o644); err != nil {
+		t.Fatalf("write stale dataset: %v", err)
+	}
+	old := time.Now().Add(-worker.DatasetRetention - time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("age stale dataset: %v", err)
+	}
+
+	train := func() uuid.UUID {
+		t.Helper()
+		res, err := trainRecommendationModel(newContext(t, "POST", "/api/recommendations/train", nil, nil, requester.address))
+		if err != nil {
+			t.Fatalf("trainRecommendationModel: %v", err)
+		}
+		return res.(map[string]interface{})["job_id"].(uuid.UUID)
+	}
+	jobID := train()
+	if again := train(); again != jobID {
+		t.Errorf("second request queued job %s, want it to join %s", again, jobID)
+	}
+
+	if _, err := trainRecommendationModel(newContext(t, "POST", "/api/recommendations/train", nil, nil, "")); err == nil {
+		t.Error("expected an anonymous training request to fail")
+	}
+
+	getTraining := func() *models.TrainingJob {
+		t.Helper()
+		res, err := getTrainingJob(newContext(t, "GET", "/api/recommendations/train/"+jobID.String(), nil,
+			map[string]string{"jobId": jobID.String()}, ""))
+		if err != nil {
+			t.Fatalf("getTrainingJob: %v", err)
+		}
+		return res.(*models.TrainingJob)
+	}
+
+	// The first run exports the dataset and triggers the engine
+	if err := worker.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	job := getTraining()
+	if job.Status != models.TrainingJobTraining || job.DatasetPath == nil || job.NFTCount == nil || *job.NFTCount == 0 {
+		t.Fatalf("job = %+v, want training with an exported dataset", job)
+	}
+	data, err := os.ReadFile(*job.DatasetPath)
+	if err != nil {
+		t.Fatalf("read dataset: %v", err)
+	}
+	var dataset models.TrainingDataset
+	if err := json.Unmarshal(data, &dataset); err != nil || len(dataset.NFTs) != *job.NFTCount {
+		t.Errorf("dataset holds %d NFTs (err %v), want %d", len(dataset.NFTs), err, *job.NFTCount)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale dataset still there (stat err %v)", err)
+	}
+
+	// The next run sees the newer model and registers it
+	if err := worker.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	job = getTraining()
+	const version = "ai-1.0.0@20261016T120000"
+	if job.Status != models.TrainingJobCompleted || job.ModelVersion == nil || *job.ModelVersion != version {
+		t.Fatalf("job = %+v, want completed with model %s", job, version)
+	}
+	model, err := repository.NewModelVersionRepository().GetByVersion(version)
+	if err != nil {
+		t.Fatalf("GetByVersion: %v", err)
+	}
+	if model.Source != models.ModelSourceAIEngine || model.TrainingJobID == nil || *model.TrainingJobID != jobID {
+		t.Errorf("model = %+v, want the ai-engine model of the job", model)
+	}
+
+	// Recommendations cached from now on name the trained model
+	aiEngine.HealthTTL = 0
+	user, err := repository.NewUserRepository().GetByWalletAddress(requester.address)
+	if err != nil {
+		t.Fatalf("GetByWalletAddress: %v", err)
+	}
+	recs, err := recommender.Recommend(context.Background(), user, 5)
+	if err != nil {
+		t.Fatalf("Recommend: %v", err)
+	}
+	if len(recs) != 1 || recs[0].AlgorithmVersion != version {
+		t.Errorf("recommendations = %+v, want one from model %s", recs, version)
+	}
+}
+
+// newUser creates a user for a fresh wallet
+func newUser(t *testing.T) *models.User {
+	t.Helper()
+
+	user := models.NewUser(newTestWallet(t).address)
+	if err := repository.NewUserRepository().Create(user); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	return user
+}
+
+func TestInteractions(t *testing.T) {
+	nftID := mint(t, newTestWallet(t), "liked")
+	fan := newUser(t)
+	params := map[string]string{"id": nftID.String()}
+
+	interactions := func(interactionType string) []models.UserInteraction {
+		t.Helper()
+		var rows []models.UserInteraction
+		err := database.DB.Select(&rows, `SELECT * FROM user_interactions WHERE nft_id = $1 AND interaction_type = $2`, nftID, interactionType)
+		if err != nil {
+			t.Fatalf("select interactions: %v", err)
+		}
+		return rows
+	}
+	storedLikes := func() int {
+		t.Helper()
+		var likes int
+		if err := database.DB.Get(&likes, `SELECT likes FROM nfts WHERE id = $1`, nftID); err != nil {
+			t.Fatalf("select likes: %v", err)
+		}
+		return likes
+	}
+
+	// Liking and unliking twice counts once
+	for i := 0; i < 2; i++ {
+		res, err := likeNFT(newContext(t, "POST", "/api/nfts/"+nftID.String()+"/like", nil, params, fan.WalletAddress))
+		if err != nil {
+			t.Fatalf("likeNFT: %v", err)
+		}
+		if likes := res.(map[string]interface{})["likes"]; likes != 1 {
+			t.Errorf("likes = %v, want 1", likes)
+		}
+	}
+	if rows := interactions(models.InteractionLike); storedLikes() != 1 || len(rows) != 1 || rows[0].InteractionValue != models.InteractionWeightLike {
+		t.Errorf("stored likes = %d with interactions %+v, want one weighted like", storedLikes(), rows)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := unlikeNFT(newContext(t, "DELETE", "/api/nfts/"+nftID.String()+"/like", nil, params, fan.WalletAddress)); err != nil {
+			t.Fatalf("unlikeNFT: %v", err)
+		}
+	}
+	if storedLikes() != 0 || len(interactions(models.InteractionLike)) != 0 {
+		t.Errorf("stored likes = %d after unliking, want 0 and no like interaction", storedLikes())
+	}
+
+	// Concurrent fans each count once
+	fans := make([]*models.User, 5)
+	for i := range fans {
+		fans[i] = newUser(t)
+	}
+	var wg sync.WaitGroup
+	for _, f := range fans {
+		wg.Add(1)
+		go func(f *models.User) {
+			defer wg.Done()
+			if _, err := likeNFT(newContext(t, "POST", "/api/nfts/"+nftID.String()+"/like", nil, params, f.WalletAddress)); err != nil {
+				t.Errorf("likeNFT: %v", err)
+			}
+		}(f)
+	}
+	wg.Wait()
+	if storedLikes() != len(fans) {
+		t.Errorf("stored likes = %d, want %d", storedLikes(), len(fans))
+	}
+
+	// Repeat views within the dedupe window count once
+	for i := 0; i < 3; i++ {
+		res, err := recordView(newContext(t, "POST", "/api/nfts/"+nftID.String()+"/view", nil, params, fan.WalletAddress))
+		if err != nil {
+			t.Fatalf("recordView: %v", err)
+		}
+		if counted := res.(map[string]interface{})["counted"]; counted != (i == 0) {
+			t.Errorf("view %d counted = %v", i, counted)
+		}
+	}
+	if err := viewPipeline.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if rows := interactions(models.InteractionView); len(rows) != 1 || rows[0].InteractionValue != models.InteractionWeightView {
+		t.Errorf("view interactions = %+v, want one", rows)
+	}
+
+	if _, err := recordShare(newContext(t, "POST", "/api/nfts/"+nftID.String()+"/share", nil, params, fan.WalletAddress)); err != nil {
+		t.Fatalf("recordShare: %v", err)
+	}
+	if rows := interactions(models.InteractionShare); len(rows) != 1 || rows[0].InteractionValue != models.InteractionWeightShare {
+		t.Errorf("share interactions = %+v, want one", rows)
+	}
+
+	missing := map[string]string{"id": uuid.NewString()}
+	if _, err := likeNFT(newContext(t, "POST", "/api/nfts/x/like", nil, missing, fan.WalletAddress)); !errors.Is(err, repository.ErrNFTNotFound) {
+		t.Errorf("liking a missing NFT err = %v, want ErrNFTNotFound", err)
+	}
+	if _, err := recordView(newContext(t, "POST", "/api/nfts/x/view", nil, missing, fan.WalletAddress)); !errors.Is(err, repository.ErrNFTNotFound) {
+		t.Errorf("viewing a missing NFT err = %v, want ErrNFTNotFound", err)
+	}
+	if _, err := likeNFT(newContext(t, "POST", "/api/nfts/"+nftID.String()+"/like", nil, params, "")); !errors.Is(err, middleware.ErrUnauthenticated) || statusCode(err) != http.StatusUnauthorized {
+		t.Errorf("anonymous like err = %v (status %d), want ErrUnauthenticated with a 401", err, statusCode(err))
+	}
+}
+
+// viewerContext is a GET /api/nfts/{id} request from a client address
+func viewerContext(t *testing.T, nftID uuid.UUID, remoteAddr, forwardedFor string) *gofr.Context {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/api/nfts/"+nftID.String(), nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": nftID.String()})
+	return gofr.NewContext(nil, request.NewHTTPRequest(req), testApp)
+}
+
+func TestViewPipeline(t *testing.T) {
+	nftID := mint(t, newTestWallet(t), "viewed")
+	params := map[string]string{"id": nftID.String()}
+	fan := newUser(t)
+
+	storedViews := func() int {
+		t.Helper()
+		var views int
+		if err := database.DB.Get(&views, `SELECT views FROM nfts WHERE id = $1`, nftID); err != nil {
+			t.Fatalf("select views: %v", err)
+		}
+		return views
+	}
+	get := func(ctx *gofr.Context) {
+		t.Helper()
+		if _, err := getNFTByID(ctx); err != nil {
+			t.Fatalf("getNFTByID: %v", err)
+		}
+	}
+
+	// Two clients and a signed-in fan, each viewing twice. X-Forwarded-For is
+	// ignored unless the proxy is trusted, so it can't fake new viewers.
+	for i := 0; i < 2; i++ {
+		get(viewerContext(t, nftID, "198.51.100.1:4000", ""))
+		get(viewerContext(t, nftID, "198.51.100.1:4001", fmt.Sprintf("192.0.2.%d", i)))
+		get(viewerContext(t, nftID, "10.0.0.1:5000", "203.0.113.7, 10.0.0.1"))
+		get(newContext(t, "GET", "/api/nfts/"+nftID.String(), nil, params, fan.WalletAddress))
+	}
+
+	// Reads no longer write; views land with the flush
+	if views := storedViews(); views != 0 {
+		t.Errorf("views before flush = %d, want 0", views)
+	}
+	if err := viewPipeline.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if views := storedViews(); views != 3 {
+		t.Errorf("views after flush = %d, want 3", views)
+	}
+
+	var rows []models.UserInteraction
+	err := database.DB.Select(&rows, `SELECT * FROM user_interactions WHERE nft_id = $1 AND interaction_type = $2`, nftID, models.InteractionView)
+	if err != nil {
+		t.Fatalf("select interactions: %v", err)
+	}
+	if len(rows) != 1 || rows[0].UserID != fan.ID {
+		t.Errorf("view interactions = %+v, want one by the fan", rows)
+	}
+
+	// Nothing is left to write twice
+	if err := viewPipeline.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if views := storedViews(); views != 3 {
+		t.Errorf("views after second flush = %d, want 3", views)
+	}
+
+	// Behind a trusted proxy each forwarded client is a viewer of its own
+	rateLimitConfig.TrustProxy = true
+	t.Cleanup(func() { rateLimitConfig.TrustProxy = false })
+	get(viewerContext(t, nftID, "10.0.0.1:5000", "203.0.113.7"))
+	get(viewerContext(t, nftID, "10.0.0.1:5001", "203.0.113.8"))
+	get(viewerContext(t, nftID, "10.0.0.1:5002", "203.0.113.8"))
+
+	// A client rotating spoofed entries before the proxy's is still one viewer
+	for i := 0; i < 3; i++ {
+		get(viewerContext(t, nftID, "10.0.0.1:5003", fmt.Sprintf("192.0.2.%d, 203.0.113.8", i)))
+	}
+	if err := viewPipeline.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if views := storedViews(); views != 5 {
+		t.Errorf("views through the trusted proxy = %d, want 5", views)
+	}
+}
+
+func TestAnalytics(t *testing.T) {
+	stats := func() map[string]interface{} {
+		t.Helper()
+		res, err := getMarketplaceStats(newContext(t, "GET", "/api/analytics/stats", nil, nil, ""))
+		if err != nil {
+			t.Fatalf("getMarketplaceStats: %v", err)
+		}
+		return res.(map[string]interface{})
+	}
+	daily := func(target string) []*models.Analytics {
+		t.Helper()
+		res, err := getDailyAnalytics(newContext(t, "GET", target, nil, nil, ""))
+		if err != nil {
+			t.Fatalf("getDailyAnalytics: %v", err)
+		}
+		return res.(map[string]interface{})["days"].([]*models.Analytics)
+	}
+	rollup := workers.NewAnalyticsRollup()
+	rollup.Backfill = 3
+
+	// The first run backfills, later runs refresh today's row
+	if err := rollup.RunOnce(time.Now()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	before := daily("/api/analytics/daily")
+	if len(before) != 3 {
+		t.Fatalf("rolled up %d days, want the 3 backfilled", len(before))
+	}
+	statsBefore := stats()
+
+	// A sale adds its price to today's and the live volume once its payment
+	// confirms
+	seller := newTestWallet(t)
+	nftID := mint(t, seller, "analytics")
+	if _, err := listNFTForSale(newContext(t, "POST", "/api/marketplace/list", map[string]interface{}{
+		"nft_id": nftID.String(),
+		"price":  2.5,
+		"seller": seller.address,
+	}, nil, seller.address)); err != nil {
+		t.Fatalf("listNFTForSale: %v", err)
+	}
+	buyer := newTestWallet(t)
+	if _, err := buyNFT(newContext(t, "POST", "/api/marketplace/buy", map[string]interface{}{
+		"nft_id":           nftID.String(),
+		"buyer":            buyer.address,
+		"price":            "2.5",
+		"transaction_hash": newTxHash(),
+	}, nil, buyer.address)); err != nil {
+		t.Fatalf("buyNFT: %v", err)
+	}
+	settleTransactions(t)
+
+	statsAfter := stats()
+	for _, key := range []string{"total_volume", "24h_volume"} {
+		if got := statsAfter[key].(float64) - statsBefore[key].(float64); got != 2.5 {
+			t.Errorf("%s grew by %v, want 2.5", key, got)
+		}
+	}
+	if got := statsAfter["24h_sales"].(int) - statsBefore["24h_sales"].(int); got != 1 {
+		t.Errorf("24h_sales grew by %d, want 1", got)
+	}
+
+	if err := rollup.RunOnce(time.Now()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	after := daily("/api/analytics/daily")
+	if len(after) != 3 {
+		t.Fatalf("rolled up %d days, want 3", len(after))
+	}
+	today, prevToday := after[2], before[2]
+	if today.TotalVolume-prevToday.TotalVolume != 2.5 || today.TotalNFTs != prevToday.TotalNFTs+1 || today.NewUsers < prevToday.NewUsers+2 {
+		t.Errorf("today = %+v, was %+v; want the sale, NFT and users counted", today, prevToday)
+	}
+	var trending []struct {
+		NFTID uuid.UUID `json:"nft_id"`
+	}
+	if err := json.Unmarshal(today.TrendingNFTs, &trending); err != nil {
+		t.Errorf("trending_nfts = %s: %v", today.TrendingNFTs, err)
+	}
+
+	res, err := getAnalyticsSeries(newContext(t, "GET", "/api/analytics/series/total_volume", nil, map[string]string{"metric": "total_volume"}, ""))
+	if err != nil {
+		t.Fatalf("getAnalyticsSeries: %v", err)
+	}
+	if series := res.(map[string]interface{})["series"]; reflect.ValueOf(series).Len() != 3 {
+		t.Errorf("series = %+v, want 3 points", series)
+	}
+
+	for _, target := range []string{"/api/analytics/daily?from=2026-02-01&to=2026-01-01", "/api/analytics/daily?from=2020-01-01&to=2026-01-01", "/api/analytics/daily?from=yesterday"} {
+		if _, err := getDailyAnalytics(newContext(t, "GET", target, nil, nil, "")); err == nil {
+			t.Errorf("%s accepted", target)
+		}
+	}
+	if _, err := getAnalyticsSeries(newContext(t, "GET", "/api/analytics/series/x", nil, map[string]string{"metric": "x"}, "")); err == nil {
+		t.Error("unknown metric accepted")
+	}
+}
+
+func TestTrending(t *testing.T) {
+	creator := newTestWallet(t)
+	liked := mint(t, creator, "trending-liked")
+	sold := mint(t, creator, "trending-sold")
+	stale := mint(t, creator, "trending-stale")
+
+	fan := newUser(t)
+	interactions := repository.NewInteractionRepository()
+	for _, id := range []uuid.UUID{liked, stale} {
+		if err := interactions.Record(models.NewUserInteraction(fan.ID, id, models.InteractionLike, models.InteractionWeightLike)); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	// The same like three hours ago has decayed
+	if _, err := database.DB.Exec(`UPDATE user_interactions SET created_at = NOW() - INTERVAL '3 hours' WHERE nft_id = $1`, stale); err != nil {
+		t.Fatalf("age interaction: %v", err)
+	}
+
+	if _, err := listNFTForSale(newContext(t, "POST", "/api/marketplace/list", map[string]interface{}{
+		"nft_id": sold.String(),
+		"price":  1.0,
+		"seller": creator.address,
+	}, nil, creator.address)); err != nil {
+		t.Fatalf("listNFTForSale: %v", err)
+	}
+	buyer := newTestWallet(t)
+	if _, err := buyNFT(newContext(t, "POST", "/api/marketplace/buy", map[string]interface{}{
+		"nft_id":           sold.String(),
+		"buyer":            buyer.address,
+		"price":            "1.0",
+		"transaction_hash": newTxHash(),
+	}, nil, buyer.address)); err != nil {
+		t.Fatalf("buyNFT: %v", err)
+	}
+	settleTransactions(t)
+
+	if err := workers.NewTrendingRefresher().RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	ranks := func(window string) map[uuid.UUID]*models.TrendingNFT {
+		t.Helper()
+		res, err := getTrendingNFTs(newContext(t, "GET", "/api/analytics/trending?limit=100&window="+window, nil, nil, ""))
+		if err != nil {
+			t.Fatalf("getTrendingNFTs: %v", err)
+		}
+		body := res.(map[string]interface{})
+		if body["period"] != window {
+			t.Errorf("period = %v, want %s", body["period"], window)
+		}
+		ranked := map[uuid.UUID]*models.TrendingNFT{}
+		for _, nft := range body["trending"].([]*models.TrendingNFT) {
+			ranked[nft.NFTID] = nft
+		}
+		return ranked
+	}
+
+	hour := ranks("1h")
+	if hour[liked] == nil || hour[sold] == nil || hour[stale] != nil {
+		t.Fatalf("1h trending = %v, want the recent like and sale only", hour)
+	}
+	if s := hour[sold]; s.Sales != 1 || s.Volume != 1 || s.Rank >= hour[liked].Rank {
+		t.Errorf("sold NFT = %+v, want its sale counted and ranked above the like", s)
+	}
+
+	day := ranks("24h")
+	if day[stale] == nil || day[liked] == nil || day[stale].Rank <= day[liked].Rank || day[stale].Score >= day[liked].Score {
+		t.Errorf("24h trending = %v, want the older like ranked below the recent one", day)
+	}
+
+	if _, err := getTrendingNFTs(newContext(t, "GET", "/api/analytics/trending?window=2d", nil, nil, "")); err == nil {
+		t.Error("unknown window accepted")
+	}
+}
+
+// useReadCache caches reads in memory until the test ends
+func useReadCache(t *testing.T) {
+	t.Helper()
+
+	readCache = cache.New(cache.NewMemory(1000))
+	cacheTTLs = cache.TTLs{NFT: time.Minute, Trending: time.Minute, Stats: time.Minute, Recommendations: time.Minute}
+	mintWorker.Cache, tracker.Cache = readCache, readCache
+	t.Cleanup(func() {
+		readCache = nil
+		mintWorker.Cache, tracker.Cache = nil, nil
+	})
+}
+
+func TestReadCache(t *testing.T) {
+	useReadCache(t)
+	seller := newTestWallet(t)
+	nftID := mint(t, seller, "cached")
+
+	getNFT := func() *models.NFT {
+		t.Helper()
+		res, err := getNFTByID(newContext(t, "GET", "/api/nfts/"+nftID.String(), nil, map[string]string{"id": nftID.String()}, ""))
+		if err != nil {
+			t.Fatalf("getNFTByID: %v", err)
+		}
+		return res.(*models.NFT)
+	}
+	activeListings := func() int {
+		t.Helper()
+		res, err := getMarketplaceStats(newContext(t, "GET", "/api/analytics/stats", nil, nil, ""))
+		if err != nil {
+			t.Fatalf("getMarketplaceStats: %v", err)
+		}
+		// Cached stats come back from JSON as float64
+		return int(reflect.ValueOf(res.(map[string]interface{})["active_listings"]).Convert(reflect.TypeOf(0)).Int())
+	}
+
+	// Writes behind the API's back stay hidden until the entry is dropped
+	getNFT()
+	listingsBefore := activeListings()
+	if _, err := database.DB.Exec(`UPDATE nfts SET name = 'renamed' WHERE id = $1`, nftID); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if nft := getNFT(); nft.Name != "cached" {
+		t.Fatalf("name = %s, want it served from the cache", nft.Name)
+	}
+
+	if _, err := listNFTForSale(newContext(t, "POST", "/api/marketplace/list", map[string]interface{}{
+		"nft_id": nftID.String(),
+		"price":  1.5,
+		"seller": seller.address,
+	}, nil, seller.address)); err != nil {
+		t.Fatalf("listNFTForSale: %v", err)
+	}
+	if nft := getNFT(); nft.Name != "renamed" {
+		t.Errorf("name = %s after listing, want the cached NFT dropped", nft.Name)
+	}
+	if got := activeListings(); got != listingsBefore+1 {
+		t.Errorf("active listings = %d after listing, want %d", got, listingsBefore+1)
+	}
+
+	// NFT reads embed their creator's profile
+	if _, err := updateUserProfile(newContext(t, "PUT", "/api/users/"+seller.address, map[string]interface{}{
+		"bio": "cached bio",
+	}, map[string]string{"address": seller.address}, seller.address)); err != nil {
+		t.Fatalf("updateUserProfile: %v", err)
+	}
+	if nft := getNFT(); nft.Creator == nil || nft.Creator.Bio == nil || *nft.Creator.Bio != "cached bio" {
+		t.Errorf("creator = %+v, want the updated bio", nft.Creator)
+	}
+
+	// Trending ranks are dropped once the refresher recomputes them
+	refresher := workers.NewTrendingRefresher()
+	refresher.Cache = readCache
+	trending := func() map[uuid.UUID]bool {
+		t.Helper()
+		res, err := getTrendingNFTs(newContext(t, "GET", "/api/analytics/trending?limit=100&window=1h", nil, nil, ""))
+		if err != nil {
+			t.Fatalf("getTrendingNFTs: %v", err)
+		}
+		ranked := map[uuid.UUID]bool{}
+		for _, nft := range res.(map[string]interface{})["trending"].([]*models.TrendingNFT) {
+			ranked[nft.NFTID] = true
+		}
+		return ranked
+	}
+	if err := refresher.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	trending()
+	fan := newUser(t)
+	if err := repository.NewInteractionRepository().Record(models.NewUserInteraction(fan.ID, nftID, models.InteractionLike, models.InteractionWeightLike)); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := refresher.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if !trending()[nftID] {
+		t.Error("refreshed trending ranks are missing the liked NFT")
+	}
+}
+
+func TestFeatureFlags(t *testing.T) {
+	admin, beta, other := newTestWallet(t), newTestWallet(t), newTestWallet(t)
+	prevAdmins := admins
+	admins = middleware.Admins{strings.ToLower(admin.address): true}
+	t.Cleanup(func() {
+		admins = prevAdmins
+		featureFlags.Reset(services.FeatureMinting)
+	})
+
+	gate := middleware.FeatureGate(featureFlags, middleware.FeatureRoutes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mintAs := func(wallet string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/nfts/mint", nil)
+		if wallet != "" {
+			req = req.WithContext(middleware.WithWallet(req.Context(), wallet))
+		}
+		rec := httptest.NewRecorder()
+		gate.ServeHTTP(rec, req)
+		return rec
+	}
+	update := func(wallet, name string, body map[string]interface{}) error {
+		t.Helper()
+		_, err := updateFeatureFlag(newContext(t, "PUT", "/api/admin/flags/"+name, body, map[string]string{"name": name}, wallet))
+		return err
+	}
+
+	if rec := mintAs(""); rec.Code != http.StatusNoContent {
+		t.Fatalf("mint with the env default: %d, want 204", rec.Code)
+	}
+	if err := update(other.address, services.FeatureMinting, map[string]interface{}{"enabled": false}); err != middleware.ErrNotAdmin || statusCode(err) != http.StatusForbidden {
+		t.Fatalf("update by a non-admin = %v (status %d), want ErrNotAdmin with a 403", err, statusCode(err))
+	}
+	if err := update(admin.address, "teleport", map[string]interface{}{"enabled": false}); err != services.ErrUnknownFeature {
+		t.Errorf("update of an unknown feature = %v, want ErrUnknownFeature", err)
+	}
+
+	// Disabled for everyone but the beta wallet
+	if err := update(admin.address, services.FeatureMinting, map[string]interface{}{
+		"enabled":         false,
+		"allowed_wallets": []string{beta.address},
+	}); err != nil {
+		t.Fatalf("updateFeatureFlag: %v", err)
+	}
+	rec := mintAs(other.address)
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Feature string `json:"feature"`
+		} `json:"error"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusServiceUnavailable || body.Error.Code != "feature_disabled" || body.Error.Feature != services.FeatureMinting {
+		t.Errorf("mint while disabled: %d %+v, want a 503 feature_disabled for minting", rec.Code, body)
+	}
+	if rec := mintAs(beta.address); rec.Code != http.StatusNoContent {
+		t.Errorf("mint by an allowed wallet: %d, want 204", rec.Code)
+	}
+
+	// Another instance picks the override up from the table
+	if services.NewFeatureFlags().Enabled(services.FeatureMinting, other.address) {
+		t.Error("another instance still has minting enabled")
+	}
+
+	res, err := getFeatures(newContext(t, "GET", "/api/features", nil, nil, beta.address))
+	if err != nil {
+		t.Fatalf("getFeatures: %v", err)
+	}
+	if features := res.(map[string]bool); !features[services.FeatureMinting] || !features[services.FeatureMarketplace] {
+		t.Errorf("features for the beta wallet = %v", features)
+	}
+
+	if _, err := resetFeatureFlag(newContext(t, "DELETE", "/api/admin/flags/minting", nil, map[string]string{"name": services.FeatureMinting}, admin.address)); err != nil {
+		t.Fatalf("resetFeatureFlag: %v", err)
+	}
+	if rec := mintAs(other.address); rec.Code != http.StatusNoContent {
+		t.Errorf("mint after reset: %d, want 204", rec.Code)
+	}
+}
+
+// tableExists reports whether table is in the test database's schema
+func tableExists(t *testing.T, table string) bool {
+	t.Helper()
+	var exists bool
+	if err := database.DB.Get(&exists, `SELECT to_regclass($1) IS NOT NULL`, table); err != nil {
+		t.Fatalf("to_regclass(%s): %v", table, err)
+	}
+	return exists
+}
+
+// appliedCount counts the migrations MigrationStatuses reports as applied
+func appliedCount(t *testing.T) (applied, total int) {
+	t.Helper()
+	statuses, err := database.MigrationStatuses()
+	if err != nil {
+		t.Fatalf("MigrationStatuses: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			applied++
+		}
+	}
+	return applied, len(statuses)
+}
+
+// TestMigrateDownAndUp runs last: it rolls the whole schema back, which
+// drops every row the other tests wrote
+func TestMigrateDownAndUp(t *testing.T) {
+	applied, total := appliedCount(t)
+	if applied != total {
+		t.Fatalf("%d of %d migrations applied before the test", applied, total)
+	}
+
+	// One step back reverts only the latest migration
+	if err := database.MigrateDown(1); err != nil {
+		t.Fatalf("MigrateDown(1): %v", err)
+	}
+	if applied, _ := appliedCount(t); applied != total-1 {
+		t.Errorf("%d migrations applied after one step down, want %d", applied, total-1)
+	}
+	if tableExists(t, "siwe_nonces") || !tableExists(t, "users") {
+		t.Error("one step down did not revert just the latest migration")
+	}
+
+	// Forcing the version only rewrites the bookkeeping
+	if err := database.ForceVersion(int64(total)); err != nil {
+		t.Fatalf("ForceVersion(%d): %v", total, err)
+	}
+	if applied, _ := appliedCount(t); applied != total || tableExists(t, "siwe_nonces") {
+		t.Errorf("after ForceVersion(%d): %d applied, siwe_nonces exists: %v", total, applied, tableExists(t, "siwe_nonces"))
+	}
+	if err := database.ForceVersion(int64(total - 1)); err != nil {
+		t.Fatalf("ForceVersion(%d): %v", total-1, err)
+	}
+	if applied, _ := appliedCount(t); applied != total-1 {
+		t.Errorf("%d migrations applied after ForceVersion(%d)", applied, total-1)
+	}
+
+	if err := database.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if !tableExists(t, "siwe_nonces") {
+		t.Error("siwe_nonces missing after migrating up again")
+	}
+
+	// All the way down and back up again
+	if err := database.MigrateDown(total); err != nil {
+		t.Fatalf("MigrateDown(%d): %v", total, err)
+	}
+	if applied, _ := appliedCount(t); applied != 0 || tableExists(t, "users") || tableExists(t, "nfts") {
+		t.Errorf("after migrating all the way down: %d applied, users table: %v", applied, tableExists(t, "users"))
+	}
+	if err := database.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp from empty: %v", err)
+	}
+	if applied, _ := appliedCount(t); applied != total {
+		t.Errorf("%d of %d migrations applied after migrating up again", applied, total)
+	}
+	for _, table := range []string{"users", "nfts", "siwe_nonces"} {
+		if !tableExists(t, table) {
+			t.Errorf("table %s missing after migrating up again", table)
+		}
+	}
+}
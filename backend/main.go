@@ -1,17 +1,29 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	gofrerrors "gofr.dev/pkg/errors"
 	"gofr.dev/pkg/gofr"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
+	"nftgenie/backend/cache"
 	"nftgenie/backend/database"
+	"nftgenie/backend/middleware"
 	"nftgenie/backend/models"
 	"nftgenie/backend/repository"
 	"nftgenie/backend/services"
+	"nftgenie/backend/workers"
 )
 
 // NFT represents an NFT in our marketplace
@@ -47,6 +59,49 @@ type Recommendation struct {
 	Reason     string  `json:"reason"`
 }
 
+// errNotCollectionCreator is answered with a 403 when a wallet edits or
+// mints into a collection it didn't create
+var errNotCollectionCreator = &gofrerrors.Response{
+	StatusCode: http.StatusForbidden,
+	Code:       "forbidden",
+	Reason:     "you don't own this collection",
+}
+
+// mintWorker processes the mint jobs queued by mintNFT
+var mintWorker *workers.MintWorker
+
+// aiEngine is the Python recommendation engine; recommender prefers it and
+// falls back to the SQL rules while it is down
+var (
+	aiEngine    *services.AIEngineClient
+	recommender *services.Recommender
+)
+
+// trainingWorker runs the training jobs queued by trainRecommendationModel
+var trainingWorker *workers.TrainingWorker
+
+// viewPipeline dedupes and batches the views counted by getNFTByID and
+// recordView
+var viewPipeline *workers.ViewPipeline
+
+// rateLimitConfig limits requests per client; viewerID follows its
+// TrustProxy to find the client IP
+var rateLimitConfig middleware.RateLimitConfig
+
+// featureFlags gates minting, the marketplace and AI recommendations; admins
+// may toggle them at runtime
+var (
+	featureFlags *services.FeatureFlags
+	admins       middleware.Admins
+)
+
+// readCache caches the hot reads for cacheTTLs; writes drop the keys they
+// make stale. It is nil when CACHE_DRIVER=none.
+var (
+	readCache *cache.Cache
+	cacheTTLs cache.TTLs
+)
+
 func main() {
 	// Load environment variables
 	err := godotenv.Load()
@@ -60,14 +115,88 @@ func main() {
 	}
 	defer database.Close()
 
+	// Handle "migrate" subcommands instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Run migrations
 	if err := database.Migrate(); err != nil {
-		log.Printf("Warning: Migration failed (tables may already exist): %v", err)
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	readCache, err = cache.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+	cacheTTLs = cache.TTLsFromEnv()
+
+	// Start the mint worker; jobs left over from a previous run resume
+	provider, err := services.NewMintProvider()
+	if err != nil {
+		log.Fatalf("Failed to initialize mint provider: %v", err)
 	}
+	mintWorker = workers.NewMintWorker(provider)
+	mintWorker.Cache = readCache
+	mintWorker.Start(context.Background())
+
+	// Follow submitted transactions until they are confirmed on chain
+	tracker := workers.NewConfirmationTracker(services.NewReceiptSource(provider))
+	tracker.Cache = readCache
+	tracker.Start(context.Background())
+
+	aiEngine = services.NewAIEngineClient()
+	recommender = services.NewRecommender(aiEngine)
+	trainingWorker = workers.NewTrainingWorker(aiEngine)
+	trainingWorker.Start(context.Background())
+
+	viewPipeline = workers.NewViewPipeline()
+	viewPipeline.Start(context.Background())
+
+	// Roll up the analytics table, filling in days missed while down
+	workers.NewAnalyticsRollup().Start(context.Background())
+	trendingRefresher := workers.NewTrendingRefresher()
+	trendingRefresher.Cache = readCache
+	trendingRefresher.Start(context.Background())
 
 	// Initialize GoFr application
 	app := gofr.New()
 
+	// CORS and security headers come first so every response carries them,
+	// rejections included; oversized bodies are refused before they are read
+	app.Server.UseMiddleware(
+		middleware.CORS(middleware.CORSConfigFromEnv()),
+		middleware.SecurityHeaders(),
+		middleware.LimitBody(middleware.MaxBodyBytesFromEnv()),
+	)
+
+	// Authenticate bearer tokens; login endpoints are public
+	tokenService := services.NewTokenService()
+	if err := tokenService.Validate(); err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
+	app.Server.UseMiddleware(middleware.Auth(tokenService,
+		"/api/users/{address}/nonce",
+		"/api/users/connect",
+		"/api/auth/refresh",
+	))
+
+	// Answer the routes of disabled features with a 503
+	featureFlags = services.NewFeatureFlags()
+	admins = middleware.AdminsFromEnv()
+	app.Server.UseMiddleware(middleware.FeatureGate(featureFlags, middleware.FeatureRoutes))
+
+	// Limit requests per wallet or client IP, minting and login tighter
+	rateLimitStore, err := middleware.NewRateLimitStore()
+	if err != nil {
+		log.Fatalf("Failed to initialize rate limit store: %v", err)
+	}
+	rateLimitConfig = middleware.RateLimitConfigFromEnv()
+	app.Server.UseMiddleware(middleware.RateLimit(rateLimitStore, rateLimitConfig))
+
 	// Health check endpoint
 	app.GET("/health", func(ctx *gofr.Context) (interface{}, error) {
 		return map[string]interface{}{
@@ -79,57 +208,161 @@ func main() {
 
 	// NFT endpoints
 	app.GET("/api/nfts", getAllNFTs)
+	app.GET("/api/nfts/search", searchNFTs)
 	app.GET("/api/nfts/{id}", getNFTByID)
+	app.POST("/api/nfts/{id}/like", likeNFT)
+	app.DELETE("/api/nfts/{id}/like", unlikeNFT)
+	app.POST("/api/nfts/{id}/view", recordView)
+	app.POST("/api/nfts/{id}/share", recordShare)
 	app.POST("/api/nfts/mint", mintNFT)
 	app.GET("/api/nfts/user/{address}", getUserNFTs)
+	app.GET("/api/mints/{jobId}", getMintJob)
+	app.GET("/api/transactions", getTransactions)
+
+	// Collection endpoints
+	app.GET("/api/collections", getCollections)
+	app.POST("/api/collections", createCollection)
+	app.GET("/api/collections/{id}", getCollection)
+	app.PUT("/api/collections/{id}", updateCollection)
+	app.GET("/api/collections/{id}/nfts", getCollectionNFTs)
 
 	// User endpoints
+	app.GET("/api/users", getUsers)
+	app.POST("/api/users/{address}/nonce", createNonce)
 	app.POST("/api/users/connect", connectWallet)
+	app.POST("/api/auth/refresh", refreshToken)
 	app.GET("/api/users/{address}", getUserProfile)
 	app.PUT("/api/users/{address}", updateUserProfile)
+	app.GET("/api/users/{address}/preferences", getUserPreferences)
+	app.PUT("/api/users/{address}/preferences", updateUserPreferences)
 
 	// AI Recommendation endpoints
 	app.GET("/api/recommendations/{userId}", getRecommendations)
+	app.GET("/api/recommendations/{userId}/explain/{nftId}", explainRecommendation)
 	app.POST("/api/recommendations/train", trainRecommendationModel)
+	app.GET("/api/recommendations/train/{jobId}", getTrainingJob)
 
 	// Marketplace endpoints
 	app.POST("/api/marketplace/list", listNFTForSale)
 	app.POST("/api/marketplace/buy", buyNFT)
 	app.GET("/api/marketplace/listings", getMarketplaceListings)
+	app.GET("/api/marketplace/listings/{id}", getMarketplaceListing)
+	app.PUT("/api/marketplace/listings/{id}", updateListingPrice)
+	app.POST("/api/marketplace/listings/{id}/cancel", cancelListing)
 
 	// Analytics endpoints
 	app.GET("/api/analytics/trending", getTrendingNFTs)
 	app.GET("/api/analytics/stats", getMarketplaceStats)
+	app.GET("/api/analytics/daily", getDailyAnalytics)
+	app.GET("/api/analytics/series/{metric}", getAnalyticsSeries)
+
+	// Feature flag endpoints
+	app.GET("/api/features", getFeatures)
+	app.GET("/api/admin/flags", getFeatureFlags)
+	app.PUT("/api/admin/flags/{name}", updateFeatureFlag)
+	app.DELETE("/api/admin/flags/{name}", resetFeatureFlag)
 
 	// Start server on port 8000
 	app.Start()
 }
 
-// NFT Handlers
-func getAllNFTs(ctx *gofr.Context) (interface{}, error) {
-	nftRepo := repository.NewNFTRepository()
-	
-	// Get pagination params
-	limit := 20
-	offset := 0
+// runMigrateCommand runs "migrate up|down [steps]|status|force <version>"
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status|force <version>")
+	}
+
+	switch args[0] {
+	case "up":
+		return database.MigrateUp()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		return database.MigrateDown(steps)
+	case "status":
+		statuses, err := database.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = "applied " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%06d  %-40s %s\n", st.Version, st.Name, applied)
+		}
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate force <version>")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return database.ForceVersion(version)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// pageRequest reads the limit and cursor query parameters of a list endpoint
+func pageRequest(ctx *gofr.Context, list string) (repository.PageRequest, error) {
+	page := repository.PageRequest{Limit: repository.DefaultPageSize}
 	if l := ctx.Param("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return page, fmt.Errorf("invalid limit")
+		}
+		page.Limit = min(limit, repository.MaxPageSize)
 	}
-	if o := ctx.Param("offset"); o != "" {
-		fmt.Sscanf(o, "%d", &offset)
+	if c := ctx.Param("cursor"); c != "" {
+		cursor, err := services.NewCursorCodec().Decode(list, c)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = cursor
 	}
-	
-	nfts, total, err := nftRepo.GetAll(limit, offset)
+	return page, nil
+}
+
+// pageResponse wraps a page of items in the envelope shared by list endpoints
+func pageResponse(list string, items interface{}, page repository.PageRequest, cursors repository.Page) map[string]interface{} {
+	codec := services.NewCursorCodec()
+	response := map[string]interface{}{
+		"data":        items,
+		"limit":       page.Limit,
+		"next_cursor": nil,
+		"prev_cursor": nil,
+	}
+	if cursors.Next != nil {
+		response["next_cursor"] = codec.Encode(list, cursors.Next)
+	}
+	if cursors.Prev != nil {
+		response["prev_cursor"] = codec.Encode(list, cursors.Prev)
+	}
+	return response
+}
+
+// NFT Handlers
+func getAllNFTs(ctx *gofr.Context) (interface{}, error) {
+	page, err := pageRequest(ctx, "nfts")
 	if err != nil {
 		return nil, err
 	}
-	
-	return map[string]interface{}{
-		"nfts":  nfts,
-		"total": total,
-		"limit": limit,
-		"offset": offset,
-	}, nil
+
+	nfts, cursors, err := repository.NewNFTRepository().GetAll(page)
+	if err != nil {
+		return nil, err
+	}
+
+	return pageResponse("nfts", nfts, page, cursors), nil
 }
 
 func getNFTByID(ctx *gofr.Context) (interface{}, error) {
@@ -139,28 +372,120 @@ func getNFTByID(ctx *gofr.Context) (interface{}, error) {
 		return nil, fmt.Errorf("invalid NFT ID")
 	}
 	
-	nftRepo := repository.NewNFTRepository()
-	nft, err := nftRepo.GetByID(id)
+	nft, err := cache.Fetch(ctx, readCache, cache.NFTKey(id), cacheTTLs.NFT, func() (*models.NFT, error) {
+		return repository.NewNFTRepository().GetByID(id)
+	})
 	if err != nil {
 		return nil, err
 	}
 	
+	// Cached reads count too; the cached view count lags until it expires
+	wallet, _ := middleware.WalletFromContext(ctx)
+	viewPipeline.Record(nft.ID, viewerID(ctx, wallet), wallet)
+	
 	return nft, nil
 }
 
+// viewerID identifies who views an NFT for view dedupe: the wallet when the
+// request is authenticated, else the client IP as rate limiting sees it
+func viewerID(ctx *gofr.Context, wallet string) string {
+	if wallet != "" {
+		return "wallet:" + wallet
+	}
+	return "ip:" + middleware.ClientIP(ctx.Request(), rateLimitConfig.TrustProxy)
+}
+
+func searchNFTs(ctx *gofr.Context) (interface{}, error) {
+	search := repository.NFTSearch{
+		Query:  strings.TrimSpace(ctx.Param("q")),
+		Chain:  ctx.Param("chain"),
+		Limit:  repository.DefaultPageSize,
+		Offset: 0,
+	}
+	if l := ctx.Param("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit")
+		}
+		search.Limit = min(limit, repository.MaxPageSize)
+	}
+	if o := ctx.Param("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset")
+		}
+		search.Offset = offset
+	}
+
+	// Facet filters
+	for _, tag := range strings.Split(ctx.Param("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			search.Tags = append(search.Tags, tag)
+		}
+	}
+	if c := ctx.Param("collection"); c != "" {
+		collectionID, err := uuid.Parse(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid collection ID")
+		}
+		search.CollectionID = &collectionID
+	}
+	if address := ctx.Param("creator"); address != "" {
+		creator, err := repository.NewUserRepository().GetByWalletAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		// An unknown creator matches nothing
+		creatorID := uuid.Nil
+		if creator != nil {
+			creatorID = creator.ID
+		}
+		search.CreatorID = &creatorID
+	}
+
+	// Trait filter, e.g. attributes={"rarity":"rare"}
+	if a := ctx.Param("attributes"); a != "" {
+		var traits map[string]interface{}
+		if err := json.Unmarshal([]byte(a), &traits); err != nil {
+			return nil, fmt.Errorf("attributes must be a JSON object")
+		}
+		search.Attributes = json.RawMessage(a)
+	}
+
+	result, err := repository.NewNFTRepository().Search(search)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"results": result.Hits,
+		"total":   result.Total,
+		"facets":  result.Facets,
+		"limit":   search.Limit,
+		"offset":  search.Offset,
+	}, nil
+}
+
 func mintNFT(ctx *gofr.Context) (interface{}, error) {
 	var mintRequest struct {
-		Name        string   `json:"name"`
-		Description string   `json:"description"`
-		ImageURL    string   `json:"image_url"`
-		Creator     string   `json:"creator"`
-		Chain       string   `json:"chain"`
-		Tags        []string `json:"tags"`
+		Name         string   `json:"name"`
+		Description  string   `json:"description"`
+		ImageURL     string   `json:"image_url"`
+		Creator      string   `json:"creator"`
+		Chain        string   `json:"chain"`
+		Tags         []string `json:"tags"`
+		CollectionID string   `json:"collection_id"`
 	}
 
 	if err := ctx.Bind(&mintRequest); err != nil {
 		return nil, err
 	}
+	if mintRequest.Name == "" || mintRequest.ImageURL == "" {
+		return nil, fmt.Errorf("name and image_url are required")
+	}
+	if err := middleware.RequireWallet(ctx, mintRequest.Creator); err != nil {
+		return nil, err
+	}
 
 	// Get or create user
 	userRepo := repository.NewUserRepository()
@@ -176,57 +501,54 @@ func mintNFT(ctx *gofr.Context) (interface{}, error) {
 		}
 	}
 
-	// Initialize Verbwire service
-	vw := services.NewVerbwireService()
+	// Queue the mint; the worker calls the provider and records the NFT
+	job := models.NewMintJob(user.ID, mintRequest.Name, mintRequest.ImageURL, mintRequest.Creator)
+	job.Description = &mintRequest.Description
+	if len(mintRequest.Tags) > 0 {
+		job.Tags = mintRequest.Tags
+	}
 
-	// Build request
-	req := services.MintNFTRequest{
-		Name:            mintRequest.Name,
-		Description:     mintRequest.Description,
-		ImageURL:        mintRequest.ImageURL,
-		RecipientAddress: mintRequest.Creator,
-		Chain:           vw.Chain,
-		Quantity:        1,
+	// Only the collection's creator may mint into it
+	if mintRequest.CollectionID != "" {
+		collectionID, err := uuid.Parse(mintRequest.CollectionID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid collection ID")
+		}
+		collection, err := repository.NewCollectionRepository().GetByID(collectionID)
+		if err != nil {
+			return nil, err
+		}
+		if collection.CreatorID != user.ID {
+			return nil, errNotCollectionCreator
+		}
+		job.CollectionID = &collection.ID
 	}
 
-	// Call Verbwire API
-	res, err := vw.QuickMintNFT(req)
-	if err != nil {
-		ctx.Logger.Errorf("mint error: %v", err)
-		return map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		}, nil
+	if err := repository.NewMintJobRepository().Create(job); err != nil {
+		return nil, err
 	}
+	mintWorker.Notify()
 
-	// Save NFT to database
-	nft := models.NewNFT(mintRequest.Name, mintRequest.ImageURL, user.ID, user.ID)
-	nft.Description = &mintRequest.Description
-	nft.ContractAddress = &res.ContractAddress
-	nft.TokenID = &res.TokenID
-	nft.TransactionHash = &res.TransactionHash
-	nft.Chain = vw.Chain
-	now := time.Now()
-	nft.MintedAt = &now
-	if len(mintRequest.Tags) > 0 {
-		nft.Tags = mintRequest.Tags
+	return map[string]interface{}{
+		"success": true,
+		"message": "Mint queued",
+		"job_id":  job.ID,
+		"status":  job.Status,
+	}, nil
+}
+
+func getMintJob(ctx *gofr.Context) (interface{}, error) {
+	id, err := uuid.Parse(ctx.PathParam("jobId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid mint job ID")
 	}
 
-	nftRepo := repository.NewNFTRepository()
-	if err := nftRepo.Create(nft); err != nil {
-		ctx.Logger.Errorf("failed to save NFT: %v", err)
+	job, err := repository.NewMintJobRepository().GetByID(id)
+	if err != nil {
+		return nil, err
 	}
 
-	return map[string]interface{}{
-		"success":           true,
-		"message":           "NFT minted successfully",
-		"nft_id":            nft.ID,
-		"transaction_hash":  res.TransactionHash,
-		"contract_address":  res.ContractAddress,
-		"token_id":          res.TokenID,
-		"opensea_url":       res.OpenseaURL,
-		"chain":             vw.Chain,
-	}, nil
+	return job, nil
 }
 
 func getUserNFTs(ctx *gofr.Context) (interface{}, error) {
@@ -249,193 +571,816 @@ func getUserNFTs(ctx *gofr.Context) (interface{}, error) {
 	return nfts, nil
 }
 
-// User Handlers
-func connectWallet(ctx *gofr.Context) (interface{}, error) {
-	var walletRequest struct {
-		Address   string `json:"address"`
-		Signature string `json:"signature"`
-		Message   string `json:"message"`
-	}
-
-	if err := ctx.Bind(&walletRequest); err != nil {
+func getTransactions(ctx *gofr.Context) (interface{}, error) {
+	page, err := pageRequest(ctx, "transactions")
+	if err != nil {
 		return nil, err
 	}
 
-	// Get or create user
-	userRepo := repository.NewUserRepository()
-	user, err := userRepo.GetByWalletAddress(walletRequest.Address)
-	if err != nil && err.Error() != "user not found" {
-		return nil, err
+	filter := repository.TransactionFilter{Page: page}
+	if n := ctx.Param("nft_id"); n != "" {
+		nftID, err := uuid.Parse(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NFT ID")
+		}
+		filter.NFTID = &nftID
 	}
-	
-	if user == nil {
-		// Create new user
-		user = models.NewUser(walletRequest.Address)
-		if err := userRepo.CreateOrUpdate(user); err != nil {
+
+	// Filter by sender or recipient wallet
+	if address := ctx.Param("user"); address != "" {
+		user, err := repository.NewUserRepository().GetByWalletAddress(address)
+		if err != nil {
 			return nil, err
 		}
+		if user == nil {
+			return pageResponse("transactions", []*models.Transaction{}, page, repository.Page{}), nil
+		}
+		filter.UserID = &user.ID
 	}
 
-	// TODO: Implement actual signature verification
-	// For now, we'll accept any connection
-	
-	return map[string]interface{}{
-		"success": true,
-		"user":    user,
-		"token":   "jwt_token_here", // TODO: Implement JWT generation
-	}, nil
+	transactions, cursors, err := repository.NewTransactionRepository().List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pageResponse("transactions", transactions, page, cursors), nil
 }
 
-func getUserProfile(ctx *gofr.Context) (interface{}, error) {
-	address := ctx.PathParam("address")
-	
-	userRepo := repository.NewUserRepository()
-	user, err := userRepo.GetByWalletAddress(address)
+// Collection Handlers
+func getCollections(ctx *gofr.Context) (interface{}, error) {
+	page, err := pageRequest(ctx, "collections")
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
-		return nil, fmt.Errorf("user not found")
+
+	// Filter by creator wallet
+	var creatorID *uuid.UUID
+	if address := ctx.Param("creator"); address != "" {
+		creator, err := repository.NewUserRepository().GetByWalletAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		if creator == nil {
+			return pageResponse("collections", []*models.Collection{}, page, repository.Page{}), nil
+		}
+		creatorID = &creator.ID
 	}
-	
-	// Get user stats
-	stats, err := userRepo.GetUserStats(user.ID)
+
+	collections, cursors, err := repository.NewCollectionRepository().GetAll(creatorID, page)
 	if err != nil {
-		ctx.Logger.Errorf("failed to get user stats: %v", err)
-		stats = make(map[string]interface{})
+		return nil, err
 	}
-	
-	return map[string]interface{}{
-		"user":  user,
-		"stats": stats,
-	}, nil
+
+	return pageResponse("collections", collections, page, cursors), nil
 }
 
-func updateUserProfile(ctx *gofr.Context) (interface{}, error) {
-	address := ctx.PathParam("address")
-	var updateRequest struct {
-		Username     string `json:"username"`
-		Bio          string `json:"bio"`
-		ProfileImage string `json:"profile_image"`
-		Email        string `json:"email"`
+func createCollection(ctx *gofr.Context) (interface{}, error) {
+	var collectionRequest struct {
+		Name            string `json:"name"`
+		Description     string `json:"description"`
+		ContractAddress string `json:"contract_address"`
+		Chain           string `json:"chain"`
+		Creator         string `json:"creator"`
 	}
 
-	if err := ctx.Bind(&updateRequest); err != nil {
+	if err := ctx.Bind(&collectionRequest); err != nil {
+		return nil, err
+	}
+	if collectionRequest.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if collectionRequest.ContractAddress != "" && !services.IsHexAddress(collectionRequest.ContractAddress) {
+		return nil, fmt.Errorf("invalid contract address")
+	}
+	if err := middleware.RequireWallet(ctx, collectionRequest.Creator); err != nil {
 		return nil, err
 	}
 
+	// Get or create user
 	userRepo := repository.NewUserRepository()
-	user, err := userRepo.GetByWalletAddress(address)
-	if err != nil || user == nil {
-		return nil, fmt.Errorf("user not found")
+	user, err := userRepo.GetByWalletAddress(collectionRequest.Creator)
+	if err != nil {
+		return nil, err
 	}
-
-	// Check username availability
-	if updateRequest.Username != "" && (user.Username == nil || *user.Username != updateRequest.Username) {
-		exists, err := userRepo.UsernameExists(updateRequest.Username)
-		if err != nil {
+	if user == nil {
+		user = models.NewUser(collectionRequest.Creator)
+		if err := userRepo.Create(user); err != nil {
 			return nil, err
 		}
-		if exists {
-			return nil, fmt.Errorf("username already taken")
-		}
-		user.Username = &updateRequest.Username
 	}
 
-	if updateRequest.Bio != "" {
-		user.Bio = &updateRequest.Bio
+	collection := models.NewCollection(collectionRequest.Name, user.ID)
+	if collectionRequest.Description != "" {
+		collection.Description = &collectionRequest.Description
 	}
-	if updateRequest.ProfileImage != "" {
-		user.ProfileImage = &updateRequest.ProfileImage
+	if collectionRequest.ContractAddress != "" {
+		collection.ContractAddress = &collectionRequest.ContractAddress
 	}
-	if updateRequest.Email != "" {
-		user.Email = &updateRequest.Email
+	if collectionRequest.Chain != "" {
+		collection.Chain = collectionRequest.Chain
 	}
 
-	if err := userRepo.Update(user); err != nil {
+	if err := repository.NewCollectionRepository().Create(collection); err != nil {
 		return nil, err
 	}
 
 	return map[string]interface{}{
-		"success": true,
-		"message": "Profile updated successfully",
-		"user":    user,
+		"success":    true,
+		"collection": collection,
+		"message":    "Collection created successfully",
 	}, nil
 }
 
-// AI Recommendation Handlers
-func getRecommendations(ctx *gofr.Context) (interface{}, error) {
-	userIdStr := ctx.PathParam("userId")
-	userID, err := uuid.Parse(userIdStr)
+func getCollection(ctx *gofr.Context) (interface{}, error) {
+	id, err := uuid.Parse(ctx.PathParam("id"))
 	if err != nil {
-		// Try to get by wallet address
-		userRepo := repository.NewUserRepository()
-		user, err := userRepo.GetByWalletAddress(userIdStr)
-		if err != nil || user == nil {
-			return nil, fmt.Errorf("user not found")
-		}
-		userID = user.ID
+		return nil, fmt.Errorf("invalid collection ID")
 	}
-	
-	// Get recommended NFTs
-	nftRepo := repository.NewNFTRepository()
-	nfts, err := nftRepo.GetRecommendedForUser(userID, 10)
-	if err != nil {
+
+	return repository.NewCollectionRepository().GetByID(id)
+}
+
+func updateCollection(ctx *gofr.Context) (interface{}, error) {
+	var updateRequest struct {
+		Name            string `json:"name"`
+		Description     string `json:"description"`
+		ContractAddress string `json:"contract_address"`
+		Creator         string `json:"creator"`
+	}
+
+	if err := ctx.Bind(&updateRequest); err != nil {
 		return nil, err
 	}
-	
-	// Format as recommendations
-	recommendations := make([]map[string]interface{}, len(nfts))
-	for i, nft := range nfts {
-		recommendations[i] = map[string]interface{}{
-			"nft":    nft,
-			"score":  0.85 + float64(10-i)*0.015, // Simulated score
-			"reason": generateRecommendationReason(i),
-		}
+	if err := middleware.RequireWallet(ctx, updateRequest.Creator); err != nil {
+		return nil, err
 	}
-	
-	return recommendations, nil
-}
 
-func generateRecommendationReason(index int) string {
-	reasons := []string{
-		"Based on your interest in digital art",
-		"Similar to NFTs you've viewed",
-		"From creators you follow",
-		"Trending in your favorite categories",
-		"Matches your collection style",
-		"Popular with similar collectors",
-		"New from verified creators",
-		"Rising in value recently",
-		"Limited edition opportunity",
-		"Recommended by the algorithm",
+	id, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid collection ID")
 	}
-	if index < len(reasons) {
-		return reasons[index]
+	if updateRequest.ContractAddress != "" && !services.IsHexAddress(updateRequest.ContractAddress) {
+		return nil, fmt.Errorf("invalid contract address")
 	}
-	return "Personalized for you"
-}
-
-func trainRecommendationModel(ctx *gofr.Context) (interface{}, error) {
-	// TODO: Implement model training logic
-	return map[string]interface{}{
-		"success": true,
-		"message": "Recommendation model training initiated",
-		"job_id":  "train_job_123",
-	}, nil
-}
+
+	userRepo := repository.NewUserRepository()
+	creator, err := userRepo.GetByWalletAddress(updateRequest.Creator)
+	if err != nil || creator == nil {
+		return nil, fmt.Errorf("creator not found")
+	}
+
+	collectionRepo := repository.NewCollectionRepository()
+	collection, err := collectionRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if collection.CreatorID != creator.ID {
+		return nil, errNotCollectionCreator
+	}
+
+	if updateRequest.Name != "" {
+		collection.Name = updateRequest.Name
+	}
+	if updateRequest.Description != "" {
+		collection.Description = &updateRequest.Description
+	}
+	if updateRequest.ContractAddress != "" {
+		collection.ContractAddress = &updateRequest.ContractAddress
+	}
+
+	if err := collectionRepo.Update(collection); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"collection": collection,
+		"message":    "Collection updated successfully",
+	}, nil
+}
+
+func getCollectionNFTs(ctx *gofr.Context) (interface{}, error) {
+	id, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid collection ID")
+	}
+	if _, err := repository.NewCollectionRepository().GetByID(id); err != nil {
+		return nil, err
+	}
+
+	page, err := pageRequest(ctx, "collection_nfts")
+	if err != nil {
+		return nil, err
+	}
+
+	nfts, cursors, err := repository.NewNFTRepository().GetByCollection(id, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return pageResponse("collection_nfts", nfts, page, cursors), nil
+}
+
+// User Handlers
+func getUsers(ctx *gofr.Context) (interface{}, error) {
+	page, err := pageRequest(ctx, "users")
+	if err != nil {
+		return nil, err
+	}
+
+	users, cursors, err := repository.NewUserRepository().GetAll(page)
+	if err != nil {
+		return nil, err
+	}
+	// Don't hand out contact details in bulk
+	for _, user := range users {
+		user.Email = nil
+	}
+
+	return pageResponse("users", users, page, cursors), nil
+}
+
+// createNonce issues a sign-in challenge for a wallet. Each challenge has its
+// own nonce, so requesting one cannot invalidate a login in progress; users
+// are only created once a login verifies.
+func createNonce(ctx *gofr.Context) (interface{}, error) {
+	address := ctx.PathParam("address")
+	if !services.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid wallet address")
+	}
+
+	nonce, err := services.GenerateNonce()
+	if err != nil {
+		return nil, err
+	}
+	challenge := &models.SIWENonce{Nonce: nonce, WalletAddress: address}
+	if err := repository.NewSIWENonceRepository().Create(challenge, services.SIWENonceTTL); err != nil {
+		return nil, err
+	}
+
+	siwe := services.NewSIWEService()
+	return map[string]interface{}{
+		"nonce":      challenge.Nonce,
+		"domain":     siwe.Domain,
+		"chain_id":   siwe.ChainID,
+		"issued_at":  time.Now().UTC().Format(time.RFC3339),
+		"expires_at": challenge.ExpiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+func connectWallet(ctx *gofr.Context) (interface{}, error) {
+	var walletRequest struct {
+		Address   string `json:"address"`
+		Signature string `json:"signature"`
+		Message   string `json:"message"`
+	}
+
+	if err := ctx.Bind(&walletRequest); err != nil {
+		return nil, err
+	}
+	if walletRequest.Message == "" || walletRequest.Signature == "" {
+		return nil, fmt.Errorf("message and signature are required")
+	}
+
+	// The message names the challenge it answers, which must have been
+	// issued for this wallet
+	msg, err := services.ParseSIWEMessage(walletRequest.Message)
+	if err != nil {
+		return nil, err
+	}
+	nonceRepo := repository.NewSIWENonceRepository()
+	challenge, err := nonceRepo.Get(msg.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || !strings.EqualFold(challenge.WalletAddress, walletRequest.Address) {
+		return nil, services.ErrNonceMismatch
+	}
+
+	// Verify the SIWE message was signed by the claimed wallet
+	siwe := services.NewSIWEService()
+	msg, err = siwe.Verify(walletRequest.Message, walletRequest.Signature, challenge.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(msg.Address, walletRequest.Address) {
+		return nil, services.ErrSignerMismatch
+	}
+
+	// Consume the challenge so the signature cannot be replayed
+	consumed, err := nonceRepo.Consume(challenge.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, services.ErrNonceMismatch
+	}
+
+	// A wallet's first login creates its user
+	userRepo := repository.NewUserRepository()
+	user, err := userRepo.GetByWalletAddress(msg.Address)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user = models.NewUser(msg.Address)
+		if err := userRepo.Create(user); err != nil {
+			return nil, err
+		}
+	}
+
+	// Issue session tokens for the verified wallet
+	tokens, err := services.NewTokenService().IssueTokens(user.ID, user.WalletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"user":    user,
+		"tokens":  tokens,
+	}, nil
+}
+
+func refreshToken(ctx *gofr.Context) (interface{}, error) {
+	var refreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := ctx.Bind(&refreshRequest); err != nil {
+		return nil, err
+	}
+
+	tokenService := services.NewTokenService()
+	claims, err := tokenService.Parse(refreshRequest.RefreshToken, services.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the wallet still has an account
+	userRepo := repository.NewUserRepository()
+	user, err := userRepo.GetByWalletAddress(claims.Wallet)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, services.ErrInvalidToken
+	}
+
+	tokens, err := tokenService.IssueTokens(user.ID, user.WalletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"tokens":  tokens,
+	}, nil
+}
+
+func getUserProfile(ctx *gofr.Context) (interface{}, error) {
+	address := ctx.PathParam("address")
+	
+	userRepo := repository.NewUserRepository()
+	user, err := userRepo.GetByWalletAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	
+	// Get user stats
+	stats, err := userRepo.GetUserStats(user.ID)
+	if err != nil {
+		ctx.Logger.Errorf("failed to get user stats: %v", err)
+		stats = make(map[string]interface{})
+	}
+	
+	return map[string]interface{}{
+		"user":  user,
+		"stats": stats,
+	}, nil
+}
+
+func updateUserProfile(ctx *gofr.Context) (interface{}, error) {
+	address := ctx.PathParam("address")
+	var updateRequest struct {
+		Username     string `json:"username"`
+		Bio          string `json:"bio"`
+		ProfileImage string `json:"profile_image"`
+		Email        string `json:"email"`
+	}
+
+	if err := ctx.Bind(&updateRequest); err != nil {
+		return nil, err
+	}
+	if err := middleware.RequireWallet(ctx, address); err != nil {
+		return nil, err
+	}
+
+	userRepo := repository.NewUserRepository()
+	user, err := userRepo.GetByWalletAddress(address)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	// Check username availability
+	if updateRequest.Username != "" && (user.Username == nil || *user.Username != updateRequest.Username) {
+		exists, err := userRepo.UsernameExists(updateRequest.Username)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("username already taken")
+		}
+		user.Username = &updateRequest.Username
+	}
+
+	if updateRequest.Bio != "" {
+		user.Bio = &updateRequest.Bio
+	}
+	if updateRequest.ProfileImage != "" {
+		user.ProfileImage = &updateRequest.ProfileImage
+	}
+	if updateRequest.Email != "" {
+		user.Email = &updateRequest.Email
+	}
+
+	if err := userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	// NFT reads embed their creator and owner
+	nftIDs, err := repository.NewNFTRepository().GetIDsByUser(user.ID)
+	if err != nil {
+		ctx.Logger.Errorf("failed to find NFTs to uncache for user %s: %v", user.ID, err)
+	}
+	keys := make([]string, len(nftIDs))
+	for i, id := range nftIDs {
+		keys[i] = cache.NFTKey(id)
+	}
+	readCache.Delete(ctx, keys...)
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "Profile updated successfully",
+		"user":    user,
+	}, nil
+}
+
+// maxPreferenceTerms caps each list in a user's preferences
+const maxPreferenceTerms = 50
+
+func getUserPreferences(ctx *gofr.Context) (interface{}, error) {
+	address := ctx.PathParam("address")
+	if err := middleware.RequireWallet(ctx, address); err != nil {
+		return nil, err
+	}
+	
+	user, err := repository.NewUserRepository().GetByWalletAddress(address)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	
+	return repository.NewPreferencesRepository().GetByUserID(user.ID)
+}
+
+// updateUserPreferences replaces the user's recommendation preferences and
+// drops their cached recommendations so the next request reflects them
+func updateUserPreferences(ctx *gofr.Context) (interface{}, error) {
+	address := ctx.PathParam("address")
+	var prefsRequest struct {
+		PreferredCategories []string           `json:"preferred_categories"`
+		PreferredPriceRange *models.PriceRange `json:"preferred_price_range"`
+		PreferredCreators   []string           `json:"preferred_creators"`
+		ExcludedTags        []string           `json:"excluded_tags"`
+	}
+	
+	if err := ctx.Bind(&prefsRequest); err != nil {
+		return nil, err
+	}
+	if err := middleware.RequireWallet(ctx, address); err != nil {
+		return nil, err
+	}
+	
+	user, err := repository.NewUserRepository().GetByWalletAddress(address)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	
+	prefs := models.NewUserPreferences(user.ID)
+	prefs.PreferredCategories = preferenceTerms(prefsRequest.PreferredCategories)
+	prefs.ExcludedTags = preferenceTerms(prefsRequest.ExcludedTags)
+	if len(prefs.PreferredCategories) > maxPreferenceTerms || len(prefs.ExcludedTags) > maxPreferenceTerms || len(prefsRequest.PreferredCreators) > maxPreferenceTerms {
+		return nil, fmt.Errorf("at most %d categories, creators and excluded tags each", maxPreferenceTerms)
+	}
+	for _, category := range prefs.PreferredCategories {
+		for _, tag := range prefs.ExcludedTags {
+			if category == tag {
+				return nil, fmt.Errorf("%q is both a preferred category and an excluded tag", tag)
+			}
+		}
+	}
+	
+	if prefsRequest.PreferredPriceRange != nil {
+		if err := prefsRequest.PreferredPriceRange.Validate(); err != nil {
+			return nil, err
+		}
+		prefs.PreferredPriceRange, err = json.Marshal(prefsRequest.PreferredPriceRange)
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	// Creators may be given by user ID or wallet address; they are stored by ID
+	for _, creator := range preferenceTerms(prefsRequest.PreferredCreators) {
+		creatorUser, err := recommendationUser(creator)
+		if err != nil {
+			return nil, fmt.Errorf("creator %s not found", creator)
+		}
+		prefs.PreferredCreators = append(prefs.PreferredCreators, creatorUser.ID.String())
+	}
+	prefs.PreferredCreators = preferenceTerms(prefs.PreferredCreators)
+	
+	err = database.Transaction(func(tx *sqlx.Tx) error {
+		if err := repository.NewPreferencesRepository().WithTx(tx).Upsert(prefs); err != nil {
+			return err
+		}
+		return repository.NewRecommendationRepository().WithTx(tx).DeleteForUser(user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	readCache.Delete(ctx, cache.RecommendationsKey(user.ID))
+	
+	return prefs, nil
+}
+
+// preferenceTerms trims the terms and drops blanks and duplicates, keeping
+// the first occurrence's order
+func preferenceTerms(terms []string) []string {
+	var cleaned []string
+	seen := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" || seen[term] {
+			continue
+		}
+		seen[term] = true
+		cleaned = append(cleaned, term)
+	}
+	return cleaned
+}
+
+// Interaction Handlers
+func likeNFT(ctx *gofr.Context) (interface{}, error) {
+	return setLike(ctx, true)
+}
+
+func unlikeNFT(ctx *gofr.Context) (interface{}, error) {
+	return setLike(ctx, false)
+}
+
+// setLike likes or unlikes an NFT for the authenticated user; repeating
+// either is a no-op, so nfts.likes always matches the like interactions
+func setLike(ctx *gofr.Context, liked bool) (interface{}, error) {
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nftID, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NFT ID")
+	}
+	
+	var likes int
+	err = database.Transaction(func(tx *sqlx.Tx) error {
+		nftRepo := repository.NewNFTRepository().WithTx(tx)
+		interactionRepo := repository.NewInteractionRepository().WithTx(tx)
+		
+		var err error
+		likes, err = nftRepo.LockLikes(nftID)
+		if err != nil {
+			return err
+		}
+		
+		if liked {
+			like := models.NewUserInteraction(user.ID, nftID, models.InteractionLike, models.InteractionWeightLike)
+			added, err := interactionRepo.Add(like)
+			if err != nil || !added {
+				return err
+			}
+			likes++
+			return nftRepo.IncrementLikes(nftID)
+		}
+		
+		removed, err := interactionRepo.Remove(user.ID, nftID, models.InteractionLike)
+		if err != nil || !removed {
+			return err
+		}
+		likes = max(likes-1, 0)
+		return nftRepo.DecrementLikes(nftID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	readCache.Delete(ctx, cache.NFTKey(nftID))
+	
+	return map[string]interface{}{
+		"success": true,
+		"nft_id":  nftID,
+		"liked":   liked,
+		"likes":   likes,
+	}, nil
+}
+
+// recordView counts an explicit view by the authenticated user. Like views
+// through getNFTByID it goes through viewPipeline, so repeats within the
+// dedupe window are not counted and the counts land with the next flush.
+func recordView(ctx *gofr.Context) (interface{}, error) {
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nftID, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NFT ID")
+	}
+	
+	exists, err := repository.NewNFTRepository().Exists(nftID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, repository.ErrNFTNotFound
+	}
+	
+	counted := viewPipeline.Record(nftID, viewerID(ctx, user.WalletAddress), user.WalletAddress)
+	
+	return map[string]interface{}{
+		"success":          true,
+		"nft_id":           nftID,
+		"interaction_type": models.InteractionView,
+		"counted":          counted,
+	}, nil
+}
+
+func recordShare(ctx *gofr.Context) (interface{}, error) {
+	return recordInteraction(ctx, models.InteractionShare, models.InteractionWeightShare)
+}
+
+// recordInteraction upserts an interaction of the authenticated user with an
+// NFT; repeats refresh the existing row instead of adding weight
+func recordInteraction(ctx *gofr.Context, interactionType string, weight float64) (interface{}, error) {
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nftID, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NFT ID")
+	}
+	
+	exists, err := repository.NewNFTRepository().Exists(nftID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, repository.ErrNFTNotFound
+	}
+	
+	interaction := models.NewUserInteraction(user.ID, nftID, interactionType, weight)
+	if err := repository.NewInteractionRepository().Record(interaction); err != nil {
+		return nil, err
+	}
+	
+	return map[string]interface{}{
+		"success":          true,
+		"nft_id":           nftID,
+		"interaction_type": interactionType,
+	}, nil
+}
+
+// authenticatedUser returns the user of the wallet the request is
+// authenticated as
+func authenticatedUser(ctx *gofr.Context) (*models.User, error) {
+	wallet, ok := middleware.WalletFromContext(ctx)
+	if !ok {
+		return nil, middleware.ErrUnauthenticated
+	}
+	user, err := repository.NewUserRepository().GetByWalletAddress(wallet)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+// AI Recommendation Handlers
+func getRecommendations(ctx *gofr.Context) (interface{}, error) {
+	user, err := recommendationUser(ctx.PathParam("userId"))
+	if err != nil {
+		return nil, err
+	}
+	
+	// Served from readCache, then the recommendations table, until they expire
+	return cache.Fetch(ctx, readCache, cache.RecommendationsKey(user.ID), cacheTTLs.Recommendations, func() ([]*models.Recommendation, error) {
+		return recommender.Recommend(ctx, user, 10)
+	})
+}
+
+func explainRecommendation(ctx *gofr.Context) (interface{}, error) {
+	user, err := recommendationUser(ctx.PathParam("userId"))
+	if err != nil {
+		return nil, err
+	}
+	nftID, err := uuid.Parse(ctx.PathParam("nftId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NFT ID")
+	}
+	
+	if _, ok := aiEngine.Healthy(ctx); !ok {
+		return nil, fmt.Errorf("AI engine unavailable")
+	}
+	return aiEngine.Explain(ctx, user.ID.String(), nftID.String())
+}
+
+// recommendationUser looks up a user by ID or wallet address
+func recommendationUser(idOrAddress string) (*models.User, error) {
+	userRepo := repository.NewUserRepository()
+	var user *models.User
+	var err error
+	if id, parseErr := uuid.Parse(idOrAddress); parseErr == nil {
+		user, err = userRepo.GetByID(id)
+	} else {
+		user, err = userRepo.GetByWalletAddress(idOrAddress)
+	}
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+func trainRecommendationModel(ctx *gofr.Context) (interface{}, error) {
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	job := models.NewTrainingJob(ctx.Param("force") == "true")
+	job.RequestedBy = &user.ID
+	
+	trainingJobs := repository.NewTrainingJobRepository()
+	if err := trainingJobs.Create(job); err != nil {
+		if !errors.Is(err, repository.ErrTrainingJobActive) {
+			return nil, err
+		}
+		// Join the training that is already under way
+		active, err := trainingJobs.GetActive()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"success": true,
+			"message": "Recommendation model training already in progress",
+			"job_id":  active.ID,
+			"status":  active.Status,
+		}, nil
+	}
+	trainingWorker.Notify()
+	
+	return map[string]interface{}{
+		"success": true,
+		"message": "Recommendation model training queued",
+		"job_id":  job.ID,
+		"status":  job.Status,
+	}, nil
+}
+
+func getTrainingJob(ctx *gofr.Context) (interface{}, error) {
+	id, err := uuid.Parse(ctx.PathParam("jobId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid training job ID")
+	}
+	
+	return repository.NewTrainingJobRepository().GetByID(id)
+}
 
 // Marketplace Handlers
 func listNFTForSale(ctx *gofr.Context) (interface{}, error) {
 	var listingRequest struct {
-		NFTID  string  `json:"nft_id"`
-		Price  float64 `json:"price"`
-		Seller string  `json:"seller"`
+		NFTID    string  `json:"nft_id"`
+		Price    float64 `json:"price"`
+		Currency string  `json:"currency"`
+		Seller   string  `json:"seller"`
 	}
 
 	if err := ctx.Bind(&listingRequest); err != nil {
 		return nil, err
 	}
+	if err := middleware.RequireWallet(ctx, listingRequest.Seller); err != nil {
+		return nil, err
+	}
 
 	// Parse NFT ID
 	nftID, err := uuid.Parse(listingRequest.NFTID)
@@ -459,11 +1404,25 @@ func listNFTForSale(ctx *gofr.Context) (interface{}, error) {
 	if nft.OwnerID != seller.ID {
 		return nil, fmt.Errorf("you don't own this NFT")
 	}
+	if nft.MintStatus == models.TransactionFailed {
+		return nil, fmt.Errorf("NFT mint failed on chain")
+	}
+
+	if listingRequest.Price <= 0 {
+		return nil, fmt.Errorf("price must be greater than zero")
+	}
 
 	// Create listing
 	listing := models.NewMarketplaceListing(nftID, seller.ID, listingRequest.Price)
-	
-	// TODO: Save listing to database when marketplace repository is created
+	if listingRequest.Currency != "" {
+		listing.Currency = listingRequest.Currency
+	}
+
+	marketRepo := repository.NewMarketplaceRepository()
+	if err := marketRepo.Create(listing); err != nil {
+		return nil, err
+	}
+	readCache.InvalidateNFTs(ctx, nftID)
 	
 	return map[string]interface{}{
 		"success":    true,
@@ -474,53 +1433,315 @@ func listNFTForSale(ctx *gofr.Context) (interface{}, error) {
 
 func buyNFT(ctx *gofr.Context) (interface{}, error) {
 	var purchaseRequest struct {
-		NFTID  string `json:"nft_id"`
-		Buyer  string `json:"buyer"`
-		Price  string `json:"price"`
+		NFTID           string `json:"nft_id"`
+		Buyer           string `json:"buyer"`
+		Price           string `json:"price"`
+		TransactionHash string `json:"transaction_hash"`
 	}
 
 	if err := ctx.Bind(&purchaseRequest); err != nil {
 		return nil, err
 	}
+	if err := middleware.RequireWallet(ctx, purchaseRequest.Buyer); err != nil {
+		return nil, err
+	}
+
+	nftID, err := uuid.Parse(purchaseRequest.NFTID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NFT ID")
+	}
+	offeredPrice, err := strconv.ParseFloat(purchaseRequest.Price, 64)
+	if err != nil || offeredPrice <= 0 {
+		return nil, fmt.Errorf("invalid price")
+	}
+	// The payment must already be on chain; the sale is settled or undone
+	// once it confirms
+	if !services.IsTxHash(purchaseRequest.TransactionHash) {
+		return nil, fmt.Errorf("transaction_hash must be the 0x-prefixed hash of the payment")
+	}
+	paymentHash := strings.ToLower(purchaseRequest.TransactionHash)
+
+	// Get or create buyer
+	userRepo := repository.NewUserRepository()
+	buyer, err := userRepo.GetByWalletAddress(purchaseRequest.Buyer)
+	if err != nil {
+		return nil, err
+	}
+	if buyer == nil {
+		buyer = models.NewUser(purchaseRequest.Buyer)
+		if err := userRepo.Create(buyer); err != nil {
+			return nil, err
+		}
+	}
+
+	var listing *models.MarketplaceListing
+	purchase := models.NewTransaction("purchase", nftID)
+
+	// Claim the listing, transfer ownership and record the sale atomically
+	err = database.Transaction(func(tx *sqlx.Tx) error {
+		marketRepo := repository.NewMarketplaceRepository().WithTx(tx)
+		locked, err := marketRepo.LockActiveByNFT(nftID)
+		if err != nil {
+			return err
+		}
+		listing = locked
+		if listing.SellerID == buyer.ID {
+			return fmt.Errorf("you can't buy your own NFT")
+		}
+		if listing.NFT.OwnerID != listing.SellerID {
+			return fmt.Errorf("seller no longer owns this NFT")
+		}
+		if offeredPrice < listing.Price {
+			return fmt.Errorf("offered price %v is below listing price %v", offeredPrice, listing.Price)
+		}
+
+		if err := marketRepo.MarkSold(listing.ID, buyer.ID); err != nil {
+			return err
+		}
+
+		nftRepo := repository.NewNFTRepository().WithTx(tx)
+		if err := nftRepo.UpdateOwner(nftID, buyer.ID); err != nil {
+			return err
+		}
+
+		// Record the sale; it stays pending until the payment is seen on chain
+		purchase.FromUserID = &listing.SellerID
+		purchase.ToUserID = &buyer.ID
+		purchase.Price = &listing.Price
+		purchase.TransactionHash = &paymentHash
+		txRepo := repository.NewTransactionRepository().WithTx(tx)
+		used, err := txRepo.HashUsed(paymentHash)
+		if err != nil {
+			return err
+		}
+		if used {
+			return repository.ErrTransactionHashUsed
+		}
+		if err := txRepo.Create(purchase); err != nil {
+			return err
+		}
+
+		interaction := models.NewUserInteraction(buyer.ID, nftID, models.InteractionPurchase, models.InteractionWeightPurchase)
+		interactionRepo := repository.NewInteractionRepository().WithTx(tx)
+		return interactionRepo.Record(interaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	readCache.InvalidateNFTs(ctx, nftID)
 
-	// TODO: Process NFT purchase
 	return map[string]interface{}{
-		"success": true,
-		"transaction_hash": "0xdef456...",
-		"message": "NFT purchased successfully",
+		"success":          true,
+		"listing_id":       listing.ID,
+		"transaction_id":   purchase.ID,
+		"transaction_hash": purchase.TransactionHash,
+		"price":            listing.Price,
+		"currency":         listing.Currency,
+		"status":           purchase.Status,
+		"message":          "NFT purchased successfully",
 	}, nil
 }
 
 func getMarketplaceListings(ctx *gofr.Context) (interface{}, error) {
-	// TODO: Get active marketplace listings
-	return []map[string]interface{}{
-		{
-			"id":     "listing_1",
-			"nft_id": "nft_1",
-			"price":  "0.5",
-			"seller": "0x789...",
-			"status": "active",
-		},
+	page, err := pageRequest(ctx, "listings")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repository.ListingFilter{
+		Status:   "active",
+		Chain:    ctx.Param("chain"),
+		Currency: ctx.Param("currency"),
+		Page:     page,
+	}
+	if status := ctx.Param("status"); status != "" {
+		filter.Status = status
+	}
+
+	// Parse price range
+	if p := ctx.Param("min_price"); p != "" {
+		minPrice, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_price")
+		}
+		filter.MinPrice = &minPrice
+	}
+	if p := ctx.Param("max_price"); p != "" {
+		maxPrice, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_price")
+		}
+		filter.MaxPrice = &maxPrice
+	}
+
+	// Filter by seller wallet
+	if address := ctx.Param("seller"); address != "" {
+		userRepo := repository.NewUserRepository()
+		seller, err := userRepo.GetByWalletAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		if seller == nil {
+			return pageResponse("listings", []*models.MarketplaceListing{}, page, repository.Page{}), nil
+		}
+		filter.SellerID = &seller.ID
+	}
+
+	marketRepo := repository.NewMarketplaceRepository()
+	listings, cursors, err := marketRepo.GetListings(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return pageResponse("listings", listings, page, cursors), nil
+}
+
+func getMarketplaceListing(ctx *gofr.Context) (interface{}, error) {
+	listingID, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid listing ID")
+	}
+
+	marketRepo := repository.NewMarketplaceRepository()
+	return marketRepo.GetByID(listingID)
+}
+
+func updateListingPrice(ctx *gofr.Context) (interface{}, error) {
+	var updateRequest struct {
+		Price  float64 `json:"price"`
+		Seller string  `json:"seller"`
+	}
+
+	if err := ctx.Bind(&updateRequest); err != nil {
+		return nil, err
+	}
+
+	listingID, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid listing ID")
+	}
+	if updateRequest.Price <= 0 {
+		return nil, fmt.Errorf("price must be greater than zero")
+	}
+	if err := middleware.RequireWallet(ctx, updateRequest.Seller); err != nil {
+		return nil, err
+	}
+
+	userRepo := repository.NewUserRepository()
+	seller, err := userRepo.GetByWalletAddress(updateRequest.Seller)
+	if err != nil || seller == nil {
+		return nil, fmt.Errorf("seller not found")
+	}
+
+	marketRepo := repository.NewMarketplaceRepository()
+	if err := marketRepo.UpdatePrice(listingID, seller.ID, updateRequest.Price); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"listing_id": listingID,
+		"price":      updateRequest.Price,
+		"message":    "Listing price updated successfully",
+	}, nil
+}
+
+func cancelListing(ctx *gofr.Context) (interface{}, error) {
+	var cancelRequest struct {
+		Seller string `json:"seller"`
+	}
+
+	if err := ctx.Bind(&cancelRequest); err != nil {
+		return nil, err
+	}
+	if err := middleware.RequireWallet(ctx, cancelRequest.Seller); err != nil {
+		return nil, err
+	}
+
+	listingID, err := uuid.Parse(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid listing ID")
+	}
+
+	userRepo := repository.NewUserRepository()
+	seller, err := userRepo.GetByWalletAddress(cancelRequest.Seller)
+	if err != nil || seller == nil {
+		return nil, fmt.Errorf("seller not found")
+	}
+
+	marketRepo := repository.NewMarketplaceRepository()
+	if err := marketRepo.Cancel(listingID, seller.ID); err != nil {
+		return nil, err
+	}
+	readCache.Delete(ctx, cache.StatsKey)
+
+	return map[string]interface{}{
+		"success":    true,
+		"listing_id": listingID,
+		"message":    "Listing cancelled successfully",
 	}, nil
 }
 
+// maxTrendingLimit caps the trending NFTs served per request
+const maxTrendingLimit = 100
+
 // Analytics Handlers
+// getTrendingNFTs serves the trending ranks the trending refresher
+// precomputed for ?window= (1h, 24h, 7d or 30d)
 func getTrendingNFTs(ctx *gofr.Context) (interface{}, error) {
-	nftRepo := repository.NewNFTRepository()
-	trendingNFTs, err := nftRepo.GetTrending(10)
+	period := ctx.Param("window")
+	if period == "" {
+		period = "7d"
+	}
+	if !repository.IsTrendingPeriod(period) {
+		return nil, fmt.Errorf("invalid window, want 1h, 24h, 7d or 30d")
+	}
+	limit := 10
+	if v := ctx.Param("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			return nil, fmt.Errorf("invalid limit")
+		}
+		limit = min(parsed, maxTrendingLimit)
+	}
+	
+	// The top maxTrendingLimit are cached per window and cut to the limit
+	trendingNFTs, err := cache.Fetch(ctx, readCache, cache.TrendingKey(period), cacheTTLs.Trending, func() ([]*models.TrendingNFT, error) {
+		return repository.NewTrendingRepository().Get(period, maxTrendingLimit)
+	})
 	if err != nil {
 		return nil, err
 	}
+	trendingNFTs = trendingNFTs[:min(limit, len(trendingNFTs))]
 	
-	return map[string]interface{}{
+	response := map[string]interface{}{
 		"trending": trendingNFTs,
-		"period":   "7_days",
-	}, nil
+		"period":   period,
+	}
+	if len(trendingNFTs) > 0 {
+		response["computed_at"] = trendingNFTs[0].ComputedAt
+	}
+	return response, nil
 }
 
+// getMarketplaceStats serves the marketplace figures. Figures that fail to
+// load are logged and reported as zero; such partial stats aren't cached.
 func getMarketplaceStats(ctx *gofr.Context) (interface{}, error) {
+	stats, err := cache.Fetch(ctx, readCache, cache.StatsKey, cacheTTLs.Stats, func() (map[string]interface{}, error) {
+		return marketplaceStats(ctx)
+	})
+	if err != nil {
+		ctx.Logger.Errorf("serving partial stats: %v", err)
+	}
+	return stats, nil
+}
+
+// marketplaceStats computes the marketplace figures, returning the failures
+// along with the figures that did load
+func marketplaceStats(ctx *gofr.Context) (map[string]interface{}, error) {
 	// Get statistics from database
 	stats := make(map[string]interface{})
+	var failures []error
 	
 	// Get counts
 	var counts struct {
@@ -536,14 +1757,197 @@ func getMarketplaceStats(ctx *gofr.Context) (interface{}, error) {
 	err := database.DB.Get(&counts, query)
 	if err != nil {
 		ctx.Logger.Errorf("failed to get stats: %v", err)
+		failures = append(failures, err)
+	}
+	
+	// Get active listings
+	marketRepo := repository.NewMarketplaceRepository()
+	activeListings, err := marketRepo.CountActive()
+	if err != nil {
+		ctx.Logger.Errorf("failed to count active listings: %v", err)
+		failures = append(failures, err)
+	}
+	
+	// Volume and 24h activity are computed live from the transactions
+	market, err := repository.NewAnalyticsRepository().GetMarketStats()
+	if err != nil {
+		ctx.Logger.Errorf("failed to get market stats: %v", err)
+		failures = append(failures, err)
+		market = &repository.MarketStats{}
 	}
 	
 	stats["total_nfts"] = counts.TotalNFTs
 	stats["total_users"] = counts.TotalUsers
-	stats["total_volume"] = "0" // TODO: Calculate from transactions
-	stats["active_listings"] = 0 // TODO: Get from marketplace_listings
-	stats["24h_volume"] = "0"    // TODO: Calculate from recent transactions
+	stats["total_volume"] = market.TotalVolume
+	stats["total_sales"] = market.TotalSales
+	stats["average_sale_price"] = market.AverageSalePrice
+	stats["active_listings"] = activeListings
+	stats["24h_volume"] = market.Volume24h
+	stats["24h_sales"] = market.Sales24h
+	stats["24h_transactions"] = market.Transactions24h
+	stats["24h_active_users"] = market.ActiveUsers24h
 	stats["chain"] = "polygonAmoy"
 	
-	return stats, nil
+	return stats, errors.Join(failures...)
+}
+
+// maxAnalyticsDays caps the date range of the analytics time series
+const maxAnalyticsDays = 366
+
+// analyticsRange reads the from and to dates (YYYY-MM-DD, inclusive) of an
+// analytics request, defaulting to the last 30 days
+func analyticsRange(ctx *gofr.Context) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if v := ctx.Param("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to date, want YYYY-MM-DD")
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -29)
+	if v := ctx.Param("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from date, want YYYY-MM-DD")
+		}
+		from = parsed
+	}
+	
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	if to.Sub(from) >= maxAnalyticsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range must not exceed %d days", maxAnalyticsDays)
+	}
+	return from, to, nil
+}
+
+func getDailyAnalytics(ctx *gofr.Context) (interface{}, error) {
+	from, to, err := analyticsRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	days, err := repository.NewAnalyticsRepository().GetRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	
+	return map[string]interface{}{
+		"from": from.Format("2006-01-02"),
+		"to":   to.Format("2006-01-02"),
+		"days": days,
+	}, nil
+}
+
+// analyticsMetrics are the daily figures getAnalyticsSeries can chart
+var analyticsMetrics = map[string]func(*models.Analytics) float64{
+	"total_users":        func(a *models.Analytics) float64 { return float64(a.TotalUsers) },
+	"total_nfts":         func(a *models.Analytics) float64 { return float64(a.TotalNFTs) },
+	"total_transactions": func(a *models.Analytics) float64 { return float64(a.TotalTransactions) },
+	"total_volume":       func(a *models.Analytics) float64 { return a.TotalVolume },
+	"active_users":       func(a *models.Analytics) float64 { return float64(a.ActiveUsers) },
+	"new_users":          func(a *models.Analytics) float64 { return float64(a.NewUsers) },
+}
+
+// getAnalyticsSeries returns one metric of the daily rollup as a time series
+func getAnalyticsSeries(ctx *gofr.Context) (interface{}, error) {
+	metric := ctx.PathParam("metric")
+	value, ok := analyticsMetrics[metric]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", metric)
+	}
+	from, to, err := analyticsRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	days, err := repository.NewAnalyticsRepository().GetRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	
+	type point struct {
+		Date  string  `json:"date"`
+		Value float64 `json:"value"`
+	}
+	points := make([]point, len(days))
+	for i, day := range days {
+		points[i] = point{Date: day.Date.Format("2006-01-02"), Value: value(day)}
+	}
+	
+	return map[string]interface{}{
+		"metric": metric,
+		"from":   from.Format("2006-01-02"),
+		"to":     to.Format("2006-01-02"),
+		"series": points,
+	}, nil
+}
+
+// Feature Flag Handlers
+// getFeatures reports which features are on for the caller, so clients can
+// hide what they can't use
+func getFeatures(ctx *gofr.Context) (interface{}, error) {
+	wallet, _ := middleware.WalletFromContext(ctx)
+	
+	features := make(map[string]bool)
+	for _, flag := range featureFlags.All() {
+		features[flag.Name] = featureFlags.Enabled(flag.Name, wallet)
+	}
+	return features, nil
+}
+
+func getFeatureFlags(ctx *gofr.Context) (interface{}, error) {
+	if err := admins.Require(ctx); err != nil {
+		return nil, err
+	}
+	
+	return featureFlags.All(), nil
+}
+
+// updateFeatureFlag overrides a feature's flag; it takes effect here at once
+// and on other instances within FEATURE_FLAGS_REFRESH_INTERVAL
+func updateFeatureFlag(ctx *gofr.Context) (interface{}, error) {
+	var flagRequest struct {
+		Enabled        *bool    `json:"enabled"`
+		AllowedWallets []string `json:"allowed_wallets"`
+	}
+	
+	if err := ctx.Bind(&flagRequest); err != nil {
+		return nil, err
+	}
+	if err := admins.Require(ctx); err != nil {
+		return nil, err
+	}
+	if flagRequest.Enabled == nil {
+		return nil, fmt.Errorf("enabled is required")
+	}
+	
+	wallet, _ := middleware.WalletFromContext(ctx)
+	flag := &models.FeatureFlag{
+		Name:           ctx.PathParam("name"),
+		Enabled:        *flagRequest.Enabled,
+		AllowedWallets: flagRequest.AllowedWallets,
+		UpdatedBy:      &wallet,
+	}
+	if err := featureFlags.Set(flag); err != nil {
+		return nil, err
+	}
+	
+	return flag, nil
+}
+
+// resetFeatureFlag drops a feature's override, restoring its ENABLE_* default
+func resetFeatureFlag(ctx *gofr.Context) (interface{}, error) {
+	if err := admins.Require(ctx); err != nil {
+		return nil, err
+	}
+	
+	name := ctx.PathParam("name")
+	if err := featureFlags.Reset(name); err != nil {
+		return nil, err
+	}
+	return featureFlags.Get(name), nil
 }
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"nftgenie/backend/services"
+
+	gofrerrors "gofr.dev/pkg/errors"
+)
+
+// Handlers return these as gofr response errors so they are answered with
+// their status code rather than a 500
+var (
+	// ErrUnauthenticated is returned when a request has no valid token
+	ErrUnauthenticated = &gofrerrors.Response{
+		StatusCode: http.StatusUnauthorized,
+		Code:       "unauthorized",
+		Reason:     "authentication required",
+	}
+	// ErrForbidden is returned when the token wallet is not the acting wallet
+	ErrForbidden = &gofrerrors.Response{
+		StatusCode: http.StatusForbidden,
+		Code:       "forbidden",
+		Reason:     "wallet does not match authenticated user",
+	}
+)
+
+// walletKey is the request context key holding the authenticated wallet
+type walletKey struct{}
+
+// Auth verifies bearer access tokens and stores the authenticated wallet on
+// the request context. Mutating requests must carry a valid token unless
+// their path is listed as public, where a {name} segment matches any one
+// segment; reads pass through anonymously.
+func Auth(tokens *services.TokenService, publicPaths ...string) func(http.Handler) http.Handler {
+	isPublic := func(r *http.Request) bool {
+		for _, p := range publicPaths {
+			if matchRoute("* "+p, r.Method, r.URL.Path) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := isMutating(r.Method) && !isPublic(r)
+
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				if required {
+					writeError(w, http.StatusUnauthorized, "unauthorized", ErrUnauthenticated.Error())
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "malformed authorization header")
+				return
+			}
+
+			claims, err := tokens.Parse(token, services.AccessToken)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "unauthorized", err.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithWallet(r.Context(), claims.Wallet)))
+		})
+	}
+}
+
+// WithWallet returns a copy of ctx carrying an authenticated wallet
+func WithWallet(ctx context.Context, wallet string) context.Context {
+	return context.WithValue(ctx, walletKey{}, wallet)
+}
+
+// WalletFromContext returns the authenticated wallet, if any
+func WalletFromContext(ctx context.Context) (string, bool) {
+	wallet, ok := ctx.Value(walletKey{}).(string)
+	return wallet, ok && wallet != ""
+}
+
+// RequireWallet checks that the authenticated wallet is the acting address
+func RequireWallet(ctx context.Context, address string) error {
+	wallet, ok := WalletFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if !strings.EqualFold(wallet, address) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// isMutating reports whether the method changes server state
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nftgenie/backend/services"
+
+	"github.com/google/uuid"
+	gofrerrors "gofr.dev/pkg/errors"
+)
+
+func newTestTokens() *services.TokenService {
+	return &services.TokenService{
+		Secret:        []byte("auth-test-secret-with-at-least-32-chars"),
+		AccessExpiry:  time.Hour,
+		RefreshExpiry: 24 * time.Hour,
+		Issuer:        "nftgenie",
+	}
+}
+
+// echoWallet answers 200 with the authenticated wallet, if any
+var echoWallet = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	wallet, _ := WalletFromContext(r.Context())
+	w.Write([]byte(wallet))
+})
+
+func serveAuth(handler http.Handler, method, path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuth(t *testing.T) {
+	tokens := newTestTokens()
+	handler := Auth(tokens, "/api/users/connect", "/api/users/{address}/nonce")(echoWallet)
+
+	pair, err := tokens.IssueTokens(uuid.New(), "0xabc")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	// Reads pass anonymously; mutations need a token unless public
+	if rec := serveAuth(handler, "GET", "/api/nfts", ""); rec.Code != http.StatusOK || rec.Body.String() != "" {
+		t.Errorf("anonymous read: %d %q", rec.Code, rec.Body)
+	}
+	for _, path := range []string{"/api/users/connect", "/api/users/0xabc/nonce"} {
+		if rec := serveAuth(handler, "POST", path, ""); rec.Code != http.StatusOK {
+			t.Errorf("anonymous POST %s: %d, want 200", path, rec.Code)
+		}
+	}
+	rec := serveAuth(handler, "POST", "/api/nfts/mint", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous mint: %d, want 401", rec.Code)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error.Code != "unauthorized" {
+		t.Errorf("body = %+v, %v", body, err)
+	}
+
+	// A valid access token authenticates reads and mutations alike
+	for _, method := range []string{"GET", "POST"} {
+		if rec := serveAuth(handler, method, "/api/nfts/mint", "Bearer "+pair.AccessToken); rec.Code != http.StatusOK || rec.Body.String() != "0xabc" {
+			t.Errorf("%s with a token: %d %q, want 200 as 0xabc", method, rec.Code, rec.Body)
+		}
+	}
+
+	// A bad token is refused even on reads rather than silently dropped
+	for name, authorization := range map[string]string{
+		"refresh token": "Bearer " + pair.RefreshToken,
+		"not bearer":    "Basic " + pair.AccessToken,
+		"garbage":       "Bearer nope",
+	} {
+		if rec := serveAuth(handler, "GET", "/api/nfts", authorization); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: %d, want 401", name, rec.Code)
+		}
+	}
+}
+
+func TestRequireWallet(t *testing.T) {
+	ctx := WithWallet(context.Background(), "0xabcdef")
+
+	if err := RequireWallet(ctx, "0xABCDEF"); err != nil {
+		t.Errorf("same wallet in another case: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   error
+		status int
+	}{
+		{"anonymous", context.Background(), ErrUnauthenticated, http.StatusUnauthorized},
+		{"other wallet", WithWallet(context.Background(), "0x123456"), ErrForbidden, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RequireWallet(tt.ctx, "0xabcdef")
+			var response *gofrerrors.Response
+			if !errors.Is(err, tt.want) || !errors.As(err, &response) || response.StatusCode != tt.status {
+				t.Errorf("err = %v, want %v answered with %d", err, tt.want, tt.status)
+			}
+		})
+	}
+}
+
+func TestAdminsRequire(t *testing.T) {
+	t.Setenv("ADMIN_WALLETS", " 0xADMIN , ,0xother")
+	admins := AdminsFromEnv()
+
+	if err := admins.Require(WithWallet(context.Background(), "0xadmin")); err != nil {
+		t.Errorf("admin: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+	}{
+		{"anonymous", context.Background(), http.StatusUnauthorized},
+		{"not an admin", WithWallet(context.Background(), "0xnobody"), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response *gofrerrors.Response
+			if err := admins.Require(tt.ctx); !errors.As(err, &response) || response.StatusCode != tt.status {
+				t.Errorf("err = %v, want one answered with %d", err, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"nftgenie/backend/models"
+	"nftgenie/backend/services"
+
+	"github.com/lib/pq"
+)
+
+// flagStore is a FeatureFlagStore holding overrides in memory
+type flagStore map[string]*models.FeatureFlag
+
+func (s flagStore) GetAll() ([]*models.FeatureFlag, error) {
+	flags := make([]*models.FeatureFlag, 0, len(s))
+	for _, flag := range s {
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+func (s flagStore) Upsert(flag *models.FeatureFlag) error {
+	s[flag.Name] = flag
+	return nil
+}
+
+func (s flagStore) Delete(name string) (bool, error) {
+	_, ok := s[name]
+	delete(s, name)
+	return ok, nil
+}
+
+func newGatedHandler(flags *services.FeatureFlags, routes map[string][]string) http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return FeatureGate(flags, routes)(ok)
+}
+
+func TestFeatureGate(t *testing.T) {
+	flags := services.NewFeatureFlagsWithStore(flagStore{})
+	handler := newGatedHandler(flags, FeatureRoutes)
+
+	// Everything passes while the features have their default
+	for _, route := range []string{"POST /api/nfts/mint", "GET /api/marketplace/listings/1", "GET /api/nfts"} {
+		method, path, _ := strings.Cut(route, " ")
+		if rec := serve(handler, method, path, "203.0.113.1:1000", ""); rec.Code != http.StatusNoContent {
+			t.Errorf("%s with defaults: %d, want 204", route, rec.Code)
+		}
+	}
+
+	err := flags.Set(&models.FeatureFlag{
+		Name:           services.FeatureMinting,
+		AllowedWallets: pq.StringArray{"0xbeta"},
+	})
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	rec := serve(handler, "POST", "/api/nfts/mint", "203.0.113.1:1000", "0xother")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("mint while disabled: %d, want 503", rec.Code)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error.Code != "feature_disabled" || body.Error.Feature != services.FeatureMinting || body.Error.Message != "minting is currently disabled" {
+		t.Errorf("body = %+v", body)
+	}
+	if rec := serve(handler, "POST", "/api/nfts/mint", "203.0.113.1:1000", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("anonymous mint while disabled: %d, want 503", rec.Code)
+	}
+
+	// The allowlisted wallet still mints, and other features and the job
+	// status route are untouched
+	if rec := serve(handler, "POST", "/api/nfts/mint", "203.0.113.1:1000", "0xBETA"); rec.Code != http.StatusNoContent {
+		t.Errorf("allowed wallet: %d, want 204", rec.Code)
+	}
+	if rec := serve(handler, "GET", "/api/mints/1", "203.0.113.1:1000", "0xother"); rec.Code != http.StatusNoContent {
+		t.Errorf("mint status while disabled: %d, want 204", rec.Code)
+	}
+	if rec := serve(handler, "POST", "/api/marketplace/buy", "203.0.113.1:1000", "0xother"); rec.Code != http.StatusNoContent {
+		t.Errorf("marketplace while minting is disabled: %d, want 204", rec.Code)
+	}
+}
+
+func TestFeatureGateUnknownFeature(t *testing.T) {
+	flags := services.NewFeatureFlagsWithStore(flagStore{})
+	handler := newGatedHandler(flags, map[string][]string{"teleport": {"POST /api/teleport"}})
+
+	// A route gated by a feature with no flag stays closed
+	rec := serve(handler, "POST", "/api/teleport", "203.0.113.1:1000", "0xabc")
+	var body errorResponse
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusServiceUnavailable || body.Error.Feature != "teleport" {
+		t.Errorf("unknown feature: %d %+v, want a 503 for teleport", rec.Code, body)
+	}
+	if rec := serve(handler, "GET", "/api/teleport", "203.0.113.1:1000", "0xabc"); rec.Code != http.StatusNoContent {
+		t.Errorf("other method: %d, want 204", rec.Code)
+	}
+}
@@ -0,0 +1,303 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nftgenie/backend/cache"
+	"nftgenie/backend/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimitPolicy allows Requests per Window on its routes. A route is
+// "METHOD /path", where a method of * matches any method and a {name} path
+// segment matches any one segment; a policy without routes matches every
+// request.
+type RateLimitPolicy struct {
+	Name     string
+	Routes   []string
+	Requests int
+	Window   time.Duration
+}
+
+// RateLimitConfig is the policies a request is limited by: the first whose
+// routes match it
+type RateLimitConfig struct {
+	Policies []RateLimitPolicy
+	// TrustProxy keys anonymous clients by the last X-Forwarded-For address,
+	// the one the proxy in front of the backend appended, instead of the
+	// connection's; only enable it behind exactly one such proxy
+	TrustProxy bool
+}
+
+// RateLimitConfigFromEnv reads the RATE_LIMIT_* environment. Minting spends
+// Verbwire credits and login endpoints invite guessing, so both get tighter
+// policies than the RATE_LIMIT_REQUESTS per RATE_LIMIT_DURATION default.
+func RateLimitConfigFromEnv() RateLimitConfig {
+	return RateLimitConfig{
+		Policies: []RateLimitPolicy{
+			{
+				Name:     "mint",
+				Routes:   []string{"POST /api/nfts/mint"},
+				Requests: config.Int("RATE_LIMIT_MINT_REQUESTS", 5),
+				Window:   config.Duration("RATE_LIMIT_MINT_DURATION", time.Minute),
+			},
+			{
+				Name: "auth",
+				Routes: []string{
+					"POST /api/users/{address}/nonce",
+					"POST /api/users/connect",
+					"POST /api/auth/refresh",
+				},
+				Requests: config.Int("RATE_LIMIT_AUTH_REQUESTS", 10),
+				Window:   config.Duration("RATE_LIMIT_AUTH_DURATION", time.Minute),
+			},
+			{
+				Name:     "default",
+				Requests: config.Int("RATE_LIMIT_REQUESTS", 100),
+				Window:   config.Duration("RATE_LIMIT_DURATION", time.Minute),
+			},
+		},
+		TrustProxy: config.Bool("RATE_LIMIT_TRUST_PROXY", false),
+	}
+}
+
+// RateLimitStore counts requests per key in consecutive fixed windows
+type RateLimitStore interface {
+	// Increment counts a request in key's window starting at start and
+	// returns the counts of that window and of the window before it
+	Increment(ctx context.Context, key string, start time.Time, window time.Duration) (current, previous int, err error)
+}
+
+// NewRateLimitStore creates the store RATE_LIMIT_STORE selects: memory (the
+// default, per instance) or redis at REDIS_HOST (shared between instances)
+func NewRateLimitStore() (RateLimitStore, error) {
+	switch store := config.String("RATE_LIMIT_STORE", "memory"); store {
+	case "memory":
+		return NewMemoryRateLimitStore(), nil
+	case "redis":
+		return NewRedisRateLimitStore(cache.NewRedis(cache.RedisConfigFromEnv())), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", store)
+	}
+}
+
+// RateLimit limits requests per policy and client, where the client is the
+// authenticated wallet or else the client IP, so it must run after Auth.
+// Counts are kept with a sliding window: the previous window's count weighs
+// in proportion to how much of it the sliding window still covers. Limited
+// responses carry RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers; requests over the limit get a 429 with
+// Retry-After. Rejected requests count too, so a client retrying in a loop
+// stays limited. When the store fails, requests are let through.
+func RateLimit(store RateLimitStore, config RateLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := matchPolicy(config.Policies, r)
+			if policy == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now()
+			start := now.Truncate(policy.Window)
+			key := "ratelimit:" + policy.Name + ":" + rateLimitClient(r, config.TrustProxy)
+			current, previous, err := store.Increment(r.Context(), key, start, policy.Window)
+			if err != nil {
+				log.Printf("rate limit: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The share of the previous window the sliding window still covers
+			overlap := 1 - float64(now.Sub(start))/float64(policy.Window)
+			used := int(math.Floor(float64(previous)*overlap)) + current
+			reset := int(math.Ceil(start.Add(policy.Window).Sub(now).Seconds()))
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(policy.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(max(policy.Requests-used, 0)))
+			h.Set("RateLimit-Reset", strconv.Itoa(reset))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Requests, int(policy.Window.Seconds())))
+
+			if used > policy.Requests {
+				h.Set("Retry-After", strconv.Itoa(reset))
+				writeError(w, http.StatusTooManyRequests, "rate_limited",
+					fmt.Sprintf("rate limit of %d requests per %s exceeded", policy.Requests, policy.Window))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matchPolicy returns the first policy matching the request
+func matchPolicy(policies []RateLimitPolicy, r *http.Request) *RateLimitPolicy {
+	for i := range policies {
+		if len(policies[i].Routes) == 0 {
+			return &policies[i]
+		}
+		for _, route := range policies[i].Routes {
+			if matchRoute(route, r.Method, r.URL.Path) {
+				return &policies[i]
+			}
+		}
+	}
+	return nil
+}
+
+// matchRoute reports whether a "METHOD /path" route matches the request
+func matchRoute(route, method, path string) bool {
+	routeMethod, routePath, _ := strings.Cut(route, " ")
+	if routeMethod != "*" && routeMethod != method {
+		return false
+	}
+
+	want := strings.Split(strings.Trim(routePath, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i, segment := range want {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if segment != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rateLimitClient identifies who a request counts against: the
+// authenticated wallet, else the client IP
+func rateLimitClient(r *http.Request, trustProxy bool) string {
+	if wallet, ok := WalletFromContext(r.Context()); ok {
+		return "wallet:" + strings.ToLower(wallet)
+	}
+	return "ip:" + ClientIP(r, trustProxy)
+}
+
+// ClientIP returns the address of the client that sent the request. With
+// trustProxy set it is the last X-Forwarded-For address, which the proxy
+// appended for the peer it saw; earlier entries come from the client and
+// can't be trusted. Otherwise it is the connection's address.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			forwarded := values[len(values)-1]
+			if client := strings.TrimSpace(forwarded[strings.LastIndex(forwarded, ",")+1:]); client != "" {
+				return client
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// MemoryRateLimitStore counts requests in the process's memory, so each
+// backend instance limits on its own
+type MemoryRateLimitStore struct {
+	mu         sync.Mutex
+	windows    map[string]*rateWindow
+	lastPruned time.Time
+}
+
+// rateWindow is a key's current and previous window counts
+type rateWindow struct {
+	start    time.Time
+	window   time.Duration
+	current  int
+	previous int
+}
+
+// NewMemoryRateLimitStore creates an in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// Increment counts a request in key's window starting at start
+func (s *MemoryRateLimitStore) Increment(ctx context.Context, key string, start time.Time, window time.Duration) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.windows[key]
+	switch {
+	case !ok:
+		w = &rateWindow{start: start, window: window}
+		s.windows[key] = w
+	case start.Equal(w.start.Add(window)):
+		w.start, w.previous, w.current = start, w.current, 0
+	case start.After(w.start):
+		w.start, w.previous, w.current = start, 0, 0
+	}
+	w.current++
+
+	if start.Sub(s.lastPruned) >= window {
+		s.prune(start)
+	}
+	return w.current, w.previous, nil
+}
+
+// prune forgets keys idle for two windows, whose counts no longer matter.
+// Call it with mu held.
+func (s *MemoryRateLimitStore) prune(now time.Time) {
+	for key, w := range s.windows {
+		if now.Sub(w.start) >= 2*w.window {
+			delete(s.windows, key)
+		}
+	}
+	s.lastPruned = now
+}
+
+// rateLimitScript increments the current window's counter, expiring it once
+// the next window no longer needs it, and reads the previous window's
+var rateLimitScript = redis.NewScript(`
+local current = redis.call('INCR', KEYS[1])
+if current == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+return {current, previous}`)
+
+// RedisRateLimitStore counts requests in Redis, shared by every backend
+// instance
+type RedisRateLimitStore struct {
+	redis *cache.Redis
+}
+
+// NewRedisRateLimitStore creates a rate limit store in Redis
+func NewRedisRateLimitStore(redis *cache.Redis) *RedisRateLimitStore {
+	return &RedisRateLimitStore{redis: redis}
+}
+
+// Increment counts a request in key's window starting at start
+func (s *RedisRateLimitStore) Increment(ctx context.Context, key string, start time.Time, window time.Duration) (int, int, error) {
+	windowKey := func(start time.Time) string {
+		return key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+	}
+	keys := []string{windowKey(start), windowKey(start.Add(-window))}
+
+	counts, err := rateLimitScript.Run(ctx, s.redis.Client(), keys, (2 * window).Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(counts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rate limit reply %v", counts)
+	}
+	return int(counts[0]), int(counts[1]), nil
+}
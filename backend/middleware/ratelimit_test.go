@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"nftgenie/backend/cache"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newRateLimitedHandler(store RateLimitStore, policies ...RateLimitPolicy) http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return RateLimit(store, RateLimitConfig{Policies: policies})(ok)
+}
+
+func serve(handler http.Handler, method, path, remoteAddr, wallet string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	if wallet != "" {
+		req = req.WithContext(WithWallet(req.Context(), wallet))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	handler := newRateLimitedHandler(NewMemoryRateLimitStore(),
+		RateLimitPolicy{Name: "default", Requests: 2, Window: time.Hour})
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		rec := serve(handler, "GET", "/api/nfts", "203.0.113.1:1000", "")
+		if rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Remaining") != wantRemaining {
+			t.Fatalf("request %d: %d with %s remaining, want 204 with %s", i, rec.Code, rec.Header().Get("RateLimit-Remaining"), wantRemaining)
+		}
+	}
+
+	rec := serve(handler, "GET", "/api/nfts", "203.0.113.1:2000", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Policy") != "2;w=3600" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	if retry, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retry < 1 || retry > 3600 {
+		t.Errorf("Retry-After = %q", rec.Header().Get("Retry-After"))
+	}
+
+	// Other clients have their own counts
+	if rec := serve(handler, "GET", "/api/nfts", "203.0.113.2:1000", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("another IP: %d, want 204", rec.Code)
+	}
+	if rec := serve(handler, "GET", "/api/nfts", "203.0.113.1:1000", "0xABC"); rec.Code != http.StatusNoContent {
+		t.Errorf("a wallet on the limited IP: %d, want 204", rec.Code)
+	}
+}
+
+func TestRateLimitRoutePolicies(t *testing.T) {
+	handler := newRateLimitedHandler(NewMemoryRateLimitStore(),
+		RateLimitPolicy{Name: "mint", Routes: []string{"POST /api/nfts/mint"}, Requests: 1, Window: time.Hour},
+		RateLimitPolicy{Name: "nonce", Routes: []string{"GET /api/users/{address}/nonce"}, Requests: 1, Window: time.Hour},
+	)
+
+	if rec := serve(handler, "POST", "/api/nfts/mint", "", "0xabc"); rec.Code != http.StatusNoContent {
+		t.Fatalf("first mint: %d", rec.Code)
+	}
+	if rec := serve(handler, "POST", "/api/nfts/mint", "", "0xABC"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second mint by the same wallet: %d, want 429", rec.Code)
+	}
+	if rec := serve(handler, "GET", "/api/users/0x1/nonce", "203.0.113.1:1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("nonce: %d, want 204 as mints count separately", rec.Code)
+	}
+	if rec := serve(handler, "GET", "/api/users/0x2/nonce", "203.0.113.1:1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("nonce of another address: %d, want 429", rec.Code)
+	}
+
+	// Requests no policy matches are not limited
+	for i := 0; i < 3; i++ {
+		if rec := serve(handler, "GET", "/api/nfts/mint", "", "0xabc"); rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("unmatched request: %d with headers %v", rec.Code, rec.Header())
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreSlides(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Minute)
+
+	store.Increment(ctx, "k", start, time.Minute)
+	store.Increment(ctx, "k", start, time.Minute)
+	if current, previous, _ := store.Increment(ctx, "k", start.Add(time.Minute), time.Minute); current != 1 || previous != 2 {
+		t.Errorf("next window = %d, %d, want 1, 2", current, previous)
+	}
+	if current, previous, _ := store.Increment(ctx, "k", start.Add(5*time.Minute), time.Minute); current != 1 || previous != 0 {
+		t.Errorf("after a gap = %d, %d, want 1, 0", current, previous)
+	}
+}
+
+func TestRedisRateLimitStoreSlides(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := NewRedisRateLimitStore(cache.NewRedis(cache.RedisConfig{Addr: server.Addr(), Timeout: time.Second}))
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Minute)
+
+	for want := 1; want <= 2; want++ {
+		if current, previous, err := store.Increment(ctx, "k", start, time.Minute); err != nil || current != want || previous != 0 {
+			t.Fatalf("request %d = %d, %d, %v, want %d, 0", want, current, previous, err, want)
+		}
+	}
+	if current, previous, _ := store.Increment(ctx, "k", start.Add(time.Minute), time.Minute); current != 1 || previous != 2 {
+		t.Errorf("next window = %d, %d, want 1, 2", current, previous)
+	}
+
+	// Counters expire once the following window no longer needs them
+	if ttl := server.TTL("k:" + strconv.FormatInt(start.UnixMilli(), 10)); ttl != 2*time.Minute {
+		t.Errorf("window TTL = %v, want 2m", ttl)
+	}
+	server.FastForward(2 * time.Minute)
+	if current, previous, _ := store.Increment(ctx, "k", start.Add(2*time.Minute), time.Minute); current != 1 || previous != 0 {
+		t.Errorf("after expiry = %d, %d, want 1, 0", current, previous)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Increment(context.Context, string, time.Time, time.Duration) (int, int, error) {
+	return 0, 0, errors.New("store down")
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	handler := newRateLimitedHandler(failingStore{}, RateLimitPolicy{Name: "default", Requests: 1, Window: time.Minute})
+	for i := 0; i < 3; i++ {
+		if rec := serve(handler, "GET", "/api/nfts", "203.0.113.1:1", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d with the store down: %d, want 204", i, rec.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		forwarded  []string
+		trustProxy bool
+		want       string
+	}{
+		{"connection", nil, false, "10.0.0.1"},
+		{"header ignored without a trusted proxy", []string{"203.0.113.7"}, false, "10.0.0.1"},
+		{"address the proxy appended", []string{"203.0.113.7"}, true, "203.0.113.7"},
+		{"spoofed leading entries", []string{"198.51.100.1, 198.51.100.2, 203.0.113.7"}, true, "203.0.113.7"},
+		{"spoofed header before the proxy's", []string{"198.51.100.1", "203.0.113.7"}, true, "203.0.113.7"},
+		{"empty entry", []string{"198.51.100.1, "}, true, "10.0.0.1"},
+		{"no header", nil, true, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/nfts", nil)
+			req.RemoteAddr = "10.0.0.1:5000"
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if got := ClientIP(req, tt.trustProxy); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := RateLimit(NewMemoryRateLimitStore(), RateLimitConfig{
+		Policies:   []RateLimitPolicy{{Name: "auth", Requests: 2, Window: time.Hour}},
+		TrustProxy: true,
+	})(ok)
+
+	// Rotating the leading entry doesn't give the client a fresh count
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/api/auth/login", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-Forwarded-For", "198.51.100."+strconv.Itoa(i)+", 203.0.113.7")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if want := i < 2; (rec.Code == http.StatusNoContent) != want {
+			t.Errorf("request %d with a spoofed entry: %d", i, rec.Code)
+		}
+	}
+}
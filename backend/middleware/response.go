@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// errorResponse is the JSON body written when a middleware rejects a request
+type errorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Feature string `json:"feature,omitempty"`
+	} `json:"error"`
+}
+
+// writeError writes a JSON error response with the given status
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	var body errorResponse
+	body.Error.Code = code
+	body.Error.Message = message
+	writeBody(w, status, body)
+}
+
+// writeBody writes an error response body with the given status
+func writeBody(w http.ResponseWriter, status int, body errorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
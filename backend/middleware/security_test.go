@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoBody answers 200 with the body it read, or 400 when reading fails
+var echoBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Write(body)
+})
+
+func TestCORS(t *testing.T) {
+	handler := CORS(CORSConfig{AllowedOrigins: []string{"http://localhost:3000"}, MaxAge: time.Minute})(echoBody)
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/nfts/mint", nil)
+	preflight.Header.Set("Origin", "http://localhost:3000")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, preflight)
+	h := rec.Header()
+	if rec.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "http://localhost:3000" ||
+		!strings.Contains(h.Get("Access-Control-Allow-Methods"), "POST") ||
+		!strings.Contains(h.Get("Access-Control-Allow-Headers"), "Authorization") || h.Get("Access-Control-Max-Age") != "60" {
+		t.Errorf("preflight: %d %v", rec.Code, h)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/nfts", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "Retry-After") || rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("allowed origin: %d %v", rec.Code, rec.Header())
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodOptions} {
+		req := httptest.NewRequest(method, "/api/nfts", nil)
+		req.Header.Set("Origin", "https://evil.example")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%s from a disallowed origin: %d %v", method, rec.Code, rec.Header())
+		}
+	}
+
+	// Requests without an Origin are not from browsers
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nfts", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("request without an Origin: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestCORSConfigFromEnv(t *testing.T) {
+	t.Setenv("ALLOWED_ORIGINS", " http://localhost:3000/, ,https://app.example ")
+	config := CORSConfigFromEnv()
+	if len(config.AllowedOrigins) != 2 || config.AllowedOrigins[0] != "http://localhost:3000" || config.AllowedOrigins[1] != "https://app.example" {
+		t.Errorf("origins = %q", config.AllowedOrigins)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := SecurityHeaders()(echoBody)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nfts", nil))
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" || rec.Header().Get("X-Frame-Options") != "DENY" || rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("headers over HTTP = %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/nfts", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("no HSTS over HTTPS")
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	handler := LimitBody(16)(echoBody)
+	post := func(body, contentType string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/nfts/mint", strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"name":"ok"}`, "application/json; charset=utf-8", false); rec.Code != http.StatusOK {
+		t.Errorf("small JSON body: %d", rec.Code)
+	}
+	if rec := post(`{"name":"far too long"}`, "application/json", false); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("declared oversized body: %d, want 413", rec.Code)
+	}
+	if rec := post(`{"name":"far too long"}`, "application/json", true); rec.Code != http.StatusBadRequest {
+		t.Errorf("undeclared oversized body: %d, want the read to fail", rec.Code)
+	}
+	if rec := post(`name=ok`, "application/x-www-form-urlencoded", false); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("form body: %d, want 415", rec.Code)
+	}
+	if rec := post("", "", false); rec.Code != http.StatusOK {
+		t.Errorf("empty body: %d", rec.Code)
+	}
+}
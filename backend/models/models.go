@@ -2,6 +2,8 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,7 +21,15 @@ type User struct {
 	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
 	IsVerified    bool       `db:"is_verified" json:"is_verified"`
-	Nonce         *string    `db:"nonce" json:"-"`
+}
+
+// SIWENonce is an outstanding sign-in challenge; each is issued for one
+// wallet and consumed by the login that signs it
+type SIWENonce struct {
+	Nonce         string    `db:"nonce" json:"nonce"`
+	WalletAddress string    `db:"wallet_address" json:"wallet_address"`
+	ExpiresAt     time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
 }
 
 // Collection represents an NFT collection
@@ -49,6 +59,7 @@ type NFT struct {
 	Chain           string          `db:"chain" json:"chain"`
 	TransactionHash *string         `db:"transaction_hash" json:"transaction_hash,omitempty"`
 	MintedAt        *time.Time      `db:"minted_at" json:"minted_at,omitempty"`
+	MintStatus      string          `db:"mint_status" json:"mint_status"`
 	CreatedAt       time.Time       `db:"created_at" json:"created_at"`
 	UpdatedAt       time.Time       `db:"updated_at" json:"updated_at"`
 	Views           int             `db:"views" json:"views"`
@@ -92,7 +103,9 @@ type Transaction struct {
 	BlockNumber     *int64     `db:"block_number" json:"block_number,omitempty"`
 	Price           *float64   `db:"price" json:"price,omitempty"`
 	GasFee          *float64   `db:"gas_fee" json:"gas_fee,omitempty"`
+	BlockHash       *string    `db:"block_hash" json:"block_hash,omitempty"`
 	Status          string     `db:"status" json:"status"`
+	ConfirmedAt     *time.Time `db:"confirmed_at" json:"confirmed_at,omitempty"`
 	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
 }
 
@@ -125,6 +138,7 @@ type Recommendation struct {
 	NFTID            uuid.UUID  `db:"nft_id" json:"nft_id"`
 	Score            float64    `db:"score" json:"score"`
 	Reason           *string    `db:"reason" json:"reason,omitempty"`
+	Rule             string     `db:"rule" json:"rule"`
 	AlgorithmVersion string     `db:"algorithm_version" json:"algorithm_version"`
 	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
 	ExpiresAt        *time.Time `db:"expires_at" json:"expires_at,omitempty"`
@@ -147,44 +161,239 @@ type Analytics struct {
 	CreatedAt        time.Time       `db:"created_at" json:"created_at"`
 }
 
+// TrendingNFT is an NFT's precomputed rank in a trending window
+type TrendingNFT struct {
+	Period       string    `db:"period" json:"period"`
+	NFTID        uuid.UUID `db:"nft_id" json:"nft_id"`
+	Rank         int       `db:"rank" json:"rank"`
+	Score        float64   `db:"score" json:"score"`
+	Interactions int       `db:"interactions" json:"interactions"`
+	Sales        int       `db:"sales" json:"sales"`
+	Volume       float64   `db:"volume" json:"volume"`
+	ComputedAt   time.Time `db:"computed_at" json:"computed_at"`
+	
+	// Joined field
+	NFT          *NFT      `db:"-" json:"nft,omitempty"`
+}
+
+// FeatureFlag switches a feature on or off; a disabled feature stays
+// available to its allowed wallets
+type FeatureFlag struct {
+	Name           string         `db:"name" json:"name"`
+	Enabled        bool           `db:"enabled" json:"enabled"`
+	AllowedWallets pq.StringArray `db:"allowed_wallets" json:"allowed_wallets"`
+	UpdatedBy      *string        `db:"updated_by" json:"updated_by,omitempty"`
+	UpdatedAt      *time.Time     `db:"updated_at" json:"updated_at,omitempty"`
+	
+	// Source is FeatureSourceEnv for a default, FeatureSourceOverride for a
+	// flag set at runtime
+	Source         string         `db:"-" json:"source"`
+}
+
 // PriceRange represents a price range for preferences
 type PriceRange struct {
 	Min float64 `json:"min"`
 	Max float64 `json:"max"`
 }
 
+// Validate checks that the range is non-negative and not inverted
+func (p PriceRange) Validate() error {
+	if p.Min < 0 || p.Max < 0 {
+		return errors.New("price range must not be negative")
+	}
+	if p.Max == 0 {
+		return errors.New("price range max must be greater than zero")
+	}
+	if p.Min > p.Max {
+		return errors.New("price range min must not exceed max")
+	}
+	return nil
+}
+
 // NFTAttribute represents an NFT attribute/trait
 type NFTAttribute struct {
 	TraitType string      `json:"trait_type"`
 	AttrValue interface{} `json:"value"` // Renamed from Value to avoid conflict
 }
 
+// MintJob is a queued mint processed asynchronously by the mint worker
+type MintJob struct {
+	ID               uuid.UUID      `db:"id" json:"id"`
+	CreatorID        uuid.UUID      `db:"creator_id" json:"creator_id"`
+	Name             string         `db:"name" json:"name"`
+	Description      *string        `db:"description" json:"description,omitempty"`
+	ImageURL         string         `db:"image_url" json:"image_url"`
+	Tags             pq.StringArray `db:"tags" json:"tags,omitempty"`
+	CollectionID     *uuid.UUID     `db:"collection_id" json:"collection_id,omitempty"`
+	RecipientAddress string         `db:"recipient_address" json:"recipient_address"`
+	Status           string         `db:"status" json:"status"`
+	Provider         *string        `db:"provider" json:"provider,omitempty"`
+	Chain            *string        `db:"chain" json:"chain,omitempty"`
+	TransactionHash  *string        `db:"transaction_hash" json:"transaction_hash,omitempty"`
+	ContractAddress  *string        `db:"contract_address" json:"contract_address,omitempty"`
+	TokenID          *string        `db:"token_id" json:"token_id,omitempty"`
+	NFTID            *uuid.UUID     `db:"nft_id" json:"nft_id,omitempty"`
+	Error            *string        `db:"error" json:"error,omitempty"`
+	Attempts         int            `db:"attempts" json:"attempts"`
+	RunAfter         time.Time      `db:"run_after" json:"-"`
+	LockedUntil      *time.Time     `db:"locked_until" json:"-"`
+	CreatedAt        time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// TrainingJob is a queued recommendation model training run by the
+// training worker
+type TrainingJob struct {
+	ID                uuid.UUID  `db:"id" json:"id"`
+	Status            string     `db:"status" json:"status"`
+	ForceRetrain      bool       `db:"force_retrain" json:"force_retrain"`
+	RequestedBy       *uuid.UUID `db:"requested_by" json:"requested_by,omitempty"`
+	DatasetPath       *string    `db:"dataset_path" json:"dataset_path,omitempty"`
+	InteractionCount  *int       `db:"interaction_count" json:"interaction_count,omitempty"`
+	NFTCount          *int       `db:"nft_count" json:"nft_count,omitempty"`
+	PreferenceCount   *int       `db:"preference_count" json:"preference_count,omitempty"`
+	PreviousTrainedAt *string    `db:"previous_trained_at" json:"-"`
+	ModelVersion      *string    `db:"model_version" json:"model_version,omitempty"`
+	Message           *string    `db:"message" json:"message,omitempty"`
+	Error             *string    `db:"error" json:"error,omitempty"`
+	Attempts          int        `db:"attempts" json:"attempts"`
+	RunAfter          time.Time  `db:"run_after" json:"-"`
+	LockedUntil       *time.Time `db:"locked_until" json:"-"`
+	TriggeredAt       *time.Time `db:"triggered_at" json:"triggered_at,omitempty"`
+	CompletedAt       *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// ModelVersion is a registered recommendation model; the algorithm_version
+// of every cached recommendation names one
+type ModelVersion struct {
+	Version       string     `db:"version" json:"version"`
+	Source        string     `db:"source" json:"source"`
+	EngineVersion *string    `db:"engine_version" json:"engine_version,omitempty"`
+	TrainedAt     *time.Time `db:"trained_at" json:"trained_at,omitempty"`
+	TrainingJobID *uuid.UUID `db:"training_job_id" json:"training_job_id,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
+// TrainingDataset is the snapshot exported for a training job
+type TrainingDataset struct {
+	ExportedAt   time.Time          `json:"exported_at"`
+	Interactions []*UserInteraction `json:"interactions"`
+	NFTs         []*TrainingNFT     `json:"nfts"`
+	Preferences  []*UserPreferences `json:"preferences"`
+}
+
+// TrainingNFT holds the NFT features a training uses
+type TrainingNFT struct {
+	ID         uuid.UUID       `db:"id" json:"id"`
+	CreatorID  uuid.UUID       `db:"creator_id" json:"creator_id"`
+	Tags       pq.StringArray  `db:"tags" json:"tags"`
+	Attributes json.RawMessage `db:"attributes" json:"attributes,omitempty"`
+}
+
+// Mint job statuses
+const (
+	MintJobQueued    = "queued"
+	MintJobSubmitted = "submitted"
+	MintJobConfirmed = "confirmed"
+	MintJobFailed    = "failed"
+)
+
+// Training job statuses
+const (
+	TrainingJobQueued    = "queued"
+	TrainingJobTraining  = "training"
+	TrainingJobCompleted = "completed"
+	TrainingJobFailed    = "failed"
+)
+
+// Model version sources
+const (
+	ModelSourceSQL      = "sql"
+	ModelSourceAIEngine = "ai-engine"
+)
+
+// Transaction statuses; NFT mint statuses use the same values
+const (
+	TransactionPending   = "pending"
+	TransactionConfirmed = "confirmed"
+	TransactionFailed    = "failed"
+)
+
+// Recommendation rules, named after the strongest signal behind the score;
+// the ai-engine's recommendations are tagged as such
+const (
+	RulePreferredCreator  = "preferred_creator"
+	RuleCreatorAffinity   = "creator_affinity"
+	RulePreferredCategory = "preferred_category"
+	RuleTagOverlap        = "tag_overlap"
+	RulePopularity        = "popularity"
+	RuleAIEngine          = "ai_engine"
+)
+
+// Feature flag sources
+const (
+	FeatureSourceEnv      = "env"
+	FeatureSourceOverride = "override"
+)
+
+// Interaction types
+const (
+	InteractionView     = "view"
+	InteractionLike     = "like"
+	InteractionShare    = "share"
+	InteractionPurchase = "purchase"
+)
+
+// Recommendation weights of each interaction type
+const (
+	InteractionWeightView     = 1.0
+	InteractionWeightLike     = 3.0
+	InteractionWeightShare    = 4.0
+	InteractionWeightPurchase = 5.0
+)
+
 // Helper methods
 
-// NewUser creates a new user with defaults
+// NewUser creates a new user with defaults. The wallet address is
+// lowercased so each wallet has one user whatever its checksum casing.
 func NewUser(walletAddress string) *User {
 	return &User{
 		ID:            uuid.New(),
-		WalletAddress: walletAddress,
+		WalletAddress: strings.ToLower(walletAddress),
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 		IsVerified:    false,
 	}
 }
 
-// NewNFT creates a new NFT with defaults
-func NewNFT(name, imageURL string, creatorID, ownerID uuid.UUID) *NFT {
-	return &NFT{
+// NewCollection creates a new collection with defaults
+func NewCollection(name string, creatorID uuid.UUID) *Collection {
+	return &Collection{
 		ID:        uuid.New(),
 		Name:      name,
-		ImageURL:  imageURL,
 		CreatorID: creatorID,
-		OwnerID:   ownerID,
 		Chain:     "polygonAmoy",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-		Views:     0,
-		Likes:     0,
+	}
+}
+
+// NewNFT creates a new NFT with defaults
+func NewNFT(name, imageURL string, creatorID, ownerID uuid.UUID) *NFT {
+	return &NFT{
+		ID:         uuid.New(),
+		Name:       name,
+		ImageURL:   imageURL,
+		CreatorID:  creatorID,
+		OwnerID:    ownerID,
+		Chain:      "polygonAmoy",
+		MintStatus: TransactionConfirmed,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Views:      0,
+		Likes:      0,
 	}
 }
 
@@ -201,3 +410,63 @@ func NewMarketplaceListing(nftID, sellerID uuid.UUID, price float64) *Marketplac
 		UpdatedAt: time.Now(),
 	}
 }
+
+// NewTransaction creates a new pending transaction
+func NewTransaction(txType string, nftID uuid.UUID) *Transaction {
+	return &Transaction{
+		ID:        uuid.New(),
+		Type:      txType,
+		NFTID:     nftID,
+		Status:    TransactionPending,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewUserInteraction creates a new user interaction
+func NewUserInteraction(userID, nftID uuid.UUID, interactionType string, value float64) *UserInteraction {
+	return &UserInteraction{
+		ID:               uuid.New(),
+		UserID:           userID,
+		NFTID:            nftID,
+		InteractionType:  interactionType,
+		InteractionValue: value,
+		CreatedAt:        time.Now(),
+	}
+}
+
+// NewUserPreferences creates empty recommendation preferences for a user
+func NewUserPreferences(userID uuid.UUID) *UserPreferences {
+	return &UserPreferences{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// NewTrainingJob creates a new queued training job
+func NewTrainingJob(forceRetrain bool) *TrainingJob {
+	return &TrainingJob{
+		ID:           uuid.New(),
+		Status:       TrainingJobQueued,
+		ForceRetrain: forceRetrain,
+		RunAfter:     time.Now(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+}
+
+// NewMintJob creates a new queued mint job
+func NewMintJob(creatorID uuid.UUID, name, imageURL, recipientAddress string) *MintJob {
+	return &MintJob{
+		ID:               uuid.New(),
+		CreatorID:        creatorID,
+		Name:             name,
+		ImageURL:         imageURL,
+		RecipientAddress: recipientAddress,
+		Status:           MintJobQueued,
+		RunAfter:         time.Now(),
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"database/sql"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dateLayout is how analytics dates are passed to Postgres
+const dateLayout = "2006-01-02"
+
+// MarketStats are marketplace figures computed live from the transactions
+type MarketStats struct {
+	TotalVolume      float64 `db:"total_volume" json:"total_volume"`
+	TotalSales       int     `db:"total_sales" json:"total_sales"`
+	Volume24h        float64 `db:"volume_24h" json:"24h_volume"`
+	Sales24h         int     `db:"sales_24h" json:"24h_sales"`
+	Transactions24h  int     `db:"transactions_24h" json:"24h_transactions"`
+	ActiveUsers24h   int     `db:"active_users_24h" json:"24h_active_users"`
+	AverageSalePrice float64 `db:"average_sale_price" json:"average_sale_price"`
+}
+
+// AnalyticsRepository handles the daily analytics rollup
+type AnalyticsRepository struct {
+	db DBTX
+}
+
+// NewAnalyticsRepository creates a new analytics repository
+func NewAnalyticsRepository() *AnalyticsRepository {
+	return &AnalyticsRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *AnalyticsRepository) WithTx(tx *sqlx.Tx) *AnalyticsRepository {
+	return &AnalyticsRepository{
+		db: tx,
+	}
+}
+
+// Rollup computes the analytics row of a day and stores it, replacing the
+// row of an earlier run. Users and NFTs are totals at the end of the day;
+// transactions, volume, active users, new users and trending NFTs cover the
+// day only. Volume sums confirmed purchases by when they were confirmed.
+func (r *AnalyticsRepository) Rollup(day time.Time) (*models.Analytics, error) {
+	var analytics models.Analytics
+	query := `
+		WITH bounds AS (
+			SELECT $1::date as day, $1::date + 1 as next_day
+		),
+		trending AS (
+			SELECT n.id, n.name, n.image_url,
+				   SUM(ui.interaction_value) as score,
+				   COUNT(*) as interactions
+			FROM user_interactions ui
+			JOIN nfts n ON n.id = ui.nft_id
+			CROSS JOIN bounds b
+			WHERE ui.created_at >= b.day AND ui.created_at < b.next_day
+			GROUP BY n.id
+			ORDER BY score DESC, n.id
+			LIMIT 10
+		)
+		INSERT INTO analytics (
+			date, total_users, total_nfts, total_transactions, total_volume,
+			active_users, new_users, trending_nfts
+		)
+		SELECT b.day,
+			(SELECT COUNT(*) FROM users WHERE created_at < b.next_day),
+			(SELECT COUNT(*) FROM nfts WHERE created_at < b.next_day),
+			(SELECT COUNT(*) FROM transactions
+			 WHERE status != 'failed' AND created_at >= b.day AND created_at < b.next_day),
+			(SELECT COALESCE(SUM(price), 0) FROM transactions
+			 WHERE type = 'purchase' AND status = 'confirmed'
+			   AND COALESCE(confirmed_at, created_at) >= b.day
+			   AND COALESCE(confirmed_at, created_at) < b.next_day),
+			(SELECT COUNT(DISTINCT user_id) FROM (
+				SELECT user_id FROM user_interactions
+				WHERE created_at >= b.day AND created_at < b.next_day
+				UNION
+				SELECT unnest(ARRAY[from_user_id, to_user_id]) FROM transactions
+				WHERE created_at >= b.day AND created_at < b.next_day
+			 ) active WHERE user_id IS NOT NULL),
+			(SELECT COUNT(*) FROM users WHERE created_at >= b.day AND created_at < b.next_day),
+			(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+				'nft_id', t.id, 'name', t.name, 'image_url', t.image_url,
+				'score', t.score, 'interactions', t.interactions
+			 ) ORDER BY t.score DESC, t.id), '[]') FROM trending t)
+		FROM bounds b
+		ON CONFLICT (date) DO UPDATE SET
+			total_users = EXCLUDED.total_users,
+			total_nfts = EXCLUDED.total_nfts,
+			total_transactions = EXCLUDED.total_transactions,
+			total_volume = EXCLUDED.total_volume,
+			active_users = EXCLUDED.active_users,
+			new_users = EXCLUDED.new_users,
+			trending_nfts = EXCLUDED.trending_nfts,
+			created_at = NOW()
+		RETURNING *`
+
+	if err := r.db.Get(&analytics, query, day.Format(dateLayout)); err != nil {
+		return nil, err
+	}
+	return &analytics, nil
+}
+
+// LatestDate returns the most recent day rolled up, or nil before the first
+// rollup
+func (r *AnalyticsRepository) LatestDate() (*time.Time, error) {
+	var latest sql.NullTime
+	if err := r.db.Get(&latest, `SELECT MAX(date) FROM analytics`); err != nil {
+		return nil, err
+	}
+	if !latest.Valid {
+		return nil, nil
+	}
+	return &latest.Time, nil
+}
+
+// GetRange retrieves the rolled up days from from through to, oldest first
+func (r *AnalyticsRepository) GetRange(from, to time.Time) ([]*models.Analytics, error) {
+	analytics := []*models.Analytics{}
+	query := `
+		SELECT * FROM analytics
+		WHERE date >= $1::date AND date <= $2::date
+		ORDER BY date`
+
+	err := r.db.Select(&analytics, query, from.Format(dateLayout), to.Format(dateLayout))
+	return analytics, err
+}
+
+// GetMarketStats computes sales volume over all time and activity over the
+// last 24 hours from the transactions. Only confirmed purchases count as
+// sales.
+func (r *AnalyticsRepository) GetMarketStats() (*MarketStats, error) {
+	var stats MarketStats
+	query := `
+		WITH sales AS (
+			SELECT price, COALESCE(confirmed_at, created_at) as sold_at
+			FROM transactions
+			WHERE type = 'purchase' AND status = 'confirmed'
+		)
+		SELECT
+			(SELECT COALESCE(SUM(price), 0) FROM sales) as total_volume,
+			(SELECT COUNT(*) FROM sales) as total_sales,
+			(SELECT COALESCE(SUM(price), 0) FROM sales
+			 WHERE sold_at > NOW() - INTERVAL '24 hours') as volume_24h,
+			(SELECT COUNT(*) FROM sales
+			 WHERE sold_at > NOW() - INTERVAL '24 hours') as sales_24h,
+			(SELECT COUNT(*) FROM transactions
+			 WHERE status != 'failed' AND created_at > NOW() - INTERVAL '24 hours') as transactions_24h,
+			(SELECT COUNT(DISTINCT user_id) FROM (
+				SELECT user_id FROM user_interactions
+				WHERE created_at > NOW() - INTERVAL '24 hours'
+				UNION
+				SELECT unnest(ARRAY[from_user_id, to_user_id]) FROM transactions
+				WHERE created_at > NOW() - INTERVAL '24 hours'
+			 ) active WHERE user_id IS NOT NULL) as active_users_24h,
+			(SELECT COALESCE(AVG(price), 0) FROM sales) as average_sale_price`
+
+	if err := r.db.Get(&stats, query); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
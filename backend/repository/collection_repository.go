@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrCollectionNotFound is returned when a collection does not exist
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// CollectionRepository handles collection database operations
+type CollectionRepository struct {
+	db DBTX
+}
+
+// NewCollectionRepository creates a new collection repository
+func NewCollectionRepository() *CollectionRepository {
+	return &CollectionRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *CollectionRepository) WithTx(tx *sqlx.Tx) *CollectionRepository {
+	return &CollectionRepository{
+		db: tx,
+	}
+}
+
+// Create creates a new collection
+func (r *CollectionRepository) Create(collection *models.Collection) error {
+	query := `
+		INSERT INTO collections (
+			id, name, description, creator_id, contract_address, chain
+		) VALUES (
+			:id, :name, :description, :creator_id, :contract_address, :chain
+		)`
+
+	_, err := r.db.NamedExec(query, collection)
+	return err
+}
+
+// GetByID retrieves a collection by ID
+func (r *CollectionRepository) GetByID(id uuid.UUID) (*models.Collection, error) {
+	var collection models.Collection
+	query := `SELECT * FROM collections WHERE id = $1`
+
+	err := r.db.Get(&collection, query, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// GetAll retrieves a page of collections, newest first, optionally only
+// those of a creator
+func (r *CollectionRepository) GetAll(creatorID *uuid.UUID, page PageRequest) ([]*models.Collection, Page, error) {
+	collections := []*models.Collection{}
+	var where string
+	var args []interface{}
+	if creatorID != nil {
+		args = append(args, *creatorID)
+		where = "WHERE c.creator_id = $1"
+	}
+	condition, args := page.after("c", args)
+	query := fmt.Sprintf(`
+		SELECT * FROM collections c
+		%s
+		ORDER BY %s
+		LIMIT $%d`, and(where, condition), page.orderBy("c"), len(args)+1)
+
+	if err := r.db.Select(&collections, query, append(args, page.fetchLimit())...); err != nil {
+		return nil, Page{}, err
+	}
+
+	collections, cursors := paginate(collections, page, func(c *models.Collection) Cursor {
+		return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	})
+	return collections, cursors, nil
+}
+
+// Update updates a collection owned by its creator
+func (r *CollectionRepository) Update(collection *models.Collection) error {
+	query := `
+		UPDATE collections SET
+			name = :name,
+			description = :description,
+			contract_address = :contract_address,
+			updated_at = NOW()
+		WHERE id = :id AND creator_id = :creator_id`
+
+	res, err := r.db.NamedExec(query, collection)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DBTX is implemented by both *sqlx.DB and *sqlx.Tx so repositories
+// can run either directly or inside database.Transaction
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+	NamedQuery(query string, arg interface{}) (*sqlx.Rows, error)
+}
+
+// nftColumnNames and userColumnNames list the columns scanned into
+// models.NFT and models.User when they are joined into another row
+var (
+	nftColumnNames = []string{
+		"id", "name", "description", "image_url", "metadata_url",
+		"creator_id", "owner_id", "collection_id", "contract_address",
+		"token_id", "chain", "transaction_hash", "minted_at", "mint_status",
+		"created_at", "updated_at", "views", "likes", "attributes", "tags",
+	}
+	userColumnNames = []string{
+		"id", "wallet_address", "username", "bio", "profile_image",
+		"email", "created_at", "updated_at", "is_verified",
+	}
+)
+
+// nftColumns selects the models.NFT columns of nfts aliased as n. Queries
+// list them instead of n.* as the table also holds the search_vector column.
+var nftColumns = qualifiedColumns("n", nftColumnNames)
+
+// qualifiedColumns lists columns qualified with the table alias
+func qualifiedColumns(alias string, columns []string) string {
+	selected := make([]string, len(columns))
+	for i, c := range columns {
+		selected[i] = alias + "." + c
+	}
+	return strings.Join(selected, ", ")
+}
+
+// prefixedColumns selects alias.column as "prefix.column" so sqlx can
+// scan joined tables into nested structs tagged with prefix
+func prefixedColumns(alias, prefix string, columns []string) string {
+	selected := make([]string, len(columns))
+	for i, c := range columns {
+		selected[i] = fmt.Sprintf(`%s.%s as "%s.%s"`, alias, c, prefix, c)
+	}
+	return strings.Join(selected, ",\n\t\t\t   ")
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FeatureFlagRepository handles the runtime feature flag overrides
+type FeatureFlagRepository struct {
+	db DBTX
+}
+
+// NewFeatureFlagRepository creates a new feature flag repository
+func NewFeatureFlagRepository() *FeatureFlagRepository {
+	return &FeatureFlagRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *FeatureFlagRepository) WithTx(tx *sqlx.Tx) *FeatureFlagRepository {
+	return &FeatureFlagRepository{
+		db: tx,
+	}
+}
+
+// GetAll retrieves every override
+func (r *FeatureFlagRepository) GetAll() ([]*models.FeatureFlag, error) {
+	flags := []*models.FeatureFlag{}
+	err := r.db.Select(&flags, `SELECT * FROM feature_flags ORDER BY name`)
+	return flags, err
+}
+
+// Upsert sets a flag's override, replacing an earlier one
+func (r *FeatureFlagRepository) Upsert(flag *models.FeatureFlag) error {
+	query := `
+		INSERT INTO feature_flags (name, enabled, allowed_wallets, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (name) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			allowed_wallets = EXCLUDED.allowed_wallets,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+		RETURNING *`
+
+	return r.db.Get(flag, query, flag.Name, flag.Enabled, flag.AllowedWallets, flag.UpdatedBy)
+}
+
+// Delete drops a flag's override, reporting whether it had one
+func (r *FeatureFlagRepository) Delete(name string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM feature_flags WHERE name = $1`, name)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// WalletView is a view of an NFT by the user of a wallet
+type WalletView struct {
+	Wallet   string
+	NFTID    uuid.UUID
+	ViewedAt time.Time
+}
+
+// InteractionRepository handles user interaction database operations
+type InteractionRepository struct {
+	db DBTX
+}
+
+// NewInteractionRepository creates a new interaction repository
+func NewInteractionRepository() *InteractionRepository {
+	return &InteractionRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *InteractionRepository) WithTx(tx *sqlx.Tx) *InteractionRepository {
+	return &InteractionRepository{
+		db: tx,
+	}
+}
+
+// Record inserts an interaction, refreshing it if the user already has
+// one of the same type for the NFT
+func (r *InteractionRepository) Record(interaction *models.UserInteraction) error {
+	query := `
+		INSERT INTO user_interactions (
+			id, user_id, nft_id, interaction_type, interaction_value
+		) VALUES (
+			:id, :user_id, :nft_id, :interaction_type, :interaction_value
+		)
+		ON CONFLICT (user_id, nft_id, interaction_type)
+		DO UPDATE SET
+			interaction_value = EXCLUDED.interaction_value,
+			created_at = NOW()`
+
+	_, err := r.db.NamedExec(query, interaction)
+	return err
+}
+
+// Add inserts an interaction unless the user already has one of the same
+// type for the NFT. It reports whether a row was inserted.
+func (r *InteractionRepository) Add(interaction *models.UserInteraction) (bool, error) {
+	query := `
+		INSERT INTO user_interactions (
+			id, user_id, nft_id, interaction_type, interaction_value
+		) VALUES (
+			:id, :user_id, :nft_id, :interaction_type, :interaction_value
+		)
+		ON CONFLICT (user_id, nft_id, interaction_type) DO NOTHING`
+
+	res, err := r.db.NamedExec(query, interaction)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// Remove deletes the user's interaction of the given type with the NFT. It
+// reports whether one existed.
+func (r *InteractionRepository) Remove(userID, nftID uuid.UUID, interactionType string) (bool, error) {
+	query := `
+		DELETE FROM user_interactions
+		WHERE user_id = $1 AND nft_id = $2 AND interaction_type = $3`
+
+	res, err := r.db.Exec(query, userID, nftID, interactionType)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// RecordViews upserts a view interaction for each view whose wallet belongs
+// to a user; repeat views refresh the existing row
+func (r *InteractionRepository) RecordViews(views []WalletView) error {
+	wallets := make(pq.StringArray, len(views))
+	nftIDs := make(pq.StringArray, len(views))
+	viewedAt := make(pq.StringArray, len(views))
+	for i, view := range views {
+		wallets[i] = view.Wallet
+		nftIDs[i] = view.NFTID.String()
+		viewedAt[i] = view.ViewedAt.Format(time.RFC3339Nano)
+	}
+
+	query := `
+		INSERT INTO user_interactions (
+			user_id, nft_id, interaction_type, interaction_value, created_at
+		)
+		SELECT u.id, v.nft_id, $4, $5, v.viewed_at
+		FROM unnest($1::text[], $2::uuid[], $3::timestamptz[]) AS v(wallet_address, nft_id, viewed_at)
+		JOIN users u ON u.wallet_address = lower(v.wallet_address)
+		JOIN nfts n ON n.id = v.nft_id
+		ON CONFLICT (user_id, nft_id, interaction_type)
+		DO UPDATE SET
+			interaction_value = EXCLUDED.interaction_value,
+			created_at = GREATEST(user_interactions.created_at, EXCLUDED.created_at)`
+
+	_, err := r.db.Exec(query, wallets, nftIDs, viewedAt, models.InteractionView, models.InteractionWeightView)
+	return err
+}
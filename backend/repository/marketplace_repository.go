@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrListingNotFound is returned when a listing does not exist or is not active
+	ErrListingNotFound = errors.New("listing not found")
+	// ErrListingExists is returned when an NFT already has an active listing
+	ErrListingExists = errors.New("NFT is already listed")
+)
+
+// ListingFilter holds the optional filters for querying marketplace listings
+type ListingFilter struct {
+	Status   string
+	SellerID *uuid.UUID
+	Chain    string
+	Currency string
+	MinPrice *float64
+	MaxPrice *float64
+	Page     PageRequest
+}
+
+// listingRow is a listing joined with its NFT and seller
+type listingRow struct {
+	models.MarketplaceListing
+	ListingNFT    models.NFT  `db:"nft"`
+	ListingSeller models.User `db:"seller"`
+}
+
+// listingSelect selects listings with the NFT and seller columns prefixed for sqlx
+var listingSelect = `
+		SELECT ml.*,
+			   ` + prefixedColumns("n", "nft", nftColumnNames) + `,
+			   ` + prefixedColumns("u", "seller", userColumnNames) + `
+		FROM marketplace_listings ml
+		JOIN nfts n ON ml.nft_id = n.id
+		JOIN users u ON ml.seller_id = u.id`
+
+// MarketplaceRepository handles marketplace listing database operations
+type MarketplaceRepository struct {
+	db DBTX
+}
+
+// NewMarketplaceRepository creates a new marketplace repository
+func NewMarketplaceRepository() *MarketplaceRepository {
+	return &MarketplaceRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *MarketplaceRepository) WithTx(tx *sqlx.Tx) *MarketplaceRepository {
+	return &MarketplaceRepository{
+		db: tx,
+	}
+}
+
+// Create creates a new listing, failing if the NFT is already listed
+func (r *MarketplaceRepository) Create(listing *models.MarketplaceListing) error {
+	query := `
+		INSERT INTO marketplace_listings (
+			id, nft_id, seller_id, price, currency, status
+		) VALUES (
+			:id, :nft_id, :seller_id, :price, :currency, :status
+		)`
+
+	_, err := r.db.NamedExec(query, listing)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "idx_marketplace_active_nft" {
+		return ErrListingExists
+	}
+	return err
+}
+
+// GetByID retrieves a listing by ID with its NFT and seller
+func (r *MarketplaceRepository) GetByID(id uuid.UUID) (*models.MarketplaceListing, error) {
+	var row listingRow
+	query := listingSelect + ` WHERE ml.id = $1`
+
+	err := r.db.Get(&row, query, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrListingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.toListing(), nil
+}
+
+// GetActiveByNFT retrieves the active listing for an NFT
+func (r *MarketplaceRepository) GetActiveByNFT(nftID uuid.UUID) (*models.MarketplaceListing, error) {
+	var row listingRow
+	query := listingSelect + ` WHERE ml.nft_id = $1 AND ml.status = 'active'`
+
+	err := r.db.Get(&row, query, nftID)
+	if err == sql.ErrNoRows {
+		return nil, ErrListingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.toListing(), nil
+}
+
+// LockActiveByNFT retrieves the active listing for an NFT and locks it
+// until the surrounding transaction ends, so only one buyer can claim it
+func (r *MarketplaceRepository) LockActiveByNFT(nftID uuid.UUID) (*models.MarketplaceListing, error) {
+	var row listingRow
+	query := listingSelect + ` WHERE ml.nft_id = $1 AND ml.status = 'active' FOR UPDATE OF ml, n`
+
+	err := r.db.Get(&row, query, nftID)
+	if err == sql.ErrNoRows {
+		return nil, ErrListingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.toListing(), nil
+}
+
+// GetListings retrieves a page of listings matching the filter, newest first
+func (r *MarketplaceRepository) GetListings(filter ListingFilter) ([]*models.MarketplaceListing, Page, error) {
+	where, args := filter.where()
+	condition, args := filter.Page.after("ml", args)
+
+	var rows []listingRow
+	query := fmt.Sprintf(`%s
+		%s
+		ORDER BY %s
+		LIMIT $%d`, listingSelect, and(where, condition), filter.Page.orderBy("ml"), len(args)+1)
+
+	err := r.db.Select(&rows, query, append(args, filter.Page.fetchLimit())...)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	rows, cursors := paginate(rows, filter.Page, func(row listingRow) Cursor {
+		return Cursor{CreatedAt: row.CreatedAt, ID: row.ID}
+	})
+	listings := make([]*models.MarketplaceListing, len(rows))
+	for i := range rows {
+		listings[i] = rows[i].toListing()
+	}
+	return listings, cursors, nil
+}
+
+// UpdatePrice changes the price of an active listing owned by the seller
+func (r *MarketplaceRepository) UpdatePrice(id, sellerID uuid.UUID, price float64) error {
+	query := `
+		UPDATE marketplace_listings SET
+			price = $1,
+			updated_at = NOW()
+		WHERE id = $2 AND seller_id = $3 AND status = 'active'`
+
+	return r.execActive(query, price, id, sellerID)
+}
+
+// Cancel cancels an active listing owned by the seller
+func (r *MarketplaceRepository) Cancel(id, sellerID uuid.UUID) error {
+	query := `
+		UPDATE marketplace_listings SET
+			status = 'cancelled',
+			updated_at = NOW()
+		WHERE id = $1 AND seller_id = $2 AND status = 'active'`
+
+	return r.execActive(query, id, sellerID)
+}
+
+// MarkSold marks an active listing as sold to the buyer
+func (r *MarketplaceRepository) MarkSold(id, buyerID uuid.UUID) error {
+	query := `
+		UPDATE marketplace_listings SET
+			status = 'sold',
+			buyer_id = $1,
+			sold_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $2 AND status = 'active'`
+
+	return r.execActive(query, buyerID, id)
+}
+
+// CancelByNFT cancels any active listing of the NFT
+func (r *MarketplaceRepository) CancelByNFT(nftID uuid.UUID) error {
+	query := `
+		UPDATE marketplace_listings SET
+			status = 'cancelled',
+			updated_at = NOW()
+		WHERE nft_id = $1 AND status = 'active'`
+
+	_, err := r.db.Exec(query, nftID)
+	return err
+}
+
+// RevertSale reopens the buyer's most recent purchase of the NFT after the
+// payment failed on chain
+func (r *MarketplaceRepository) RevertSale(nftID, buyerID uuid.UUID) error {
+	query := `
+		UPDATE marketplace_listings SET
+			status = 'active',
+			buyer_id = NULL,
+			sold_at = NULL,
+			updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM marketplace_listings
+			WHERE nft_id = $1 AND buyer_id = $2 AND status = 'sold'
+			ORDER BY sold_at DESC
+			LIMIT 1
+		)`
+
+	_, err := r.db.Exec(query, nftID, buyerID)
+	return err
+}
+
+// CountActive returns the number of active listings
+func (r *MarketplaceRepository) CountActive() (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM marketplace_listings WHERE status = 'active'`
+	err := r.db.Get(&count, query)
+	return count, err
+}
+
+// execActive runs an update against an active listing and reports a missing row
+func (r *MarketplaceRepository) execActive(query string, args ...interface{}) error {
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrListingNotFound
+	}
+	return nil
+}
+
+// where builds the WHERE clause and arguments for the filter
+func (f ListingFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Status != "" {
+		add("ml.status = $%d", f.Status)
+	}
+	if f.SellerID != nil {
+		add("ml.seller_id = $%d", *f.SellerID)
+	}
+	if f.Chain != "" {
+		add("n.chain = $%d", f.Chain)
+	}
+	if f.Currency != "" {
+		add("ml.currency = $%d", f.Currency)
+	}
+	if f.MinPrice != nil {
+		add("ml.price >= $%d", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		add("ml.price <= $%d", *f.MaxPrice)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// toListing copies the joined NFT and seller onto the listing
+func (row *listingRow) toListing() *models.MarketplaceListing {
+	listing := row.MarketplaceListing
+	nft := row.ListingNFT
+	seller := row.ListingSeller
+	listing.NFT = &nft
+	listing.Seller = &seller
+	return &listing
+}
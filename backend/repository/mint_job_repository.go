@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrMintJobNotFound is returned when a mint job does not exist
+var ErrMintJobNotFound = errors.New("mint job not found")
+
+// MintJobRepository handles mint job database operations
+type MintJobRepository struct {
+	db DBTX
+}
+
+// NewMintJobRepository creates a new mint job repository
+func NewMintJobRepository() *MintJobRepository {
+	return &MintJobRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *MintJobRepository) WithTx(tx *sqlx.Tx) *MintJobRepository {
+	return &MintJobRepository{
+		db: tx,
+	}
+}
+
+// Create creates a new mint job
+func (r *MintJobRepository) Create(job *models.MintJob) error {
+	query := `
+		INSERT INTO mint_jobs (
+			id, creator_id, name, description, image_url, tags,
+			collection_id, recipient_address, status, run_after
+		) VALUES (
+			:id, :creator_id, :name, :description, :image_url, :tags,
+			:collection_id, :recipient_address, :status, :run_after
+		)`
+
+	_, err := r.db.NamedExec(query, job)
+	return err
+}
+
+// GetByID retrieves a mint job by ID
+func (r *MintJobRepository) GetByID(id uuid.UUID) (*models.MintJob, error) {
+	var job models.MintJob
+	query := `SELECT * FROM mint_jobs WHERE id = $1`
+
+	err := r.db.Get(&job, query, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrMintJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimNext leases the oldest runnable job for the given duration. Queued jobs
+// still need minting; submitted jobs without an NFT still need recording.
+// Jobs whose lease expired, e.g. after a crash, are claimed again. It returns
+// nil when no job is runnable.
+func (r *MintJobRepository) ClaimNext(lease time.Duration) (*models.MintJob, error) {
+	var job models.MintJob
+	query := `
+		UPDATE mint_jobs SET
+			attempts = attempts + 1,
+			locked_until = NOW() + make_interval(secs => $1)
+		WHERE id = (
+			SELECT id FROM mint_jobs
+			WHERE (status = 'queued' OR (status = 'submitted' AND nft_id IS NULL))
+			  AND run_after <= NOW()
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY run_after
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`
+
+	err := r.db.Get(&job, query, lease.Seconds())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// MarkSubmitted records the provider's result for a minted job
+func (r *MintJobRepository) MarkSubmitted(job *models.MintJob) error {
+	query := `
+		UPDATE mint_jobs SET
+			status = 'submitted',
+			provider = :provider,
+			chain = :chain,
+			transaction_hash = :transaction_hash,
+			contract_address = :contract_address,
+			token_id = :token_id,
+			error = NULL
+		WHERE id = :id`
+
+	_, err := r.db.NamedExec(query, job)
+	return err
+}
+
+// LinkNFT links the recorded NFT and releases the job; it stays submitted
+// until the confirmation tracker settles the mint transaction
+func (r *MintJobRepository) LinkNFT(id, nftID uuid.UUID) error {
+	query := `
+		UPDATE mint_jobs SET
+			nft_id = $1,
+			locked_until = NULL
+		WHERE id = $2`
+
+	_, err := r.db.Exec(query, nftID, id)
+	return err
+}
+
+// Retry releases the job so it runs again after runAfter
+func (r *MintJobRepository) Retry(id uuid.UUID, message string, runAfter time.Time) error {
+	query := `
+		UPDATE mint_jobs SET
+			error = $1,
+			run_after = $2,
+			locked_until = NULL
+		WHERE id = $3`
+
+	_, err := r.db.Exec(query, message, runAfter, id)
+	return err
+}
+
+// Fail marks the job failed
+func (r *MintJobRepository) Fail(id uuid.UUID, message string) error {
+	query := `
+		UPDATE mint_jobs SET
+			status = 'failed',
+			error = $1,
+			locked_until = NULL
+		WHERE id = $2`
+
+	_, err := r.db.Exec(query, message, id)
+	return err
+}
+
+// SetStatusByNFT moves the job that minted the NFT to status. The error is
+// cleared when message is empty.
+func (r *MintJobRepository) SetStatusByNFT(nftID uuid.UUID, status, message string) error {
+	query := `
+		UPDATE mint_jobs SET
+			status = $1,
+			error = NULLIF($2, '')
+		WHERE nft_id = $3`
+
+	_, err := r.db.Exec(query, status, message, nftID)
+	return err
+}
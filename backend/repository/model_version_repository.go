@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrModelVersionNotFound is returned when a model version is not registered
+var ErrModelVersionNotFound = errors.New("model version not found")
+
+// ModelVersionRepository handles the recommendation model registry
+type ModelVersionRepository struct {
+	db DBTX
+}
+
+// NewModelVersionRepository creates a new model version repository
+func NewModelVersionRepository() *ModelVersionRepository {
+	return &ModelVersionRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *ModelVersionRepository) WithTx(tx *sqlx.Tx) *ModelVersionRepository {
+	return &ModelVersionRepository{
+		db: tx,
+	}
+}
+
+// Register records a model version. Registering a known version only links
+// the training job when it has none yet.
+func (r *ModelVersionRepository) Register(version *models.ModelVersion) error {
+	query := `
+		INSERT INTO model_versions (
+			version, source, engine_version, trained_at, training_job_id
+		) VALUES (
+			:version, :source, :engine_version, :trained_at, :training_job_id
+		)
+		ON CONFLICT (version) DO UPDATE SET
+			training_job_id = COALESCE(model_versions.training_job_id, EXCLUDED.training_job_id)`
+
+	_, err := r.db.NamedExec(query, version)
+	return err
+}
+
+// GetByVersion retrieves a registered model version
+func (r *ModelVersionRepository) GetByVersion(version string) (*models.ModelVersion, error) {
+	var model models.ModelVersion
+	query := `SELECT * FROM model_versions WHERE version = $1`
+
+	err := r.db.Get(&model, query, version)
+	if err == sql.ErrNoRows {
+		return nil, ErrModelVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
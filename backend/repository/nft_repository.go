@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"nftgenie/backend/database"
 	"nftgenie/backend/models"
@@ -12,9 +13,12 @@ import (
 	"github.com/lib/pq"
 )
 
+// ErrNFTNotFound is returned when an NFT does not exist
+var ErrNFTNotFound = errors.New("NFT not found")
+
 // NFTRepository handles NFT database operations
 type NFTRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 // NewNFTRepository creates a new NFT repository
@@ -24,18 +28,25 @@ func NewNFTRepository() *NFTRepository {
 	}
 }
 
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *NFTRepository) WithTx(tx *sqlx.Tx) *NFTRepository {
+	return &NFTRepository{
+		db: tx,
+	}
+}
+
 // Create creates a new NFT
 func (r *NFTRepository) Create(nft *models.NFT) error {
 	query := `
 		INSERT INTO nfts (
 			id, name, description, image_url, metadata_url,
 			creator_id, owner_id, collection_id, contract_address,
-			token_id, chain, transaction_hash, minted_at,
+			token_id, chain, transaction_hash, minted_at, mint_status,
 			views, likes, attributes, tags
 		) VALUES (
 			:id, :name, :description, :image_url, :metadata_url,
 			:creator_id, :owner_id, :collection_id, :contract_address,
-			:token_id, :chain, :transaction_hash, :minted_at,
+			:token_id, :chain, :transaction_hash, :minted_at, :mint_status,
 			:views, :likes, :attributes, :tags
 		)`
 	
@@ -43,67 +54,87 @@ func (r *NFTRepository) Create(nft *models.NFT) error {
 	return err
 }
 
-// GetByID retrieves an NFT by ID
-func (r *NFTRepository) GetByID(id uuid.UUID) (*models.NFT, error) {
-	var nft models.NFT
-	query := `
-		SELECT n.*, 
-			   u1.wallet_address as "creator.wallet_address",
-			   u1.username as "creator.username",
-			   u2.wallet_address as "owner.wallet_address",
-			   u2.username as "owner.username"
+// nftRow is an NFT joined with its creator and owner
+type nftRow struct {
+	models.NFT
+	NFTCreator models.User `db:"creator"`
+	NFTOwner   models.User `db:"owner"`
+}
+
+// nftSelect selects NFTs with the creator and owner columns prefixed for sqlx
+var nftSelect = `
+		SELECT ` + nftColumns + `,
+			   ` + prefixedColumns("u1", "creator", userColumnNames) + `,
+			   ` + prefixedColumns("u2", "owner", userColumnNames) + `
 		FROM nfts n
 		LEFT JOIN users u1 ON n.creator_id = u1.id
-		LEFT JOIN users u2 ON n.owner_id = u2.id
-		WHERE n.id = $1`
+		LEFT JOIN users u2 ON n.owner_id = u2.id`
+
+// GetByID retrieves an NFT by ID
+func (r *NFTRepository) GetByID(id uuid.UUID) (*models.NFT, error) {
+	var row nftRow
+	query := nftSelect + ` WHERE n.id = $1`
 	
-	err := r.db.Get(&nft, query, id)
+	err := r.db.Get(&row, query, id)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Increment view count
-	r.IncrementViews(id)
-	
-	return &nft, nil
+	return row.toNFT(), nil
 }
 
-// GetAll retrieves all NFTs with pagination
-func (r *NFTRepository) GetAll(limit, offset int) ([]*models.NFT, int, error) {
-	var nfts []*models.NFT
-	query := `
-		SELECT n.*, 
-			   u1.wallet_address as "creator.wallet_address",
-			   u1.username as "creator.username",
-			   u2.wallet_address as "owner.wallet_address",
-			   u2.username as "owner.username"
-		FROM nfts n
-		LEFT JOIN users u1 ON n.creator_id = u1.id
-		LEFT JOIN users u2 ON n.owner_id = u2.id
-		ORDER BY n.created_at DESC
-		LIMIT $1 OFFSET $2`
+// GetByIDs retrieves NFTs with their creator and owner keyed by ID;
+// missing IDs are left out
+func (r *NFTRepository) GetByIDs(ids []uuid.UUID) (map[uuid.UUID]*models.NFT, error) {
+	keys := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
 	
-	err := r.db.Select(&nfts, query, limit, offset)
-	if err != nil {
-		return nil, 0, err
+	var rows []nftRow
+	query := nftSelect + ` WHERE n.id = ANY($1::uuid[])`
+	if err := r.db.Select(&rows, query, keys); err != nil {
+		return nil, err
 	}
 	
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM nfts`
-	err = r.db.Get(&total, countQuery)
+	nfts := make(map[uuid.UUID]*models.NFT, len(rows))
+	for i := range rows {
+		nft := rows[i].toNFT()
+		nfts[nft.ID] = nft
+	}
+	return nfts, nil
+}
+
+// GetAll retrieves a page of NFTs, newest first
+func (r *NFTRepository) GetAll(page PageRequest) ([]*models.NFT, Page, error) {
+	var rows []nftRow
+	condition, args := page.after("n", nil)
+	query := fmt.Sprintf(`%s
+		%s
+		ORDER BY %s
+		LIMIT $%d`, nftSelect, and("", condition), page.orderBy("n"), len(args)+1)
+	
+	err := r.db.Select(&rows, query, append(args, page.fetchLimit())...)
 	if err != nil {
-		return nil, 0, err
+		return nil, Page{}, err
 	}
 	
-	return nfts, total, nil
+	rows, cursors := paginate(rows, page, func(row nftRow) Cursor {
+		return nftCursor(&row.NFT)
+	})
+	nfts := make([]*models.NFT, len(rows))
+	for i := range rows {
+		nfts[i] = rows[i].toNFT()
+	}
+	return nfts, cursors, nil
 }
 
 // GetByOwner retrieves NFTs by owner
 func (r *NFTRepository) GetByOwner(ownerID uuid.UUID) ([]*models.NFT, error) {
 	var nfts []*models.NFT
 	query := `
-		SELECT * FROM nfts 
+		SELECT ` + nftColumns + `
+		FROM nfts n
 		WHERE owner_id = $1 
 		ORDER BY created_at DESC`
 	
@@ -115,7 +146,8 @@ func (r *NFTRepository) GetByOwner(ownerID uuid.UUID) ([]*models.NFT, error) {
 func (r *NFTRepository) GetByCreator(creatorID uuid.UUID) ([]*models.NFT, error) {
 	var nfts []*models.NFT
 	query := `
-		SELECT * FROM nfts 
+		SELECT ` + nftColumns + `
+		FROM nfts n
 		WHERE creator_id = $1 
 		ORDER BY created_at DESC`
 	
@@ -123,23 +155,32 @@ func (r *NFTRepository) GetByCreator(creatorID uuid.UUID) ([]*models.NFT, error)
 	return nfts, err
 }
 
-// GetByCollection retrieves NFTs by collection
-func (r *NFTRepository) GetByCollection(collectionID uuid.UUID) ([]*models.NFT, error) {
-	var nfts []*models.NFT
-	query := `
-		SELECT * FROM nfts 
-		WHERE collection_id = $1 
-		ORDER BY created_at DESC`
+// GetByCollection retrieves a page of the NFTs of a collection, newest first
+func (r *NFTRepository) GetByCollection(collectionID uuid.UUID, page PageRequest) ([]*models.NFT, Page, error) {
+	nfts := []*models.NFT{}
+	condition, args := page.after("n", []interface{}{collectionID})
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM nfts n
+		%s
+		ORDER BY %s
+		LIMIT $%d`, nftColumns, and("WHERE n.collection_id = $1", condition), page.orderBy("n"), len(args)+1)
 	
-	err := r.db.Select(&nfts, query, collectionID)
-	return nfts, err
+	err := r.db.Select(&nfts, query, append(args, page.fetchLimit())...)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	
+	nfts, cursors := paginate(nfts, page, nftCursor)
+	return nfts, cursors, nil
 }
 
 // GetByTags retrieves NFTs by tags
 func (r *NFTRepository) GetByTags(tags []string) ([]*models.NFT, error) {
 	var nfts []*models.NFT
 	query := `
-		SELECT * FROM nfts 
+		SELECT ` + nftColumns + `
+		FROM nfts n
 		WHERE tags && $1 
 		ORDER BY created_at DESC`
 	
@@ -147,27 +188,6 @@ func (r *NFTRepository) GetByTags(tags []string) ([]*models.NFT, error) {
 	return nfts, err
 }
 
-// GetTrending retrieves trending NFTs
-func (r *NFTRepository) GetTrending(limit int) ([]*models.NFT, error) {
-	var nfts []*models.NFT
-	query := `
-		SELECT n.*, 
-			   COUNT(DISTINCT ui.id) as interaction_count,
-			   COUNT(DISTINCT ml.id) as listing_count
-		FROM nfts n
-		LEFT JOIN user_interactions ui ON n.id = ui.nft_id 
-			AND ui.created_at > NOW() - INTERVAL '7 days'
-		LEFT JOIN marketplace_listings ml ON n.id = ml.nft_id 
-			AND ml.status = 'active'
-		GROUP BY n.id
-		ORDER BY (n.views * 0.3 + n.likes * 0.5 + 
-				  COUNT(DISTINCT ui.id) * 0.2) DESC
-		LIMIT $1`
-	
-	err := r.db.Select(&nfts, query, limit)
-	return nfts, err
-}
-
 // Update updates an NFT
 func (r *NFTRepository) Update(nft *models.NFT) error {
 	query := `
@@ -198,10 +218,32 @@ func (r *NFTRepository) UpdateOwner(nftID, newOwnerID uuid.UUID) error {
 	return err
 }
 
-// IncrementViews increments the view count
-func (r *NFTRepository) IncrementViews(nftID uuid.UUID) error {
-	query := `UPDATE nfts SET views = views + 1 WHERE id = $1`
-	_, err := r.db.Exec(query, nftID)
+// UpdateMintStatus updates the on-chain status of the NFT's mint
+func (r *NFTRepository) UpdateMintStatus(nftID uuid.UUID, status string) error {
+	query := `
+		UPDATE nfts SET
+			mint_status = $1,
+			updated_at = NOW()
+		WHERE id = $2`
+	
+	_, err := r.db.Exec(query, status, nftID)
+	return err
+}
+
+// AddViews adds buffered view counts to their NFTs in one statement
+func (r *NFTRepository) AddViews(counts map[uuid.UUID]int) error {
+	ids := make(pq.StringArray, 0, len(counts))
+	views := make(pq.Int64Array, 0, len(counts))
+	for id, n := range counts {
+		ids = append(ids, id.String())
+		views = append(views, int64(n))
+	}
+	
+	query := `
+		UPDATE nfts SET views = nfts.views + v.views
+		FROM unnest($1::uuid[], $2::int[]) AS v(id, views)
+		WHERE nfts.id = v.id`
+	_, err := r.db.Exec(query, ids, views)
 	return err
 }
 
@@ -212,6 +254,42 @@ func (r *NFTRepository) IncrementLikes(nftID uuid.UUID) error {
 	return err
 }
 
+// DecrementLikes decrements the like count, never below zero
+func (r *NFTRepository) DecrementLikes(nftID uuid.UUID) error {
+	query := `UPDATE nfts SET likes = GREATEST(likes - 1, 0) WHERE id = $1`
+	_, err := r.db.Exec(query, nftID)
+	return err
+}
+
+// LockLikes locks the NFT until the surrounding transaction ends and returns
+// its like count, so concurrent likes and unlikes count exactly once
+func (r *NFTRepository) LockLikes(nftID uuid.UUID) (int, error) {
+	var likes int
+	query := `SELECT likes FROM nfts WHERE id = $1 FOR UPDATE`
+	
+	err := r.db.Get(&likes, query, nftID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNFTNotFound
+	}
+	return likes, err
+}
+
+// Exists reports whether an NFT exists
+func (r *NFTRepository) Exists(nftID uuid.UUID) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM nfts WHERE id = $1)`
+	err := r.db.Get(&exists, query, nftID)
+	return exists, err
+}
+
+// GetIDsByUser retrieves the IDs of the NFTs a user created or owns
+func (r *NFTRepository) GetIDsByUser(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	query := `SELECT id FROM nfts WHERE creator_id = $1 OR owner_id = $1`
+	err := r.db.Select(&ids, query, userID)
+	return ids, err
+}
+
 // Delete deletes an NFT
 func (r *NFTRepository) Delete(id uuid.UUID) error {
 	query := `DELETE FROM nfts WHERE id = $1`
@@ -219,46 +297,38 @@ func (r *NFTRepository) Delete(id uuid.UUID) error {
 	return err
 }
 
-// Search searches NFTs by name or description
-func (r *NFTRepository) Search(searchTerm string, limit, offset int) ([]*models.NFT, int, error) {
-	var nfts []*models.NFT
-	searchPattern := "%" + strings.ToLower(searchTerm) + "%"
-	
-	query := `
-		SELECT * FROM nfts 
-		WHERE LOWER(name) LIKE $1 
-		   OR LOWER(description) LIKE $1
-		   OR $2 = ANY(tags)
-		ORDER BY views DESC, created_at DESC
-		LIMIT $3 OFFSET $4`
-	
-	err := r.db.Select(&nfts, query, searchPattern, searchTerm, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	
-	// Get total count
-	var total int
-	countQuery := `
-		SELECT COUNT(*) FROM nfts 
-		WHERE LOWER(name) LIKE $1 
-		   OR LOWER(description) LIKE $1
-		   OR $2 = ANY(tags)`
-	err = r.db.Get(&total, countQuery, searchPattern, searchTerm)
-	if err != nil {
-		return nil, 0, err
-	}
-	
-	return nfts, total, nil
+// recommendedRow is an NFT scored by GetRecommendedForUser with the signals
+// behind its score
+type recommendedRow struct {
+	models.NFT
+	Score             float64        `db:"score"`
+	Rule              string         `db:"rule"`
+	MatchedTags       pq.StringArray `db:"matched_tags"`
+	MatchedCategories pq.StringArray `db:"matched_categories"`
 }
 
-// GetRecommendedForUser gets recommended NFTs for a user
-func (r *NFTRepository) GetRecommendedForUser(userID uuid.UUID, limit int) ([]*models.NFT, error) {
-	var nfts []*models.NFT
+// GetRecommendedForUser scores NFTs for a user: +3 when the creator is one
+// the user interacts with most, +2 when the NFT shares one of the user's top
+// tags, plus a small popularity term. Each recommendation names the strongest
+// rule that applied and explains it.
+//
+// The user's preferences add +3 for a preferred creator and +2 for tags in a
+// preferred category. NFTs tagged with an excluded tag are never recommended,
+// nor are NFTs listed for sale outside the preferred price range.
+func (r *NFTRepository) GetRecommendedForUser(userID uuid.UUID, limit int) ([]*models.Recommendation, error) {
+	var rows []recommendedRow
 	
-	// Complex recommendation query based on user interactions and preferences
 	query := `
-		WITH user_tags AS (
+		WITH prefs AS (
+			SELECT COALESCE(up.preferred_categories, '{}') as categories,
+				   COALESCE(up.preferred_creators, '{}') as creators,
+				   COALESCE(up.excluded_tags, '{}') as excluded_tags,
+				   (up.preferred_price_range->>'min')::numeric as min_price,
+				   (up.preferred_price_range->>'max')::numeric as max_price
+			FROM (SELECT $1::uuid as user_id) u
+			LEFT JOIN user_preferences up ON up.user_id = u.user_id
+		),
+		user_tags AS (
 			SELECT UNNEST(n.tags) as tag, COUNT(*) as tag_count
 			FROM user_interactions ui
 			JOIN nfts n ON ui.nft_id = n.id
@@ -275,29 +345,77 @@ func (r *NFTRepository) GetRecommendedForUser(userID uuid.UUID, limit int) ([]*m
 			GROUP BY n.creator_id
 			ORDER BY creator_count DESC
 			LIMIT 5
+		),
+		candidates AS (
+			SELECT ` + nftColumns + `,
+				   n.creator_id IN (SELECT creator_id FROM user_creators) as creator_affinity,
+				   n.creator_id = ANY(p.creators) as preferred_creator,
+				   ARRAY(
+					   SELECT ut.tag FROM user_tags ut
+					   WHERE ut.tag = ANY(n.tags)
+					   ORDER BY ut.tag_count DESC, ut.tag
+				   ) as matched_tags,
+				   ARRAY(
+					   SELECT c FROM unnest(p.categories) c
+					   WHERE c = ANY(n.tags)
+					   ORDER BY c
+				   ) as matched_categories
+			FROM nfts n
+			CROSS JOIN prefs p
+			WHERE n.owner_id != $1
+			  AND n.id NOT IN (
+				  SELECT nft_id FROM user_interactions 
+				  WHERE user_id = $1 AND interaction_type = 'purchase'
+			  )
+			  AND NOT (COALESCE(n.tags, '{}') && p.excluded_tags)
+			  AND NOT EXISTS (
+				  SELECT 1 FROM marketplace_listings ml
+				  WHERE ml.nft_id = n.id AND ml.status = 'active'
+				    AND (ml.price < p.min_price OR ml.price > p.max_price)
+			  )
 		)
-		SELECT DISTINCT n.*,
-			   (CASE WHEN n.creator_id IN (SELECT creator_id FROM user_creators) THEN 3 ELSE 0 END +
-			    CASE WHEN EXISTS (SELECT 1 FROM user_tags ut WHERE ut.tag = ANY(n.tags)) THEN 2 ELSE 0 END +
-			    (n.views * 0.0001) + (n.likes * 0.001)) as score
-		FROM nfts n
-		WHERE n.owner_id != $1
-		  AND n.id NOT IN (
-			  SELECT nft_id FROM user_interactions 
-			  WHERE user_id = $1 AND interaction_type = 'purchase'
-		  )
-		ORDER BY score DESC, n.created_at DESC
+		SELECT ` + strings.Join(nftColumnNames, ", ") + `, matched_tags, matched_categories,
+			   (CASE WHEN preferred_creator THEN 3 ELSE 0 END +
+			    CASE WHEN creator_affinity THEN 3 ELSE 0 END +
+			    CASE WHEN cardinality(matched_categories) > 0 THEN 2 ELSE 0 END +
+			    CASE WHEN cardinality(matched_tags) > 0 THEN 2 ELSE 0 END +
+			    (views * 0.0001) + (likes * 0.001)) as score,
+			   CASE WHEN preferred_creator THEN 'preferred_creator'
+			        WHEN creator_affinity THEN 'creator_affinity'
+			        WHEN cardinality(matched_categories) > 0 THEN 'preferred_category'
+			        WHEN cardinality(matched_tags) > 0 THEN 'tag_overlap'
+			        ELSE 'popularity' END as rule
+		FROM candidates
+		ORDER BY score DESC, created_at DESC
 		LIMIT $2`
 	
-	err := r.db.Select(&nfts, query, userID, limit)
-	return nfts, err
+	if err := r.db.Select(&rows, query, userID, limit); err != nil {
+		return nil, err
+	}
+	
+	recommendations := make([]*models.Recommendation, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		reason := row.reason()
+		recommendations[i] = &models.Recommendation{
+			ID:     uuid.New(),
+			UserID: userID,
+			NFTID:  row.ID,
+			Score:  row.Score,
+			Reason: &reason,
+			Rule:   row.Rule,
+			NFT:    &row.NFT,
+		}
+	}
+	return recommendations, nil
 }
 
 // GetByContractAndToken retrieves an NFT by contract address and token ID
 func (r *NFTRepository) GetByContractAndToken(contractAddress, tokenID string) (*models.NFT, error) {
 	var nft models.NFT
 	query := `
-		SELECT * FROM nfts 
+		SELECT ` + nftColumns + `
+		FROM nfts n
 		WHERE contract_address = $1 AND token_id = $2`
 	
 	err := r.db.Get(&nft, query, contractAddress, tokenID)
@@ -306,3 +424,34 @@ func (r *NFTRepository) GetByContractAndToken(contractAddress, tokenID string) (
 	}
 	return &nft, err
 }
+
+// toNFT copies the joined creator and owner onto the NFT
+func (row *nftRow) toNFT() *models.NFT {
+	nft := row.NFT
+	creator := row.NFTCreator
+	owner := row.NFTOwner
+	nft.Creator = &creator
+	nft.Owner = &owner
+	return &nft
+}
+
+// reason explains the rule that fired for a recommended NFT
+func (row *recommendedRow) reason() string {
+	switch row.Rule {
+	case models.RulePreferredCreator:
+		return "From one of your preferred creators"
+	case models.RuleCreatorAffinity:
+		return "From a creator whose NFTs you've interacted with"
+	case models.RulePreferredCategory:
+		return "In your preferred categories: " + strings.Join(row.MatchedCategories, ", ")
+	case models.RuleTagOverlap:
+		return "Tagged " + strings.Join(row.MatchedTags, ", ") + ", like NFTs you've interacted with"
+	default:
+		return fmt.Sprintf("Popular with collectors: %d views, %d likes", row.Views, row.Likes)
+	}
+}
+
+// nftCursor is the position of an NFT in a list
+func nftCursor(nft *models.NFT) Cursor {
+	return Cursor{CreatedAt: nft.CreatedAt, ID: nft.ID}
+}
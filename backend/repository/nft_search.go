@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"nftgenie/backend/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// headlineOptions mark matched words in ts_headline snippets
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2"
+
+// NFTSearch holds the full-text query and facet filters of an NFT search
+type NFTSearch struct {
+	Query        string
+	Chain        string
+	Tags         []string
+	CollectionID *uuid.UUID
+	CreatorID    *uuid.UUID
+	// Attributes is a JSON object of traits the NFT's attributes must contain
+	Attributes json.RawMessage
+	Limit      int
+	Offset     int
+}
+
+// NFTSearchHit is a matching NFT with its rank and highlighted snippets
+type NFTSearchHit struct {
+	NFT        *models.NFT       `json:"nft"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// FacetCount is the number of matching NFTs sharing a facet value
+type FacetCount struct {
+	Value string  `db:"value" json:"value"`
+	Label *string `db:"label" json:"label,omitempty"`
+	Count int     `db:"count" json:"count"`
+}
+
+// SearchFacets break the matching NFTs down by chain, tag, collection and
+// creator
+type SearchFacets struct {
+	Chains      []FacetCount `json:"chains"`
+	Tags        []FacetCount `json:"tags"`
+	Collections []FacetCount `json:"collections"`
+	Creators    []FacetCount `json:"creators"`
+}
+
+// NFTSearchResult is a page of search hits with the total and facets of all
+// matches
+type NFTSearchResult struct {
+	Hits   []*NFTSearchHit `json:"results"`
+	Total  int             `json:"total"`
+	Facets SearchFacets    `json:"facets"`
+}
+
+// searchRow is a matching NFT with its rank and snippets
+type searchRow struct {
+	models.NFT
+	Rank                 float64 `db:"rank"`
+	NameHighlight        *string `db:"name_highlight"`
+	DescriptionHighlight *string `db:"description_highlight"`
+}
+
+// facetQueries count the matches per facet value; %s is the WHERE clause
+var facetQueries = map[string]string{
+	"chains": `
+		SELECT n.chain AS value, COUNT(*) AS count
+		FROM nfts n
+		%s
+		GROUP BY n.chain
+		ORDER BY count DESC, value
+		LIMIT 20`,
+	"tags": `
+		SELECT t.tag AS value, COUNT(*) AS count
+		FROM nfts n
+		CROSS JOIN LATERAL unnest(n.tags) AS t(tag)
+		%s
+		GROUP BY t.tag
+		ORDER BY count DESC, value
+		LIMIT 20`,
+	"collections": `
+		SELECT c.id::text AS value, c.name AS label, COUNT(*) AS count
+		FROM nfts n
+		JOIN collections c ON c.id = n.collection_id
+		%s
+		GROUP BY c.id
+		ORDER BY count DESC, label
+		LIMIT 20`,
+	"creators": `
+		SELECT u.wallet_address AS value, u.username AS label, COUNT(*) AS count
+		FROM nfts n
+		JOIN users u ON u.id = n.creator_id
+		%s
+		GROUP BY u.id
+		ORDER BY count DESC, value
+		LIMIT 20`,
+}
+
+// Search runs a ranked full-text search over name, tags and description. An
+// empty query matches every NFT that passes the filters, newest first.
+func (r *NFTRepository) Search(search NFTSearch) (*NFTSearchResult, error) {
+	where, args := search.where()
+
+	rank := "0::float8"
+	highlights := "NULL::text AS name_highlight, NULL::text AS description_highlight"
+	order, pageOrder := "n.created_at DESC, n.id", "page.created_at DESC, page.id"
+	if search.Query != "" {
+		// The query is always the first argument
+		rank = "ts_rank_cd(n.search_vector, websearch_to_tsquery('english', $1))"
+		highlights = fmt.Sprintf(`
+			ts_headline('english', page.name, websearch_to_tsquery('english', $1), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS name_highlight,
+			ts_headline('english', coalesce(page.description, ''), websearch_to_tsquery('english', $1), '%s') AS description_highlight`,
+			headlineOptions)
+		order, pageOrder = "rank DESC, "+order, "page.rank DESC, "+pageOrder
+	}
+
+	// Snippets are only built for the rows of the page
+	query := fmt.Sprintf(`
+		SELECT page.*, %s
+		FROM (
+			SELECT `+nftColumns+`, %s AS rank
+			FROM nfts n
+			%s
+			ORDER BY %s
+			LIMIT $%d OFFSET $%d
+		) page
+		ORDER BY %s`,
+		highlights, rank, where, order, len(args)+1, len(args)+2, pageOrder)
+
+	var rows []searchRow
+	if err := r.db.Select(&rows, query, append(args, search.Limit, search.Offset)...); err != nil {
+		return nil, err
+	}
+
+	result := &NFTSearchResult{Hits: make([]*NFTSearchHit, len(rows))}
+	for i := range rows {
+		hit := &NFTSearchHit{NFT: &rows[i].NFT, Rank: rows[i].Rank}
+		for field, snippet := range map[string]*string{
+			"name":        rows[i].NameHighlight,
+			"description": rows[i].DescriptionHighlight,
+		} {
+			if snippet != nil && strings.Contains(*snippet, "<mark>") {
+				if hit.Highlights == nil {
+					hit.Highlights = make(map[string]string)
+				}
+				hit.Highlights[field] = *snippet
+			}
+		}
+		result.Hits[i] = hit
+	}
+
+	countQuery := `SELECT COUNT(*) FROM nfts n ` + where
+	if err := r.db.Get(&result.Total, countQuery, args...); err != nil {
+		return nil, err
+	}
+
+	facets := map[string]*[]FacetCount{
+		"chains":      &result.Facets.Chains,
+		"tags":        &result.Facets.Tags,
+		"collections": &result.Facets.Collections,
+		"creators":    &result.Facets.Creators,
+	}
+	for name, dest := range facets {
+		*dest = []FacetCount{}
+		if err := r.db.Select(dest, fmt.Sprintf(facetQueries[name], where), args...); err != nil {
+			return nil, fmt.Errorf("counting %s facet: %w", name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// where builds the WHERE clause and arguments for the search
+func (s NFTSearch) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if s.Query != "" {
+		add("n.search_vector @@ websearch_to_tsquery('english', $%d)", s.Query)
+	}
+	if s.Chain != "" {
+		add("n.chain = $%d", s.Chain)
+	}
+	if len(s.Tags) > 0 {
+		add("n.tags @> $%d", pq.Array(s.Tags))
+	}
+	if s.CollectionID != nil {
+		add("n.collection_id = $%d", *s.CollectionID)
+	}
+	if s.CreatorID != nil {
+		add("n.creator_id = $%d", *s.CreatorID)
+	}
+	if len(s.Attributes) > 0 {
+		add("n.attributes @> $%d::jsonb", string(s.Attributes))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Page sizes of list queries
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Cursor is a position in a list ordered newest first by (created_at, id)
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	// Before pages towards newer rows instead of older ones
+	Before bool
+}
+
+// PageRequest asks for up to Limit rows past Cursor, or the first page when
+// Cursor is nil
+type PageRequest struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+// Page holds the cursors of the neighbouring pages; nil when there is none
+type Page struct {
+	Next *Cursor
+	Prev *Cursor
+}
+
+// after returns the condition selecting the rows past the cursor, appending
+// its values to args. It is empty without a cursor.
+func (p PageRequest) after(alias string, args []interface{}) (string, []interface{}) {
+	if p.Cursor == nil {
+		return "", args
+	}
+
+	op := "<"
+	if p.Cursor.Before {
+		op = ">"
+	}
+	args = append(args, p.Cursor.CreatedAt, p.Cursor.ID)
+	return fmt.Sprintf("(%s.created_at, %s.id) %s ($%d, $%d)", alias, alias, op, len(args)-1, len(args)), args
+}
+
+// orderBy orders rows moving away from the cursor
+func (p PageRequest) orderBy(alias string) string {
+	dir := "DESC"
+	if p.Cursor != nil && p.Cursor.Before {
+		dir = "ASC"
+	}
+	return fmt.Sprintf("%s.created_at %s, %s.id %s", alias, dir, alias, dir)
+}
+
+// fetchLimit fetches one row more than the page to tell whether another
+// page follows
+func (p PageRequest) fetchLimit() int {
+	return p.Limit + 1
+}
+
+// paginate drops the extra row fetched by fetchLimit, puts backward pages
+// back in newest first order and sets the cursors of the neighbouring pages
+func paginate[T any](rows []T, p PageRequest, key func(T) Cursor) ([]T, Page) {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+
+	backward := p.Cursor != nil && p.Cursor.Before
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	var page Page
+	if len(rows) == 0 {
+		return rows, page
+	}
+	// Coming from a cursor means rows exist on its side
+	if more || backward {
+		next := key(rows[len(rows)-1])
+		page.Next = &next
+	}
+	if (backward && more) || (!backward && p.Cursor != nil) {
+		prev := key(rows[0])
+		prev.Before = true
+		page.Prev = &prev
+	}
+	return rows, page
+}
+
+// and joins a condition onto a possibly empty WHERE clause
+func and(where, condition string) string {
+	switch {
+	case condition == "":
+		return where
+	case where == "":
+		return "WHERE " + condition
+	default:
+		return where + " AND " + condition
+	}
+}
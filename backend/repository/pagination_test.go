@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testRows are ten rows, newest first
+func testRows() []Cursor {
+	rows := make([]Cursor, 10)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range rows {
+		rows[i] = Cursor{CreatedAt: start.Add(-time.Duration(i) * time.Minute), ID: uuid.New()}
+	}
+	return rows
+}
+
+func identity(c Cursor) Cursor { return c }
+
+func TestPaginateFirstPage(t *testing.T) {
+	rows := testRows()
+	page, cursors := paginate(rows[:4], PageRequest{Limit: 3}, identity)
+
+	if len(page) != 3 || page[0] != rows[0] {
+		t.Fatalf("page = %v, want the three newest rows", page)
+	}
+	if cursors.Prev != nil {
+		t.Errorf("first page has prev cursor %+v", cursors.Prev)
+	}
+	if cursors.Next == nil || cursors.Next.ID != rows[2].ID || cursors.Next.Before {
+		t.Errorf("next = %+v, want after the third row", cursors.Next)
+	}
+}
+
+func TestPaginateLastPage(t *testing.T) {
+	rows := testRows()
+	from := rows[6]
+	page, cursors := paginate(rows[7:], PageRequest{Cursor: &from, Limit: 3}, identity)
+
+	if len(page) != 3 || cursors.Next != nil {
+		t.Errorf("last page: %d rows, next %+v", len(page), cursors.Next)
+	}
+	if cursors.Prev == nil || cursors.Prev.ID != rows[7].ID || !cursors.Prev.Before {
+		t.Errorf("prev = %+v, want before the first row of the page", cursors.Prev)
+	}
+}
+
+func TestPaginateBackward(t *testing.T) {
+	rows := testRows()
+	// Paging back from row 5 fetches rows 4, 3, 2, 1 in ascending order
+	from := Cursor{CreatedAt: rows[5].CreatedAt, ID: rows[5].ID, Before: true}
+	fetched := []Cursor{rows[4], rows[3], rows[2], rows[1]}
+	page, cursors := paginate(fetched, PageRequest{Cursor: &from, Limit: 3}, identity)
+
+	if len(page) != 3 || page[0] != rows[2] || page[2] != rows[4] {
+		t.Fatalf("page = %v, want rows 2-4 newest first", page)
+	}
+	if cursors.Prev == nil || cursors.Prev.ID != rows[2].ID {
+		t.Errorf("prev = %+v, want before row 2", cursors.Prev)
+	}
+	if cursors.Next == nil || cursors.Next.ID != rows[4].ID || cursors.Next.Before {
+		t.Errorf("next = %+v, want after row 4", cursors.Next)
+	}
+}
+
+func TestPageRequestKeyset(t *testing.T) {
+	if condition, args := (PageRequest{}).after("n", nil); condition != "" || args != nil {
+		t.Errorf("first page condition = %q %v", condition, args)
+	}
+
+	cursor := &Cursor{CreatedAt: time.Now(), ID: uuid.New(), Before: true}
+	p := PageRequest{Cursor: cursor, Limit: 5}
+	condition, args := p.after("ml", []interface{}{"active"})
+	if condition != "(ml.created_at, ml.id) > ($2, $3)" || len(args) != 3 {
+		t.Errorf("condition = %q with %d args", condition, len(args))
+	}
+	if order := p.orderBy("ml"); order != "ml.created_at ASC, ml.id ASC" {
+		t.Errorf("order = %q", order)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrPreferencesNotFound is returned when a user has not set preferences
+var ErrPreferencesNotFound = errors.New("preferences not found")
+
+// PreferencesRepository handles user preference database operations
+type PreferencesRepository struct {
+	db DBTX
+}
+
+// NewPreferencesRepository creates a new preferences repository
+func NewPreferencesRepository() *PreferencesRepository {
+	return &PreferencesRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *PreferencesRepository) WithTx(tx *sqlx.Tx) *PreferencesRepository {
+	return &PreferencesRepository{
+		db: tx,
+	}
+}
+
+// GetByUserID retrieves a user's preferences
+func (r *PreferencesRepository) GetByUserID(userID uuid.UUID) (*models.UserPreferences, error) {
+	var prefs models.UserPreferences
+	query := `SELECT * FROM user_preferences WHERE user_id = $1`
+
+	err := r.db.Get(&prefs, query, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrPreferencesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// Upsert replaces the user's preferences, creating them on first save, and
+// refreshes prefs from the stored row
+func (r *PreferencesRepository) Upsert(prefs *models.UserPreferences) error {
+	// A nil json.RawMessage would be sent as an empty string, not NULL
+	var priceRange interface{}
+	if len(prefs.PreferredPriceRange) > 0 {
+		priceRange = string(prefs.PreferredPriceRange)
+	}
+
+	query := `
+		INSERT INTO user_preferences (
+			id, user_id, preferred_categories, preferred_price_range,
+			preferred_creators, excluded_tags
+		) VALUES (
+			$1, $2, $3, $4::jsonb, $5::uuid[], $6
+		)
+		ON CONFLICT (user_id) DO UPDATE SET
+			preferred_categories = EXCLUDED.preferred_categories,
+			preferred_price_range = EXCLUDED.preferred_price_range,
+			preferred_creators = EXCLUDED.preferred_creators,
+			excluded_tags = EXCLUDED.excluded_tags
+		RETURNING *`
+
+	return r.db.Get(prefs, query,
+		prefs.ID, prefs.UserID, prefs.PreferredCategories, priceRange,
+		prefs.PreferredCreators, prefs.ExcludedTags)
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// recommendationRow is a cached recommendation joined with its NFT
+type recommendationRow struct {
+	models.Recommendation
+	RecommendedNFT models.NFT `db:"nft"`
+}
+
+// RecommendationRepository handles the recommendations cache
+type RecommendationRepository struct {
+	db DBTX
+}
+
+// NewRecommendationRepository creates a new recommendation repository
+func NewRecommendationRepository() *RecommendationRepository {
+	return &RecommendationRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *RecommendationRepository) WithTx(tx *sqlx.Tx) *RecommendationRepository {
+	return &RecommendationRepository{
+		db: tx,
+	}
+}
+
+// GetFresh retrieves the user's unexpired recommendations produced by the
+// algorithm version, best first, with their NFTs
+func (r *RecommendationRepository) GetFresh(userID uuid.UUID, version string, limit int) ([]*models.Recommendation, error) {
+	var rows []recommendationRow
+	query := `
+		SELECT r.*,
+			   ` + prefixedColumns("n", "nft", nftColumnNames) + `
+		FROM recommendations r
+		JOIN nfts n ON r.nft_id = n.id
+		WHERE r.user_id = $1
+		  AND r.algorithm_version = $2
+		  AND r.expires_at > NOW()
+		ORDER BY r.score DESC, n.created_at DESC
+		LIMIT $3`
+
+	if err := r.db.Select(&rows, query, userID, version, limit); err != nil {
+		return nil, err
+	}
+
+	recommendations := make([]*models.Recommendation, len(rows))
+	for i := range rows {
+		recommendation := rows[i].Recommendation
+		nft := rows[i].RecommendedNFT
+		recommendation.NFT = &nft
+		recommendations[i] = &recommendation
+	}
+	return recommendations, nil
+}
+
+// Replace swaps the user's cached recommendations for the algorithm version
+// with recommendations that expire at expiresAt. Run it in a transaction so
+// readers never see a partial set.
+func (r *RecommendationRepository) Replace(userID uuid.UUID, version string, recommendations []*models.Recommendation, expiresAt time.Time) error {
+	query := `DELETE FROM recommendations WHERE user_id = $1 AND algorithm_version = $2`
+	if _, err := r.db.Exec(query, userID, version); err != nil {
+		return err
+	}
+
+	insert := `
+		INSERT INTO recommendations (
+			id, user_id, nft_id, score, reason, rule, algorithm_version,
+			created_at, expires_at
+		) VALUES (
+			:id, :user_id, :nft_id, :score, :reason, :rule, :algorithm_version,
+			:created_at, :expires_at
+		)`
+	now := time.Now()
+	for _, recommendation := range recommendations {
+		recommendation.UserID = userID
+		recommendation.AlgorithmVersion = version
+		recommendation.CreatedAt = now
+		recommendation.ExpiresAt = &expiresAt
+		if _, err := r.db.NamedExec(insert, recommendation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteForUser drops the user's cached recommendations of every algorithm
+// version, e.g. after their preferences changed
+func (r *RecommendationRepository) DeleteForUser(userID uuid.UUID) error {
+	query := `DELETE FROM recommendations WHERE user_id = $1`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
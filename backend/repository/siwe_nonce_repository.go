@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"database/sql"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SIWENonceRepository handles the outstanding sign-in challenges
+type SIWENonceRepository struct {
+	db DBTX
+}
+
+// NewSIWENonceRepository creates a new sign-in challenge repository
+func NewSIWENonceRepository() *SIWENonceRepository {
+	return &SIWENonceRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *SIWENonceRepository) WithTx(tx *sqlx.Tx) *SIWENonceRepository {
+	return &SIWENonceRepository{
+		db: tx,
+	}
+}
+
+// Create stores a challenge valid for ttl and drops the expired ones
+func (r *SIWENonceRepository) Create(nonce *models.SIWENonce, ttl time.Duration) error {
+	if _, err := r.db.Exec(`DELETE FROM siwe_nonces WHERE expires_at <= NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO siwe_nonces (nonce, wallet_address, expires_at)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3))
+		RETURNING *`
+
+	return r.db.Get(nonce, query, nonce.Nonce, strings.ToLower(nonce.WalletAddress), ttl.Seconds())
+}
+
+// Get retrieves an unexpired challenge, or nil when there is none
+func (r *SIWENonceRepository) Get(nonce string) (*models.SIWENonce, error) {
+	var challenge models.SIWENonce
+	err := r.db.Get(&challenge, `SELECT * FROM siwe_nonces WHERE nonce = $1 AND expires_at > NOW()`, nonce)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// Consume deletes an unexpired challenge, reporting whether it was still
+// there, so each challenge can complete at most one login
+func (r *SIWENonceRepository) Consume(nonce string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM siwe_nonces WHERE nonce = $1 AND expires_at > NOW()`, nonce)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrTrainingJobNotFound is returned when a training job does not exist
+	ErrTrainingJobNotFound = errors.New("training job not found")
+	// ErrTrainingJobActive is returned when a training is already queued or running
+	ErrTrainingJobActive = errors.New("a training job is already queued or running")
+)
+
+// TrainingJobRepository handles training job database operations
+type TrainingJobRepository struct {
+	db DBTX
+}
+
+// NewTrainingJobRepository creates a new training job repository
+func NewTrainingJobRepository() *TrainingJobRepository {
+	return &TrainingJobRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *TrainingJobRepository) WithTx(tx *sqlx.Tx) *TrainingJobRepository {
+	return &TrainingJobRepository{
+		db: tx,
+	}
+}
+
+// Create creates a new training job, failing if one is already active
+func (r *TrainingJobRepository) Create(job *models.TrainingJob) error {
+	query := `
+		INSERT INTO training_jobs (
+			id, status, force_retrain, requested_by, run_after
+		) VALUES (
+			:id, :status, :force_retrain, :requested_by, :run_after
+		)`
+
+	_, err := r.db.NamedExec(query, job)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "idx_training_jobs_active" {
+		return ErrTrainingJobActive
+	}
+	return err
+}
+
+// GetByID retrieves a training job by ID
+func (r *TrainingJobRepository) GetByID(id uuid.UUID) (*models.TrainingJob, error) {
+	var job models.TrainingJob
+	query := `SELECT * FROM training_jobs WHERE id = $1`
+
+	err := r.db.Get(&job, query, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrTrainingJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetActive retrieves the queued or running training job
+func (r *TrainingJobRepository) GetActive() (*models.TrainingJob, error) {
+	var job models.TrainingJob
+	query := `SELECT * FROM training_jobs WHERE status IN ('queued', 'training')`
+
+	err := r.db.Get(&job, query)
+	if err == sql.ErrNoRows {
+		return nil, ErrTrainingJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetTraining retrieves the jobs whose training the ai-engine is running
+func (r *TrainingJobRepository) GetTraining() ([]*models.TrainingJob, error) {
+	var jobs []*models.TrainingJob
+	query := `SELECT * FROM training_jobs WHERE status = 'training' ORDER BY triggered_at`
+
+	err := r.db.Select(&jobs, query)
+	return jobs, err
+}
+
+// ClaimNext leases the oldest runnable queued job for the given duration.
+// Jobs whose lease expired, e.g. after a crash, are claimed again. It returns
+// nil when no job is runnable.
+func (r *TrainingJobRepository) ClaimNext(lease time.Duration) (*models.TrainingJob, error) {
+	var job models.TrainingJob
+	query := `
+		UPDATE training_jobs SET
+			attempts = attempts + 1,
+			locked_until = NOW() + make_interval(secs => $1)
+		WHERE id = (
+			SELECT id FROM training_jobs
+			WHERE status = 'queued'
+			  AND run_after <= NOW()
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY run_after
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`
+
+	err := r.db.Get(&job, query, lease.Seconds())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// MarkTraining records the exported dataset and that the ai-engine started
+// training
+func (r *TrainingJobRepository) MarkTraining(job *models.TrainingJob) error {
+	query := `
+		UPDATE training_jobs SET
+			status = 'training',
+			dataset_path = :dataset_path,
+			interaction_count = :interaction_count,
+			nft_count = :nft_count,
+			preference_count = :preference_count,
+			previous_trained_at = :previous_trained_at,
+			message = :message,
+			error = NULL,
+			triggered_at = NOW(),
+			locked_until = NULL
+		WHERE id = :id`
+
+	_, err := r.db.NamedExec(query, job)
+	return err
+}
+
+// Complete marks the job completed with the model version it produced
+func (r *TrainingJobRepository) Complete(id uuid.UUID, modelVersion, message string) error {
+	query := `
+		UPDATE training_jobs SET
+			status = 'completed',
+			model_version = $1,
+			message = COALESCE(NULLIF($2, ''), message),
+			error = NULL,
+			completed_at = NOW(),
+			locked_until = NULL
+		WHERE id = $3`
+
+	_, err := r.db.Exec(query, modelVersion, message, id)
+	return err
+}
+
+// Retry releases the job so it runs again after runAfter
+func (r *TrainingJobRepository) Retry(id uuid.UUID, message string, runAfter time.Time) error {
+	query := `
+		UPDATE training_jobs SET
+			error = $1,
+			run_after = $2,
+			locked_until = NULL
+		WHERE id = $3`
+
+	_, err := r.db.Exec(query, message, runAfter, id)
+	return err
+}
+
+// Fail marks the job failed
+func (r *TrainingJobRepository) Fail(id uuid.UUID, message string) error {
+	query := `
+		UPDATE training_jobs SET
+			status = 'failed',
+			error = $1,
+			completed_at = NOW(),
+			locked_until = NULL
+		WHERE id = $2`
+
+	_, err := r.db.Exec(query, message, id)
+	return err
+}
+
+// ExportDataset reads the interactions, NFT tags and attributes, and user
+// preferences a training uses. Run it in a transaction so the three reads
+// see the same snapshot.
+func (r *TrainingJobRepository) ExportDataset() (*models.TrainingDataset, error) {
+	if _, err := r.db.Exec(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`); err != nil {
+		return nil, err
+	}
+
+	dataset := &models.TrainingDataset{
+		ExportedAt:   time.Now(),
+		Interactions: []*models.UserInteraction{},
+		NFTs:         []*models.TrainingNFT{},
+		Preferences:  []*models.UserPreferences{},
+	}
+	if err := r.db.Select(&dataset.Interactions, `SELECT * FROM user_interactions ORDER BY created_at, id`); err != nil {
+		return nil, err
+	}
+	if err := r.db.Select(&dataset.NFTs, `SELECT id, creator_id, tags, attributes FROM nfts ORDER BY created_at, id`); err != nil {
+		return nil, err
+	}
+	if err := r.db.Select(&dataset.Preferences, `SELECT * FROM user_preferences ORDER BY created_at, id`); err != nil {
+		return nil, err
+	}
+	return dataset, nil
+}
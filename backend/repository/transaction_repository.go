@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrTransactionNotFound is returned when a transaction does not exist
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrTransactionHashUsed is returned when a chain transaction is already
+// recorded and can't back another purchase
+var ErrTransactionHashUsed = errors.New("transaction hash already used")
+
+// TransactionFilter holds the optional filters for listing transactions
+type TransactionFilter struct {
+	NFTID  *uuid.UUID
+	UserID *uuid.UUID // sender or recipient
+	Page   PageRequest
+}
+
+// TransactionRepository handles transaction database operations
+type TransactionRepository struct {
+	db DBTX
+}
+
+// NewTransactionRepository creates a new transaction repository
+func NewTransactionRepository() *TransactionRepository {
+	return &TransactionRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *TransactionRepository) WithTx(tx *sqlx.Tx) *TransactionRepository {
+	return &TransactionRepository{
+		db: tx,
+	}
+}
+
+// Create creates a new transaction, failing if it is a purchase whose hash
+// backs another purchase
+func (r *TransactionRepository) Create(transaction *models.Transaction) error {
+	query := `
+		INSERT INTO transactions (
+			id, type, nft_id, from_user_id, to_user_id,
+			transaction_hash, block_number, price, gas_fee, status
+		) VALUES (
+			:id, :type, :nft_id, :from_user_id, :to_user_id,
+			:transaction_hash, :block_number, :price, :gas_fee, :status
+		)`
+
+	_, err := r.db.NamedExec(query, transaction)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "idx_transactions_purchase_hash" {
+		return ErrTransactionHashUsed
+	}
+	return err
+}
+
+// HashUsed reports whether any transaction was recorded with the given hash
+func (r *TransactionRepository) HashUsed(hash string) (bool, error) {
+	var used bool
+	query := `SELECT EXISTS (SELECT 1 FROM transactions WHERE LOWER(transaction_hash) = LOWER($1))`
+
+	err := r.db.Get(&used, query, hash)
+	return used, err
+}
+
+// GetByID retrieves a transaction by ID
+func (r *TransactionRepository) GetByID(id uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	query := `SELECT * FROM transactions WHERE id = $1`
+
+	err := r.db.Get(&transaction, query, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// GetByNFT retrieves the transaction history of an NFT
+func (r *TransactionRepository) GetByNFT(nftID uuid.UUID) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	query := `
+		SELECT * FROM transactions
+		WHERE nft_id = $1
+		ORDER BY created_at DESC`
+
+	err := r.db.Select(&transactions, query, nftID)
+	return transactions, err
+}
+
+// List retrieves a page of transactions matching the filter, newest first
+func (r *TransactionRepository) List(filter TransactionFilter) ([]*models.Transaction, Page, error) {
+	var where string
+	var args []interface{}
+	if filter.NFTID != nil {
+		args = append(args, *filter.NFTID)
+		where = and(where, fmt.Sprintf("t.nft_id = $%d", len(args)))
+	}
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where = and(where, fmt.Sprintf("(t.from_user_id = $%d OR t.to_user_id = $%d)", len(args), len(args)))
+	}
+	condition, args := filter.Page.after("t", args)
+
+	transactions := []*models.Transaction{}
+	query := fmt.Sprintf(`
+		SELECT * FROM transactions t
+		%s
+		ORDER BY %s
+		LIMIT $%d`, and(where, condition), filter.Page.orderBy("t"), len(args)+1)
+
+	if err := r.db.Select(&transactions, query, append(args, filter.Page.fetchLimit())...); err != nil {
+		return nil, Page{}, err
+	}
+
+	transactions, cursors := paginate(transactions, filter.Page, func(t *models.Transaction) Cursor {
+		return Cursor{CreatedAt: t.CreatedAt, ID: t.ID}
+	})
+	return transactions, cursors, nil
+}
+
+// GetPending retrieves the oldest pending transactions that have a hash
+func (r *TransactionRepository) GetPending(limit int) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	query := `
+		SELECT * FROM transactions
+		WHERE status = 'pending' AND transaction_hash IS NOT NULL
+		ORDER BY created_at
+		LIMIT $1`
+
+	err := r.db.Select(&transactions, query, limit)
+	return transactions, err
+}
+
+// GetConfirmedSince retrieves transactions confirmed on chain since the given
+// time, newest first
+func (r *TransactionRepository) GetConfirmedSince(since time.Time, limit int) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	query := `
+		SELECT * FROM transactions
+		WHERE status = 'confirmed' AND transaction_hash IS NOT NULL
+		  AND confirmed_at >= $1
+		ORDER BY confirmed_at DESC
+		LIMIT $2`
+
+	err := r.db.Select(&transactions, query, since, limit)
+	return transactions, err
+}
+
+// RecordReceipt stores the block and gas fee of a mined pending transaction
+func (r *TransactionRepository) RecordReceipt(id uuid.UUID, blockNumber int64, blockHash string, gasFee float64) error {
+	query := `
+		UPDATE transactions SET
+			block_number = $1,
+			block_hash = $2,
+			gas_fee = $3
+		WHERE id = $4 AND status = 'pending'`
+
+	_, err := r.db.Exec(query, blockNumber, blockHash, gasFee, id)
+	return err
+}
+
+// ClearReceipt forgets the block of a pending transaction that is no longer
+// mined, e.g. after a reorg
+func (r *TransactionRepository) ClearReceipt(id uuid.UUID) error {
+	query := `
+		UPDATE transactions SET
+			block_number = NULL,
+			block_hash = NULL
+		WHERE id = $1 AND status = 'pending'`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// Finalize moves a pending transaction to confirmed or failed. It reports
+// whether the transaction was still pending.
+func (r *TransactionRepository) Finalize(id uuid.UUID, status string) (bool, error) {
+	query := `
+		UPDATE transactions SET
+			status = $1,
+			confirmed_at = NOW()
+		WHERE id = $2 AND status = 'pending'`
+
+	return r.execChanged(query, status, id)
+}
+
+// Reopen moves a confirmed transaction back to pending after its block was
+// reorganised away. It reports whether the transaction was confirmed.
+func (r *TransactionRepository) Reopen(id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE transactions SET
+			status = 'pending',
+			block_number = NULL,
+			block_hash = NULL,
+			confirmed_at = NULL
+		WHERE id = $1 AND status = 'confirmed'`
+
+	return r.execChanged(query, id)
+}
+
+// execChanged runs an update and reports whether it matched a row
+func (r *TransactionRepository) execChanged(query string, args ...interface{}) (bool, error) {
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
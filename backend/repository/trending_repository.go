@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TrendingWindow is a period trending NFTs are ranked over
+type TrendingWindow struct {
+	Period string
+	Length time.Duration
+}
+
+// TrendingWindows are the periods trending NFTs are precomputed for
+var TrendingWindows = []TrendingWindow{
+	{Period: "1h", Length: time.Hour},
+	{Period: "24h", Length: 24 * time.Hour},
+	{Period: "7d", Length: 7 * 24 * time.Hour},
+	{Period: "30d", Length: 30 * 24 * time.Hour},
+}
+
+// IsTrendingPeriod reports whether trending NFTs are computed for period
+func IsTrendingPeriod(period string) bool {
+	for _, window := range TrendingWindows {
+		if window.Period == period {
+			return true
+		}
+	}
+	return false
+}
+
+// TrendingScoring weighs the activity behind a trending score
+type TrendingScoring struct {
+	Gravity      float64 // how fast older activity fades
+	VolumeWeight float64 // points per unit of sale price
+	Size         int     // NFTs kept per window
+}
+
+// trendingRow is a trending rank joined with its NFT
+type trendingRow struct {
+	models.TrendingNFT
+	RankedNFT models.NFT `db:"nft"`
+}
+
+// TrendingRepository handles the precomputed trending ranks
+type TrendingRepository struct {
+	db DBTX
+}
+
+// NewTrendingRepository creates a new trending repository
+func NewTrendingRepository() *TrendingRepository {
+	return &TrendingRepository{
+		db: database.DB,
+	}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *TrendingRepository) WithTx(tx *sqlx.Tx) *TrendingRepository {
+	return &TrendingRepository{
+		db: tx,
+	}
+}
+
+// Refresh recomputes the ranks of a window. Every interaction and confirmed
+// sale in the window scores its weight (the interaction weight, or the sale
+// price times VolumeWeight) divided by (hours since it happened + 2) raised
+// to Gravity, as Hacker News ranks stories, so recent activity outweighs
+// older activity of the same kind. Run it in a transaction so readers never
+// see a partial ranking.
+func (r *TrendingRepository) Refresh(window TrendingWindow, scoring TrendingScoring) error {
+	if _, err := r.db.Exec(`DELETE FROM trending_scores WHERE period = $1`, window.Period); err != nil {
+		return err
+	}
+
+	query := `
+		WITH events AS (
+			SELECT nft_id, interaction_value::float8 as points, created_at as happened_at,
+				   0::numeric as price, false as sale
+			FROM user_interactions
+			WHERE created_at > NOW() - make_interval(secs => $1)
+			UNION ALL
+			SELECT nft_id, $3::float8 * price::float8, COALESCE(confirmed_at, created_at),
+				   price, true
+			FROM transactions
+			WHERE type = 'purchase' AND status = 'confirmed'
+			  AND COALESCE(confirmed_at, created_at) > NOW() - make_interval(secs => $1)
+		),
+		scored AS (
+			SELECT nft_id,
+				   SUM(points / POWER(GREATEST(EXTRACT(EPOCH FROM NOW() - happened_at)::float8, 0) / 3600 + 2, $2::float8)) as score,
+				   COUNT(*) FILTER (WHERE NOT sale) as interactions,
+				   COUNT(*) FILTER (WHERE sale) as sales,
+				   COALESCE(SUM(price), 0) as volume
+			FROM events
+			WHERE nft_id IS NOT NULL
+			GROUP BY nft_id
+		)
+		INSERT INTO trending_scores (
+			period, nft_id, rank, score, interactions, sales, volume, computed_at
+		)
+		SELECT $4, nft_id, ROW_NUMBER() OVER (ORDER BY score DESC, nft_id),
+			   score, interactions, sales, volume, NOW()
+		FROM scored
+		ORDER BY score DESC, nft_id
+		LIMIT $5`
+
+	_, err := r.db.Exec(query, window.Length.Seconds(), scoring.Gravity, scoring.VolumeWeight, window.Period, scoring.Size)
+	return err
+}
+
+// Get retrieves the top ranked NFTs of a period with their NFTs
+func (r *TrendingRepository) Get(period string, limit int) ([]*models.TrendingNFT, error) {
+	var rows []trendingRow
+	query := `
+		SELECT t.*,
+			   ` + prefixedColumns("n", "nft", nftColumnNames) + `
+		FROM trending_scores t
+		JOIN nfts n ON t.nft_id = n.id
+		WHERE t.period = $1
+		ORDER BY t.rank
+		LIMIT $2`
+
+	if err := r.db.Select(&rows, query, period, limit); err != nil {
+		return nil, err
+	}
+
+	trending := make([]*models.TrendingNFT, len(rows))
+	for i := range rows {
+		ranked := rows[i].TrendingNFT
+		nft := rows[i].RankedNFT
+		ranked.NFT = &nft
+		trending[i] = &ranked
+	}
+	return trending, nil
+}
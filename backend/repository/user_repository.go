@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"nftgenie/backend/database"
 	"nftgenie/backend/models"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -27,10 +28,10 @@ func (r *UserRepository) Create(user *models.User) error {
 	query := `
 		INSERT INTO users (
 			id, wallet_address, username, bio, profile_image,
-			email, is_verified, nonce
+			email, is_verified
 		) VALUES (
 			:id, :wallet_address, :username, :bio, :profile_image,
-			:email, :is_verified, :nonce
+			:email, :is_verified
 		)`
 	
 	_, err := r.db.NamedExec(query, user)
@@ -49,12 +50,13 @@ func (r *UserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 	return &user, err
 }
 
-// GetByWalletAddress retrieves a user by wallet address
+// GetByWalletAddress retrieves a user by wallet address in any letter case;
+// addresses are stored lowercased
 func (r *UserRepository) GetByWalletAddress(walletAddress string) (*models.User, error) {
 	var user models.User
 	query := `SELECT * FROM users WHERE wallet_address = $1`
 	
-	err := r.db.Get(&user, query, walletAddress)
+	err := r.db.Get(&user, query, strings.ToLower(walletAddress))
 	if err == sql.ErrNoRows {
 		return nil, nil // User doesn't exist yet
 	}
@@ -89,13 +91,6 @@ func (r *UserRepository) Update(user *models.User) error {
 	return err
 }
 
-// UpdateNonce updates user's nonce for signature verification
-func (r *UserRepository) UpdateNonce(userID uuid.UUID, nonce string) error {
-	query := `UPDATE users SET nonce = $1 WHERE id = $2`
-	_, err := r.db.Exec(query, nonce, userID)
-	return err
-}
-
 // Delete deletes a user
 func (r *UserRepository) Delete(id uuid.UUID) error {
 	query := `DELETE FROM users WHERE id = $1`
@@ -103,28 +98,25 @@ func (r *UserRepository) Delete(id uuid.UUID) error {
 	return err
 }
 
-// GetAll retrieves all users with pagination
-func (r *UserRepository) GetAll(limit, offset int) ([]*models.User, int, error) {
-	var users []*models.User
-	query := `
-		SELECT * FROM users 
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2`
-	
-	err := r.db.Select(&users, query, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM users`
-	err = r.db.Get(&total, countQuery)
+// GetAll retrieves a page of users, newest first
+func (r *UserRepository) GetAll(page PageRequest) ([]*models.User, Page, error) {
+	users := []*models.User{}
+	condition, args := page.after("u", nil)
+	query := fmt.Sprintf(`
+		SELECT * FROM users u
+		%s
+		ORDER BY %s
+		LIMIT $%d`, and("", condition), page.orderBy("u"), len(args)+1)
+	
+	err := r.db.Select(&users, query, append(args, page.fetchLimit())...)
 	if err != nil {
-		return nil, 0, err
+		return nil, Page{}, err
 	}
 	
-	return users, total, nil
+	users, cursors := paginate(users, page, func(u *models.User) Cursor {
+		return Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+	})
+	return users, cursors, nil
 }
 
 // GetTopCreators retrieves top creators by NFT count
@@ -172,10 +164,10 @@ func (r *UserRepository) CreateOrUpdate(user *models.User) error {
 	query := `
 		INSERT INTO users (
 			id, wallet_address, username, bio, profile_image,
-			email, is_verified, nonce
+			email, is_verified
 		) VALUES (
 			:id, :wallet_address, :username, :bio, :profile_image,
-			:email, :is_verified, :nonce
+			:email, :is_verified
 		)
 		ON CONFLICT (wallet_address) 
 		DO UPDATE SET
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"nftgenie/backend/config"
+	"nftgenie/backend/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AIEngineHealth is the ai-engine's health report
+type AIEngineHealth struct {
+	Status       string  `json:"status"`
+	ModelVersion string  `json:"model_version"`
+	LastTrained  *string `json:"last_trained"`
+	CacheStatus  string  `json:"cache_status"`
+}
+
+// Model returns the registry entry of the model the engine is serving. The
+// engine reports one model_version across trainings, so the version is
+// qualified with when the model was trained, e.g. ai-1.0.0@20261016T120000.
+func (h *AIEngineHealth) Model() *models.ModelVersion {
+	engineVersion := h.ModelVersion
+	model := &models.ModelVersion{
+		Version:       "ai-" + engineVersion + "@untrained",
+		Source:        models.ModelSourceAIEngine,
+		EngineVersion: &engineVersion,
+	}
+	if h.LastTrained != nil {
+		if trainedAt, err := parseEngineTime(*h.LastTrained); err == nil {
+			model.Version = "ai-" + engineVersion + "@" + trainedAt.Format("20060102T150405")
+			model.TrainedAt = &trainedAt
+		}
+	}
+	return model
+}
+
+// parseEngineTime parses the ai-engine's ISO 8601 timestamps, which Python
+// writes without a zone for local times
+func parseEngineTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02T15:04:05.999999999", value)
+}
+
+// AIRecommendRequest asks the ai-engine for a user's recommendations. Users
+// are identified by their users.id UUID, as in the engine's interactions.
+type AIRecommendRequest struct {
+	UserID       string `json:"user_id"`
+	Limit        int    `json:"limit"`
+	Strategy     string `json:"strategy"` // hybrid, collaborative, content or trending
+	ExcludeOwned bool   `json:"exclude_owned"`
+	Diversify    bool   `json:"diversify"`
+}
+
+// AIRecommendation is an NFT recommended by the ai-engine
+type AIRecommendation struct {
+	NFTID    string   `json:"nft_id"`
+	Name     string   `json:"name"`
+	ImageURL string   `json:"image_url"`
+	Score    float64  `json:"score"`
+	Reason   string   `json:"reason"`
+	Price    float64  `json:"price"`
+	Creator  string   `json:"creator"`
+	Tags     []string `json:"tags"`
+}
+
+// AIInteraction is a user interaction reported to the ai-engine
+type AIInteraction struct {
+	UserID          string  `json:"user_id"`
+	NFTID           string  `json:"nft_id"`
+	InteractionType string  `json:"interaction_type"` // view, like, purchase or mint
+	Value           float64 `json:"value"`
+}
+
+// AITrainResponse is the ai-engine's answer to a training request
+type AITrainResponse struct {
+	Status      string  `json:"status"` // training or skipped
+	Message     string  `json:"message"`
+	LastTrained *string `json:"last_trained,omitempty"`
+}
+
+// AIExplanation explains why the ai-engine recommends an NFT to a user
+type AIExplanation struct {
+	UserID      string `json:"user_id"`
+	NFTID       string `json:"nft_id"`
+	Explanation struct {
+		Factors []AIExplanationFactor `json:"factors"`
+	} `json:"explanation"`
+}
+
+// AIExplanationFactor is one signal behind a recommendation
+type AIExplanationFactor struct {
+	Type        string  `json:"type"`
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
+
+// AITrendingNFT is an NFT ranked by the ai-engine's trending strategy
+type AITrendingNFT struct {
+	NFTID      string  `json:"nft_id"`
+	Name       string  `json:"name"`
+	ImageURL   string  `json:"image_url"`
+	TrendScore float64 `json:"trend_score"`
+	Views      int     `json:"views"`
+	Likes      int     `json:"likes"`
+	Price      float64 `json:"price"`
+}
+
+// healthProbeTimeout bounds a health probe of the ai-engine
+const healthProbeTimeout = 2 * time.Second
+
+// AIEngineClient calls the Python ai-engine through the resilient client.
+// Callers check Healthy before relying on it and fall back to SQL otherwise.
+type AIEngineClient struct {
+	BaseURL   string
+	APIKey    string
+	HealthTTL time.Duration
+
+	client *ResilientClient
+
+	mu        sync.Mutex
+	health    *AIEngineHealth
+	checkedAt time.Time
+	probing   chan struct{} // closed when the running probe finishes
+}
+
+// NewAIEngineClient creates a client for AI_ENGINE_URL; it is never healthy
+// when the URL is unset
+func NewAIEngineClient() *AIEngineClient {
+	return &AIEngineClient{
+		BaseURL:   config.String("AI_ENGINE_URL", ""),
+		APIKey:    config.String("AI_ENGINE_API_KEY", ""),
+		HealthTTL: config.Duration("AI_ENGINE_HEALTH_TTL", 30*time.Second),
+		client:    NewResilientClient(ClientConfigFromEnv("ai-engine", "AI_ENGINE")),
+	}
+}
+
+// Health fetches the engine's health report
+func (c *AIEngineClient) Health(ctx context.Context) (*AIEngineHealth, error) {
+	var health AIEngineHealth
+	if err := c.get(ctx, "/", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Healthy returns the engine's health report when it is up. The result of
+// each probe is reused for HealthTTL so a down engine costs one probe per
+// interval rather than one per request. Concurrent callers share a running
+// probe; one whose ctx ends stops waiting without affecting it.
+func (c *AIEngineClient) Healthy(ctx context.Context) (*AIEngineHealth, bool) {
+	if c.BaseURL == "" {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.HealthTTL {
+		health := c.health
+		c.mu.Unlock()
+		return health, health != nil
+	}
+	probing := c.probing
+	if probing == nil {
+		probing = make(chan struct{})
+		c.probing = probing
+		go c.probe(probing)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-probing:
+	case <-ctx.Done():
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.health, c.health != nil
+}
+
+// probe checks the engine's health, independently of the request that asked,
+// and caches the result
+func (c *AIEngineClient) probe(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	defer cancel()
+
+	health, err := c.Health(ctx)
+	if err != nil || health.Status != "healthy" {
+		health = nil
+	}
+
+	c.mu.Lock()
+	c.health = health
+	c.checkedAt = time.Now()
+	c.probing = nil
+	c.mu.Unlock()
+	close(done)
+}
+
+// Recommend fetches recommendations, best first
+func (c *AIEngineClient) Recommend(ctx context.Context, req AIRecommendRequest) ([]AIRecommendation, error) {
+	var recommendations []AIRecommendation
+	if err := c.post(ctx, "/recommend", req, true, &recommendations); err != nil {
+		return nil, err
+	}
+	return recommendations, nil
+}
+
+// RecordInteraction reports an interaction so the engine updates its model
+func (c *AIEngineClient) RecordInteraction(ctx context.Context, interaction AIInteraction) error {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	return c.post(ctx, "/interaction", interaction, false, &resp)
+}
+
+// Train asks the engine to retrain; it skips recently trained models unless
+// force is set
+func (c *AIEngineClient) Train(ctx context.Context, force bool) (*AITrainResponse, error) {
+	var resp AITrainResponse
+	body := map[string]bool{"force_retrain": force}
+	if err := c.post(ctx, "/train", body, false, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Explain fetches the factors behind recommending an NFT to the user with the
+// given users.id
+func (c *AIEngineClient) Explain(ctx context.Context, userID, nftID string) (*AIExplanation, error) {
+	var explanation AIExplanation
+	path := "/explain/" + url.PathEscape(userID) + "/" + url.PathEscape(nftID)
+	if err := c.get(ctx, path, nil, &explanation); err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// Trending fetches the engine's trending NFTs
+func (c *AIEngineClient) Trending(ctx context.Context, limit int) ([]AITrendingNFT, error) {
+	var trending []AITrendingNFT
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if err := c.get(ctx, "/trending", query, &trending); err != nil {
+		return nil, err
+	}
+	return trending, nil
+}
+
+// get sends a GET request and decodes the response
+func (c *AIEngineClient) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return c.do(ctx, Request{
+		Method:     http.MethodGet,
+		URL:        target,
+		Idempotent: true,
+	}, out)
+}
+
+// post sends a JSON POST request and decodes the response
+func (c *AIEngineClient) post(ctx context.Context, path string, body interface{}, idempotent bool, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, Request{
+		Method:      http.MethodPost,
+		URL:         c.BaseURL + path,
+		Body:        payload,
+		ContentType: "application/json",
+		Idempotent:  idempotent,
+	}, out)
+}
+
+// do sends an authenticated request through the client
+func (c *AIEngineClient) do(ctx context.Context, req Request, out interface{}) error {
+	req.Header = http.Header{}
+	req.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	respBody, err := c.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
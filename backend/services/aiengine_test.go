@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testUserID is the users.id the engine knows the test user by
+const testUserID = "6f1c2a9e-4b3d-4e8a-9c7f-2d5b8e1a0c34"
+
+func newTestAIEngine(t *testing.T, handler http.HandlerFunc) (*AIEngineClient, *httptest.Server) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := testClientConfig()
+	config.Name = "ai-engine"
+	return &AIEngineClient{
+		BaseURL:   server.URL,
+		APIKey:    "engine-key",
+		HealthTTL: time.Minute,
+		client:    NewResilientClient(config),
+	}, server
+}
+
+func TestAIEngineRecommend(t *testing.T) {
+	engine, _ := newTestAIEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		var req AIRecommendRequest
+		if r.Method != http.MethodPost || r.URL.Path != "/recommend" || r.Header.Get("X-API-Key") != "engine-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID != testUserID || req.Limit != 2 {
+			http.Error(w, "unexpected body", http.StatusUnprocessableEntity)
+			return
+		}
+		fmt.Fprint(w, `[{"nft_id": "n1", "score": 0.9, "reason": "Similar to NFTs you liked", "tags": ["art"]}]`)
+	})
+
+	recs, err := engine.Recommend(context.Background(), AIRecommendRequest{UserID: testUserID, Limit: 2, Strategy: "hybrid"})
+	if err != nil {
+		t.Fatalf("Recommend: %v", err)
+	}
+	if len(recs) != 1 || recs[0].NFTID != "n1" || recs[0].Score != 0.9 || recs[0].Reason == "" {
+		t.Errorf("recommendations = %+v", recs)
+	}
+}
+
+func TestAIEngineExplain(t *testing.T) {
+	engine, _ := newTestAIEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/explain/"+testUserID+"/n1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"user_id": %q, "nft_id": "n1", "explanation": {"factors": [
+			{"type": "user_preference_match", "score": 0.8, "description": "Matches your historical preferences"}
+		]}}`, testUserID)
+	})
+
+	explanation, err := engine.Explain(context.Background(), testUserID, "n1")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if factors := explanation.Explanation.Factors; len(factors) != 1 || factors[0].Type != "user_preference_match" {
+		t.Errorf("factors = %+v", factors)
+	}
+}
+
+func TestAIEngineHealthyCachesProbe(t *testing.T) {
+	var probes atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	engine, _ := newTestAIEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		fmt.Fprint(w, `{"status": "healthy", "model_version": "1.0.0"}`)
+	})
+
+	health, ok := engine.Healthy(context.Background())
+	if !ok || health.ModelVersion != "1.0.0" {
+		t.Fatalf("Healthy = %+v, %v, want model 1.0.0", health, ok)
+	}
+
+	// The outage is only noticed once the cached probe expires
+	status.Store(http.StatusInternalServerError)
+	if _, ok := engine.Healthy(context.Background()); !ok || probes.Load() != 1 {
+		t.Errorf("Healthy reprobed within the TTL: %d probes", probes.Load())
+	}
+
+	engine.checkedAt = time.Now().Add(-engine.HealthTTL)
+	if _, ok := engine.Healthy(context.Background()); ok {
+		t.Error("Healthy = true for an engine answering 500")
+	}
+}
+
+func TestAIEngineHealthyProbeOutlivesRequest(t *testing.T) {
+	var probes atomic.Int32
+	release := make(chan struct{})
+	engine, _ := newTestAIEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		<-release
+		fmt.Fprint(w, `{"status": "healthy", "model_version": "1.0.0"}`)
+	})
+
+	// The request that started the probe goes away while it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for probes.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, ok := engine.Healthy(ctx); ok {
+		t.Fatal("Healthy = true for a cancelled request")
+	}
+
+	// Other callers share the running probe, which holds no lock
+	if !engine.mu.TryLock() {
+		t.Fatal("probe holds the client lock")
+	}
+	engine.mu.Unlock()
+	answered := make(chan bool)
+	go func() {
+		_, ok := engine.Healthy(context.Background())
+		answered <- ok
+	}()
+	close(release)
+	if !<-answered {
+		t.Error("Healthy = false after the shared probe succeeded")
+	}
+
+	// The cancellation didn't cache the engine as down
+	if health, ok := engine.Healthy(context.Background()); !ok || health.ModelVersion != "1.0.0" {
+		t.Errorf("Healthy = %+v, %v, want the probed health", health, ok)
+	}
+	if got := probes.Load(); got != 1 {
+		t.Errorf("engine probed %d times, want 1", got)
+	}
+}
+
+func TestAIEngineHealthyWithoutURL(t *testing.T) {
+	engine := &AIEngineClient{}
+	if _, ok := engine.Healthy(context.Background()); ok {
+		t.Error("Healthy = true without AI_ENGINE_URL")
+	}
+}
+
+func TestAIEngineHealthModel(t *testing.T) {
+	trained := "2026-10-16T12:30:45.123456"
+	tests := []struct {
+		lastTrained *string
+		want        string
+	}{
+		{nil, "ai-1.0.0@untrained"},
+		{&trained, "ai-1.0.0@20261016T123045"},
+	}
+	for _, tt := range tests {
+		model := (&AIEngineHealth{ModelVersion: "1.0.0", LastTrained: tt.lastTrained}).Model()
+		if model.Version != tt.want || model.Source != "ai-engine" {
+			t.Errorf("Model() = %+v, want version %s", model, tt.want)
+		}
+		if (model.TrainedAt != nil) != (tt.lastTrained != nil) {
+			t.Errorf("Model() trained at %v for last_trained %v", model.TrainedAt, tt.lastTrained)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"nftgenie/backend/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	codec := &CursorCodec{Secret: []byte("cursor-test-secret")}
+	cursor := &repository.Cursor{
+		CreatedAt: time.Date(2025, 3, 4, 5, 6, 7, 891234000, time.UTC),
+		ID:        uuid.New(),
+		Before:    true,
+	}
+
+	token := codec.Encode("nfts", cursor)
+	got, err := codec.Decode("nfts", token)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !got.CreatedAt.Equal(cursor.CreatedAt) || got.ID != cursor.ID || !got.Before {
+		t.Errorf("got %+v, want %+v", got, cursor)
+	}
+
+	if codec.Encode("nfts", nil) != "" {
+		t.Error("nil cursor should encode to an empty token")
+	}
+}
+
+func TestCursorRejectsTampering(t *testing.T) {
+	codec := &CursorCodec{Secret: []byte("cursor-test-secret")}
+	token := codec.Encode("nfts", &repository.Cursor{CreatedAt: time.Now(), ID: uuid.New()})
+	payload, signature, _ := strings.Cut(token, ".")
+
+	forged := codec.Encode("nfts", &repository.Cursor{CreatedAt: time.Now(), ID: uuid.New()})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	other := &CursorCodec{Secret: []byte("another-secret")}
+
+	tests := []struct {
+		name  string
+		codec *CursorCodec
+		list  string
+		token string
+	}{
+		{"other list", codec, "users", token},
+		{"swapped payload", codec, "nfts", forgedPayload + "." + signature},
+		{"other secret", other, "nfts", token},
+		{"missing signature", codec, "nfts", payload},
+		{"garbage", codec, "nfts", "not-a-cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.codec.Decode(tt.list, tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
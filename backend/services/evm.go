@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"nftgenie/backend/config"
+	"strings"
+	"time"
+)
+
+// ErrTransactionReverted is returned when a submitted transaction fails on chain
+var ErrTransactionReverted = errors.New("transaction reverted")
+
+// transferEventTopic is keccak256("Transfer(address,address,uint256)")
+var transferEventTopic = "0x" + hex.EncodeToString(keccak256([]byte("Transfer(address,address,uint256)")))
+
+// tokenURIDataPrefix marks token URIs that embed their JSON metadata
+const tokenURIDataPrefix = "data:application/json;base64,"
+
+// RPCError is an error object returned by a JSON-RPC node
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// EVMProvider mints through an ERC-721 contract on an EVM JSON-RPC node such
+// as a local anvil or hardhat. Transactions are sent with eth_sendTransaction,
+// so the From account must be unlocked on the node.
+type EVMProvider struct {
+	ContractAddress string
+	From            string
+	// MintFunction is the contract's mint signature; it must take the
+	// recipient address and a token URI
+	MintFunction   string
+	Chain          string
+	ReceiptTimeout time.Duration
+	PollInterval   time.Duration
+
+	rpc *RPCClient
+}
+
+// NewEVMProvider creates an EVM provider from the EVM_* environment
+func NewEVMProvider() (*EVMProvider, error) {
+	p := &EVMProvider{
+		ContractAddress: config.String("EVM_CONTRACT_ADDRESS", ""),
+		From:            config.String("EVM_FROM_ADDRESS", ""),
+		MintFunction:    config.String("EVM_MINT_FUNCTION", ""),
+		Chain:           config.String("CHAIN", ""),
+		ReceiptTimeout:  config.Duration("EVM_RECEIPT_TIMEOUT", 30*time.Second),
+		PollInterval:    config.Duration("EVM_POLL_INTERVAL", time.Second),
+		rpc: &RPCClient{
+			URL:    config.String("EVM_RPC_URL", "http://127.0.0.1:8545"),
+			client: NewResilientClient(ClientConfigFromEnv("evm", "EVM_RPC")),
+		},
+	}
+	if p.MintFunction == "" {
+		p.MintFunction = "safeMint(address,string)"
+	}
+	if p.Chain == "" {
+		p.Chain = "localhost"
+	}
+
+	if !IsHexAddress(p.ContractAddress) {
+		return nil, fmt.Errorf("EVM_CONTRACT_ADDRESS must be a contract address")
+	}
+	if !IsHexAddress(p.From) {
+		return nil, fmt.Errorf("EVM_FROM_ADDRESS must be an unlocked account address")
+	}
+	return p, nil
+}
+
+// ProviderName implements MintProvider
+func (p *EVMProvider) ProviderName() string {
+	return ProviderEVM
+}
+
+// ChainName implements MintProvider
+func (p *EVMProvider) ChainName() string {
+	return p.Chain
+}
+
+// Mint implements MintProvider. It waits for the receipt to read the token ID
+// from the contract's Transfer event.
+func (p *EVMProvider) Mint(ctx context.Context, req MintNFTRequest) (*MintResult, error) {
+	if !IsHexAddress(req.RecipientAddress) {
+		return nil, fmt.Errorf("invalid recipient address %q", req.RecipientAddress)
+	}
+
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"name":        req.Name,
+		"description": req.Description,
+		"image":       req.ImageURL,
+	})
+	uri := tokenURIDataPrefix + base64.StdEncoding.EncodeToString(metadata)
+
+	data := abiSelector(p.MintFunction) +
+		abiAddress(req.RecipientAddress) +
+		abiUint(big.NewInt(64)) +
+		abiString(uri)
+
+	txHash, err := p.sendTransaction(ctx, p.From, data)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := p.waitForReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenID, err := p.mintedTokenID(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MintResult{
+		TransactionHash: txHash,
+		ContractAddress: p.ContractAddress,
+		TokenID:         tokenID,
+	}, nil
+}
+
+// Transfer implements MintProvider with transferFrom; the sender must be
+// unlocked on the node. It returns once the transaction is submitted.
+func (p *EVMProvider) Transfer(ctx context.Context, req TransferRequest) (*TransferResult, error) {
+	tokenID, ok := new(big.Int).SetString(req.TokenID, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid token ID %q", req.TokenID)
+	}
+	if !IsHexAddress(req.FromAddress) || !IsHexAddress(req.ToAddress) {
+		return nil, fmt.Errorf("invalid transfer addresses")
+	}
+
+	data := abiSelector("transferFrom(address,address,uint256)") +
+		abiAddress(req.FromAddress) +
+		abiAddress(req.ToAddress) +
+		abiUint(tokenID)
+
+	txHash, err := p.sendTransactionTo(ctx, req.FromAddress, req.ContractAddress, data)
+	if err != nil {
+		return nil, err
+	}
+	return &TransferResult{TransactionHash: txHash}, nil
+}
+
+// Metadata implements MintProvider using ownerOf and tokenURI
+func (p *EVMProvider) Metadata(ctx context.Context, contractAddress, tokenID string) (map[string]interface{}, error) {
+	id, ok := new(big.Int).SetString(tokenID, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid token ID %q", tokenID)
+	}
+
+	ownerWord, err := p.call(ctx, contractAddress, abiSelector("ownerOf(uint256)")+abiUint(id))
+	if err != nil {
+		return nil, err
+	}
+	owner, err := decodeABIAddress(ownerWord)
+	if err != nil {
+		return nil, err
+	}
+
+	uriData, err := p.call(ctx, contractAddress, abiSelector("tokenURI(uint256)")+abiUint(id))
+	if err != nil {
+		return nil, err
+	}
+	uri, err := decodeABIString(uriData)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"contract_address": contractAddress,
+		"token_id":         tokenID,
+		"owner":            owner,
+		"token_uri":        uri,
+		"chain":            p.Chain,
+	}
+	if strings.HasPrefix(uri, tokenURIDataPrefix) {
+		var metadata map[string]interface{}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, tokenURIDataPrefix))
+		if err == nil && json.Unmarshal(raw, &metadata) == nil {
+			result["metadata"] = metadata
+		}
+	}
+	return result, nil
+}
+
+// WalletNFTs implements MintProvider by scanning the contract's Transfer
+// events to the wallet and keeping the tokens it still owns
+func (p *EVMProvider) WalletNFTs(ctx context.Context, walletAddress string) ([]map[string]interface{}, error) {
+	if !IsHexAddress(walletAddress) {
+		return nil, fmt.Errorf("invalid wallet address %q", walletAddress)
+	}
+
+	var logs []evmLog
+	filter := map[string]interface{}{
+		"fromBlock": "0x0",
+		"toBlock":   "latest",
+		"address":   p.ContractAddress,
+		"topics":    []interface{}{transferEventTopic, nil, "0x" + abiAddress(walletAddress)},
+	}
+	if err := p.rpc.Call(ctx, "eth_getLogs", &logs, filter); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	nfts := []map[string]interface{}{}
+	for _, l := range logs {
+		if len(l.Topics) != 4 {
+			continue
+		}
+		tokenID, err := decodeABIUint(l.Topics[3])
+		if err != nil || seen[tokenID] {
+			continue
+		}
+		seen[tokenID] = true
+
+		nft, err := p.Metadata(ctx, p.ContractAddress, tokenID)
+		if err != nil {
+			return nil, err
+		}
+		if owner, _ := nft["owner"].(string); strings.EqualFold(owner, walletAddress) {
+			nfts = append(nfts, nft)
+		}
+	}
+	return nfts, nil
+}
+
+// TransactionReceipt implements ReceiptSource through the provider's node
+func (p *EVMProvider) TransactionReceipt(ctx context.Context, txHash string) (*Receipt, error) {
+	return p.rpc.TransactionReceipt(ctx, txHash)
+}
+
+// evmLog is an event log in a receipt or eth_getLogs result
+type evmLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+}
+
+// evmReceipt is the subset of a transaction receipt the provider reads
+type evmReceipt struct {
+	Status string   `json:"status"`
+	Logs   []evmLog `json:"logs"`
+}
+
+// sendTransaction sends a call to the provider's contract
+func (p *EVMProvider) sendTransaction(ctx context.Context, from, data string) (string, error) {
+	return p.sendTransactionTo(ctx, from, p.ContractAddress, data)
+}
+
+// sendTransactionTo sends a transaction from an unlocked account
+func (p *EVMProvider) sendTransactionTo(ctx context.Context, from, to, data string) (string, error) {
+	var txHash string
+	err := p.rpc.Call(ctx, "eth_sendTransaction", &txHash, map[string]string{
+		"from": from,
+		"to":   to,
+		"data": "0x" + data,
+	})
+	return txHash, err
+}
+
+// call runs eth_call against the latest block and returns the hex result
+func (p *EVMProvider) call(ctx context.Context, to, data string) (string, error) {
+	var result string
+	err := p.rpc.Call(ctx, "eth_call", &result, map[string]string{
+		"to":   to,
+		"data": "0x" + data,
+	}, "latest")
+	return result, err
+}
+
+// waitForReceipt polls for a transaction receipt until ReceiptTimeout
+func (p *EVMProvider) waitForReceipt(ctx context.Context, txHash string) (*evmReceipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.ReceiptTimeout)
+	defer cancel()
+
+	for {
+		var receipt *evmReceipt
+		if err := p.rpc.Call(ctx, "eth_getTransactionReceipt", &receipt, txHash); err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			if receipt.Status != "0x1" {
+				return nil, fmt.Errorf("%w: %s", ErrTransactionReverted, txHash)
+			}
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for receipt of %s: %w", txHash, ctx.Err())
+		case <-time.After(p.PollInterval):
+		}
+	}
+}
+
+// mintedTokenID finds the token ID in the contract's Transfer event from the
+// zero address
+func (p *EVMProvider) mintedTokenID(receipt *evmReceipt) (string, error) {
+	zero := "0x" + strings.Repeat("0", 64)
+	for _, l := range receipt.Logs {
+		if !strings.EqualFold(l.Address, p.ContractAddress) || len(l.Topics) != 4 {
+			continue
+		}
+		if strings.EqualFold(l.Topics[0], transferEventTopic) && l.Topics[1] == zero {
+			return decodeABIUint(l.Topics[3])
+		}
+	}
+	return "", fmt.Errorf("mint receipt has no Transfer event")
+}
+
+// abiSelector returns the 4 byte function selector as hex
+func abiSelector(signature string) string {
+	return hex.EncodeToString(keccak256([]byte(signature))[:4])
+}
+
+// abiAddress encodes an address as a 32 byte word
+func abiAddress(address string) string {
+	return strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// abiUint encodes an unsigned integer as a 32 byte word
+func abiUint(n *big.Int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+// abiString encodes the tail of a dynamic string argument
+func abiString(s string) string {
+	data := hex.EncodeToString([]byte(s))
+	if pad := len(data) % 64; pad != 0 {
+		data += strings.Repeat("0", 64-pad)
+	}
+	return abiUint(big.NewInt(int64(len(s)))) + data
+}
+
+// decodeABIUint decodes a 32 byte word as a decimal string
+func decodeABIUint(word string) (string, error) {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(word, "0x"), 16)
+	if !ok {
+		return "", fmt.Errorf("invalid uint256 %q", word)
+	}
+	return n.String(), nil
+}
+
+// decodeABIAddress decodes an address returned by eth_call
+func decodeABIAddress(data string) (string, error) {
+	data = strings.TrimPrefix(data, "0x")
+	if len(data) != 64 {
+		return "", fmt.Errorf("invalid address result %q", data)
+	}
+	return "0x" + data[24:], nil
+}
+
+// decodeABIString decodes a single string returned by eth_call
+func decodeABIString(data string) (string, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil || len(raw) < 64 {
+		return "", fmt.Errorf("invalid string result")
+	}
+
+	offset := new(big.Int).SetBytes(raw[:32])
+	if !offset.IsInt64() || offset.Int64()+32 > int64(len(raw)) {
+		return "", fmt.Errorf("invalid string offset")
+	}
+	start := offset.Int64() + 32
+	length := new(big.Int).SetBytes(raw[start-32 : start])
+	if !length.IsInt64() || start+length.Int64() > int64(len(raw)) {
+		return "", fmt.Errorf("invalid string length")
+	}
+	return string(raw[start : start+length.Int64()]), nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"nftgenie/backend/config"
+	"nftgenie/backend/models"
+	"nftgenie/backend/repository"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Feature names
+const (
+	FeatureMinting           = "minting"
+	FeatureMarketplace       = "marketplace"
+	FeatureAIRecommendations = "ai_recommendations"
+)
+
+// featureEnv maps each feature to the environment variable holding its
+// default
+var featureEnv = map[string]string{
+	FeatureMinting:           "ENABLE_MINTING",
+	FeatureMarketplace:       "ENABLE_MARKETPLACE",
+	FeatureAIRecommendations: "ENABLE_AI_RECOMMENDATIONS",
+}
+
+// ErrUnknownFeature is returned for a flag name that is not a feature
+var ErrUnknownFeature = errors.New("unknown feature")
+
+// FeatureFlagStore persists the runtime overrides; the database one is
+// repository.FeatureFlagRepository
+type FeatureFlagStore interface {
+	GetAll() ([]*models.FeatureFlag, error)
+	Upsert(flag *models.FeatureFlag) error
+	Delete(name string) (bool, error)
+}
+
+// FeatureFlags decides whether features are enabled. Each feature defaults
+// to its ENABLE_* variable (enabled when unset); admins override defaults at
+// runtime through the feature_flags table. Overrides are reloaded every
+// RefreshInterval, so a toggle on one backend instance reaches the others
+// without a restart.
+type FeatureFlags struct {
+	RefreshInterval time.Duration
+
+	defaults map[string]bool
+	flags    FeatureFlagStore
+
+	mu          sync.Mutex
+	overrides   map[string]*models.FeatureFlag
+	refreshedAt time.Time
+	loading     chan struct{} // closed when the running load finishes
+	loaded      bool          // whether a load has finished, even if it failed
+	writes      int           // counts Set and Reset calls
+}
+
+// NewFeatureFlags creates feature flags with defaults from the ENABLE_*
+// environment and overrides from the database
+func NewFeatureFlags() *FeatureFlags {
+	return NewFeatureFlagsWithStore(repository.NewFeatureFlagRepository())
+}
+
+// NewFeatureFlagsWithStore creates feature flags with defaults from the
+// ENABLE_* environment and overrides from store
+func NewFeatureFlagsWithStore(store FeatureFlagStore) *FeatureFlags {
+	defaults := make(map[string]bool, len(featureEnv))
+	for feature, key := range featureEnv {
+		defaults[feature] = config.Bool(key, true)
+	}
+
+	return &FeatureFlags{
+		RefreshInterval: config.Duration("FEATURE_FLAGS_REFRESH_INTERVAL", 30*time.Second),
+		defaults:        defaults,
+		flags:           store,
+		overrides:       make(map[string]*models.FeatureFlag),
+	}
+}
+
+// Enabled reports whether a feature is on for the wallet, which is empty
+// for anonymous requests
+func (f *FeatureFlags) Enabled(feature, wallet string) bool {
+	flag := f.Get(feature)
+	if flag == nil {
+		return false
+	}
+	if flag.Enabled {
+		return true
+	}
+	for _, allowed := range flag.AllowedWallets {
+		if wallet != "" && strings.EqualFold(allowed, wallet) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a feature's effective flag, or nil for an unknown feature
+func (f *FeatureFlags) Get(feature string) *models.FeatureFlag {
+	enabled, ok := f.defaults[feature]
+	if !ok {
+		return nil
+	}
+
+	f.refresh()
+
+	f.mu.Lock()
+	flag, ok := f.overrides[feature]
+	f.mu.Unlock()
+	if ok {
+		return flag
+	}
+	return &models.FeatureFlag{
+		Name:           feature,
+		Enabled:        enabled,
+		AllowedWallets: pq.StringArray{},
+		Source:         models.FeatureSourceEnv,
+	}
+}
+
+// All returns every feature's effective flag, ordered by name
+func (f *FeatureFlags) All() []*models.FeatureFlag {
+	names := make([]string, 0, len(f.defaults))
+	for feature := range f.defaults {
+		names = append(names, feature)
+	}
+	sort.Strings(names)
+
+	flags := make([]*models.FeatureFlag, len(names))
+	for i, feature := range names {
+		flags[i] = f.Get(feature)
+	}
+	return flags
+}
+
+// Set overrides a feature's flag. Allowed wallets are stored lowercased.
+func (f *FeatureFlags) Set(flag *models.FeatureFlag) error {
+	if _, ok := f.defaults[flag.Name]; !ok {
+		return ErrUnknownFeature
+	}
+
+	wallets := pq.StringArray{}
+	seen := make(map[string]bool, len(flag.AllowedWallets))
+	for _, wallet := range flag.AllowedWallets {
+		wallet = strings.ToLower(strings.TrimSpace(wallet))
+		if wallet != "" && !seen[wallet] {
+			seen[wallet] = true
+			wallets = append(wallets, wallet)
+		}
+	}
+	flag.AllowedWallets = wallets
+
+	if err := f.flags.Upsert(flag); err != nil {
+		return err
+	}
+	flag.Source = models.FeatureSourceOverride
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.overrides[flag.Name] = flag
+	f.writes++
+	return nil
+}
+
+// Reset drops a feature's override, restoring its ENABLE_* default
+func (f *FeatureFlags) Reset(feature string) error {
+	if _, ok := f.defaults[feature]; !ok {
+		return ErrUnknownFeature
+	}
+	if _, err := f.flags.Delete(feature); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.overrides, feature)
+	f.writes++
+	return nil
+}
+
+// refresh reloads the overrides once RefreshInterval has passed. The store
+// is queried without holding mu, and only by one caller at a time: the others
+// keep the previous overrides meanwhile, waiting only for the very first
+// load. When a load fails the previous overrides stay in force until the
+// next interval.
+func (f *FeatureFlags) refresh() {
+	f.mu.Lock()
+	if loading := f.loading; loading != nil {
+		loaded := f.loaded
+		f.mu.Unlock()
+		if !loaded {
+			<-loading
+		}
+		return
+	}
+	if !f.refreshedAt.IsZero() && time.Since(f.refreshedAt) < f.RefreshInterval {
+		f.mu.Unlock()
+		return
+	}
+	loading := make(chan struct{})
+	f.loading = loading
+	f.refreshedAt = time.Now()
+	writes := f.writes
+	f.mu.Unlock()
+
+	flags, err := f.flags.GetAll()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer close(loading)
+	f.loading = nil
+	f.loaded = true
+
+	if err != nil {
+		log.Printf("feature flags: loading overrides: %v", err)
+		return
+	}
+	if f.writes != writes {
+		// A Set or Reset on this instance may be missing from what was
+		// read, so keep its result and load again on the next call
+		f.refreshedAt = time.Time{}
+		return
+	}
+	overrides := make(map[string]*models.FeatureFlag, len(flags))
+	for _, flag := range flags {
+		flag.Source = models.FeatureSourceOverride
+		overrides[flag.Name] = flag
+	}
+	f.overrides = overrides
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"nftgenie/backend/models"
+
+	"github.com/lib/pq"
+)
+
+// memoryFlagStore is a FeatureFlagStore shared by the FeatureFlags of
+// several "instances". While block is set, GetAll waits for it to close.
+type memoryFlagStore struct {
+	mu    sync.Mutex
+	flags map[string]models.FeatureFlag
+	err   error
+	loads int
+	block chan struct{}
+}
+
+func newMemoryFlagStore() *memoryFlagStore {
+	return &memoryFlagStore{flags: make(map[string]models.FeatureFlag)}
+}
+
+func (s *memoryFlagStore) GetAll() ([]*models.FeatureFlag, error) {
+	s.mu.Lock()
+	block := s.block
+	s.loads++
+	s.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	flags := make([]*models.FeatureFlag, 0, len(s.flags))
+	for _, flag := range s.flags {
+		flag := flag
+		flags = append(flags, &flag)
+	}
+	return flags, nil
+}
+
+func (s *memoryFlagStore) Upsert(flag *models.FeatureFlag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[flag.Name] = *flag
+	return nil
+}
+
+func (s *memoryFlagStore) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.flags[name]
+	delete(s.flags, name)
+	return ok, nil
+}
+
+func (s *memoryFlagStore) loadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loads
+}
+
+func TestFeatureFlagDefaults(t *testing.T) {
+	t.Setenv("ENABLE_MINTING", "false")
+	t.Setenv("ENABLE_MARKETPLACE", "not-a-bool")
+	t.Setenv("ENABLE_AI_RECOMMENDATIONS", "")
+	flags := NewFeatureFlagsWithStore(newMemoryFlagStore())
+
+	want := map[string]bool{FeatureMinting: false, FeatureMarketplace: true, FeatureAIRecommendations: true}
+	for feature, enabled := range want {
+		flag := flags.Get(feature)
+		if flag == nil || flag.Enabled != enabled || flag.Source != models.FeatureSourceEnv {
+			t.Errorf("%s = %+v, want enabled %v from env", feature, flag, enabled)
+		}
+	}
+
+	all := flags.All()
+	if len(all) != 3 || all[0].Name != FeatureAIRecommendations || all[2].Name != FeatureMinting {
+		t.Errorf("All() = %+v, want the three features by name", all)
+	}
+
+	if flags.Get("teleport") != nil || flags.Enabled("teleport", "") {
+		t.Error("unknown feature has a flag")
+	}
+	if err := flags.Set(&models.FeatureFlag{Name: "teleport"}); !errors.Is(err, ErrUnknownFeature) {
+		t.Errorf("Set(teleport) = %v, want ErrUnknownFeature", err)
+	}
+	if err := flags.Reset("teleport"); !errors.Is(err, ErrUnknownFeature) {
+		t.Errorf("Reset(teleport) = %v, want ErrUnknownFeature", err)
+	}
+}
+
+func TestFeatureFlagOverrides(t *testing.T) {
+	store := newMemoryFlagStore()
+	flags := NewFeatureFlagsWithStore(store)
+
+	err := flags.Set(&models.FeatureFlag{
+		Name:           FeatureMinting,
+		AllowedWallets: pq.StringArray{" 0xBETA ", "0xbeta", ""},
+	})
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	flag := flags.Get(FeatureMinting)
+	if flag.Enabled || flag.Source != models.FeatureSourceOverride || len(flag.AllowedWallets) != 1 || flag.AllowedWallets[0] != "0xbeta" {
+		t.Errorf("override = %+v, want disabled except for 0xbeta", flag)
+	}
+
+	// Allowed wallets match in any case; anonymous requests never do
+	for wallet, want := range map[string]bool{"0xBeta": true, "0xother": false, "": false} {
+		if got := flags.Enabled(FeatureMinting, wallet); got != want {
+			t.Errorf("Enabled for %q = %v, want %v", wallet, got, want)
+		}
+	}
+
+	if err := flags.Reset(FeatureMinting); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if !flags.Enabled(FeatureMinting, "") {
+		t.Error("minting still disabled after Reset")
+	}
+}
+
+func TestFeatureFlagRefresh(t *testing.T) {
+	store := newMemoryFlagStore()
+	flags := NewFeatureFlagsWithStore(store)
+	flags.RefreshInterval = time.Hour
+	other := NewFeatureFlagsWithStore(store)
+	other.RefreshInterval = 20 * time.Millisecond
+
+	if !other.Enabled(FeatureMarketplace, "") {
+		t.Fatal("marketplace disabled by default")
+	}
+	if err := flags.Set(&models.FeatureFlag{Name: FeatureMarketplace}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// The other instance sees the override once its interval passes
+	if !other.Enabled(FeatureMarketplace, "") {
+		t.Error("override reached the other instance before its refresh")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if other.Enabled(FeatureMarketplace, "") {
+		t.Error("override missing after the other instance refreshed")
+	}
+
+	// A failed load keeps the overrides it had
+	store.mu.Lock()
+	store.err = errors.New("database down")
+	store.mu.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	if other.Enabled(FeatureMarketplace, "") {
+		t.Error("override dropped after a failed load")
+	}
+	loads := store.loadCount()
+	other.Enabled(FeatureMarketplace, "")
+	if store.loadCount() != loads {
+		t.Error("failed load retried before the next interval")
+	}
+}
+
+func TestFeatureFlagRefreshDoesNotBlockReaders(t *testing.T) {
+	store := newMemoryFlagStore()
+	flags := NewFeatureFlagsWithStore(store)
+	flags.RefreshInterval = time.Millisecond
+	if !flags.Enabled(FeatureMinting, "") {
+		t.Fatal("minting disabled by default")
+	}
+
+	block := make(chan struct{})
+	store.mu.Lock()
+	store.block = block
+	store.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+
+	// One reader runs the slow load...
+	done := make(chan struct{})
+	go func() {
+		flags.Enabled(FeatureMinting, "")
+		close(done)
+	}()
+	for store.loadCount() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// ...while the others answer from the previous overrides without
+	// loading again
+	answered := make(chan bool)
+	go func() { answered <- flags.Enabled(FeatureMinting, "") }()
+	select {
+	case enabled := <-answered:
+		if !enabled {
+			t.Error("minting disabled during the load")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Enabled blocked on the running load")
+	}
+	if err := flags.Set(&models.FeatureFlag{Name: FeatureMinting}); err != nil {
+		t.Fatalf("Set during the load: %v", err)
+	}
+	if got := store.loadCount(); got != 2 {
+		t.Errorf("store loaded %d times, want 2", got)
+	}
+
+	// The load predates the Set, so its result does not undo it
+	close(block)
+	<-done
+	store.mu.Lock()
+	store.block = nil
+	store.mu.Unlock()
+	if flags.Enabled(FeatureMinting, "") {
+		t.Error("a load started before Set overwrote it")
+	}
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"nftgenie/backend/config"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is matched by CircuitOpenError via errors.Is
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned without calling the upstream API while the
+// circuit breaker is open after repeated failures
+type CircuitOpenError struct {
+	Service string
+	Until   time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s unavailable: circuit breaker open until %s", e.Service, e.Until.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) true
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// APIError is returned when the upstream API answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, apiErrorMessage(e.Body))
+}
+
+// ClientConfig tunes timeouts, retries, rate limiting and circuit breaking
+type ClientConfig struct {
+	Name             string
+	Timeout          time.Duration // deadline for each attempt
+	MaxRetries       int
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	MaxRetryAfter    time.Duration // cap on an honored Retry-After
+	RateLimit        float64       // requests per second, 0 disables
+	Burst            int
+	FailureThreshold int // consecutive failed calls before opening
+	Cooldown         time.Duration
+}
+
+// ClientConfigFromEnv reads a client config using the given env prefix,
+// e.g. VERBWIRE_TIMEOUT or VERBWIRE_MAX_RETRIES
+func ClientConfigFromEnv(name, prefix string) ClientConfig {
+	return ClientConfig{
+		Name:             name,
+		Timeout:          config.Duration(prefix+"_TIMEOUT", 30*time.Second),
+		MaxRetries:       config.NonNegativeInt(prefix+"_MAX_RETRIES", 3),
+		BaseBackoff:      config.Duration(prefix+"_BASE_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:       config.Duration(prefix+"_MAX_BACKOFF", 10*time.Second),
+		MaxRetryAfter:    config.Duration(prefix+"_MAX_RETRY_AFTER", 30*time.Second),
+		RateLimit:        config.NonNegativeFloat(prefix+"_RATE_LIMIT", 5),
+		Burst:            config.NonNegativeInt(prefix+"_RATE_BURST", 10),
+		FailureThreshold: config.NonNegativeInt(prefix+"_BREAKER_THRESHOLD", 5),
+		Cooldown:         config.Duration(prefix+"_BREAKER_COOLDOWN", 30*time.Second),
+	}
+}
+
+// ResilientClient is an HTTP client shared by the upstream API services. It
+// applies per-attempt deadlines, retries with backoff, a client-side token
+// bucket and a circuit breaker.
+type ResilientClient struct {
+	config  ClientConfig
+	http    *http.Client
+	limiter *tokenBucket
+	breaker *circuitBreaker
+}
+
+// NewResilientClient creates a client with the given config
+func NewResilientClient(config ClientConfig) *ResilientClient {
+	return &ResilientClient{
+		config:  config,
+		http:    &http.Client{},
+		limiter: newTokenBucket(config.RateLimit, config.Burst),
+		breaker: &circuitBreaker{threshold: config.FailureThreshold, cooldown: config.Cooldown},
+	}
+}
+
+// Request describes one upstream call; the body is replayed on retries
+type Request struct {
+	Method      string
+	URL         string
+	Header      http.Header
+	Body        []byte
+	ContentType string
+	// Idempotent requests are retried on any 5xx; others only when the
+	// upstream certainly did not process them (429 and 503)
+	Idempotent bool
+}
+
+// Do sends the request and returns the body of a 2xx response
+func (c *ResilientClient) Do(ctx context.Context, req Request) ([]byte, error) {
+	if until, open := c.breaker.allow(); !open {
+		return nil, &CircuitOpenError{Service: c.config.Name, Until: until}
+	}
+
+	body, err := c.doWithRetries(ctx, req)
+	if errors.Is(err, context.Canceled) {
+		// The caller gave up, which says nothing about the upstream
+		c.breaker.release()
+	} else {
+		c.breaker.record(err == nil || !countsAsFailure(err))
+	}
+	return body, err
+}
+
+func (c *ResilientClient) doWithRetries(ctx context.Context, req Request) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		body, retryAfter, err := c.attempt(ctx, req)
+		if err == nil {
+			return body, nil
+		}
+		if attempt >= c.config.MaxRetries || !c.retryable(req, err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > 0 {
+			wait = retryAfter
+			if wait > c.config.MaxRetryAfter {
+				return nil, err
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt performs a single request under the per-attempt deadline
+func (c *ResilientClient) attempt(ctx context.Context, req Request) ([]byte, time.Duration, error) {
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range req.Header {
+		httpReq.Header[k] = v
+	}
+	if req.ContentType != "" {
+		httpReq.Header.Set("Content-Type", req.ContentType)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       respBody,
+		}
+	}
+	return respBody, 0, nil
+}
+
+// retryable reports whether a failed attempt may be retried
+func (c *ResilientClient) retryable(req Request, err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// Transport errors and attempt timeouts; a POST may have reached
+		// the upstream, so only idempotent requests are retried
+		return req.Idempotent
+	}
+
+	if IsTemporary(err) {
+		return true
+	}
+	return apiErr.StatusCode >= 500 && req.Idempotent
+}
+
+// backoff returns an exponential backoff with full jitter
+func (c *ResilientClient) backoff(attempt int) time.Duration {
+	max := float64(c.config.BaseBackoff) * math.Pow(2, float64(attempt))
+	if max > float64(c.config.MaxBackoff) {
+		max = float64(c.config.MaxBackoff)
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// IsTemporary reports whether err means the upstream did not process the
+// request, so it is safe to send it again later
+func IsTemporary(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+// countsAsFailure reports whether an error means the upstream is unhealthy;
+// client errors such as 400 or 401 do not trip the breaker
+func countsAsFailure(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or HTTP-date form
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// tokenBucket is a client-side rate limiter
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a token is available or ctx is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// circuitBreaker opens after consecutive failures and lets a single probe
+// through once the cooldown has passed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a call may proceed, or when the breaker closes
+func (b *circuitBreaker) allow() (time.Time, bool) {
+	if b.threshold <= 0 {
+		return time.Time{}, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return time.Time{}, true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return b.openUntil, false
+	}
+	b.probing = true
+	return time.Time{}, true
+}
+
+// record updates the breaker with the outcome of a call
+func (b *circuitBreaker) record(success bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// release ends a call without recording an outcome, so an abandoned probe
+// lets the next one through
+func (b *circuitBreaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// apiErrorMessage extracts the "message" field from an error body
+func apiErrorMessage(body []byte) string {
+	var parsed struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Message != "" {
+		return parsed.Message
+	}
+	if len(body) > 200 {
+		return string(body[:200])
+	}
+	return string(body)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"nftgenie/backend/config"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// Token types carried in the "typ" claim
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+var (
+	// ErrInvalidToken is returned for malformed, expired or mistyped tokens
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrWeakJWTSecret is returned when JWT_SECRET is unset or too short
+	ErrWeakJWTSecret = errors.New("JWT_SECRET must be at least 32 characters")
+)
+
+// WalletClaims are the claims of an access or refresh token
+type WalletClaims struct {
+	Wallet string `json:"wallet"`
+	Type   string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned to the client after a successful login
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// TokenService issues and verifies HMAC-signed session tokens
+type TokenService struct {
+	Secret        []byte
+	AccessExpiry  time.Duration
+	RefreshExpiry time.Duration
+	Issuer        string
+}
+
+// NewTokenService creates a new token service from the environment
+func NewTokenService() *TokenService {
+	return &TokenService{
+		Secret:        []byte(config.String("JWT_SECRET", "")),
+		AccessExpiry:  config.Duration("JWT_EXPIRY", 24*time.Hour),
+		RefreshExpiry: config.Duration("JWT_REFRESH_EXPIRY", 30*24*time.Hour),
+		Issuer:        "nftgenie",
+	}
+}
+
+// Validate checks that the signing secret is long enough; call it at startup
+// so a bad JWT_SECRET stops the server instead of failing every login
+func (s *TokenService) Validate() error {
+	if len(s.Secret) < 32 {
+		return ErrWeakJWTSecret
+	}
+	return nil
+}
+
+// IssueTokens issues an access and refresh token for a wallet
+func (s *TokenService) IssueTokens(userID uuid.UUID, wallet string) (*TokenPair, error) {
+	access, err := s.sign(userID, wallet, AccessToken, s.AccessExpiry)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.sign(userID, wallet, RefreshToken, s.RefreshExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.AccessExpiry.Seconds()),
+	}, nil
+}
+
+// Parse verifies a token of the expected type and returns its claims
+func (s *TokenService) Parse(tokenString, tokenType string) (*WalletClaims, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	claims := &WalletClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return s.Secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if claims.Type != tokenType || !claims.VerifyIssuer(s.Issuer, true) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// sign creates a signed token of the given type
+func (s *TokenService) sign(userID uuid.UUID, wallet, tokenType string, expiry time.Duration) (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := WalletClaims{
+		Wallet: wallet,
+		Type:   tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			Issuer:    s.Issuer,
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.Secret)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func newTestTokenService() *TokenService {
+	return &TokenService{
+		Secret:        []byte("jwt-test-secret-with-at-least-32-chars"),
+		AccessExpiry:  time.Hour,
+		RefreshExpiry: 24 * time.Hour,
+		Issuer:        "nftgenie",
+	}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	tokens := newTestTokenService()
+	userID := uuid.New()
+
+	pair, err := tokens.IssueTokens(userID, "0xabc")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != 3600 {
+		t.Errorf("pair = %+v", pair)
+	}
+
+	claims, err := tokens.Parse(pair.AccessToken, AccessToken)
+	if err != nil {
+		t.Fatalf("Parse access: %v", err)
+	}
+	if claims.Wallet != "0xabc" || claims.Subject != userID.String() || claims.Issuer != "nftgenie" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != time.Hour {
+		t.Errorf("access token lives %v, want 1h", got)
+	}
+
+	claims, err = tokens.Parse(pair.RefreshToken, RefreshToken)
+	if err != nil {
+		t.Fatalf("Parse refresh: %v", err)
+	}
+	if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != 24*time.Hour {
+		t.Errorf("refresh token lives %v, want 24h", got)
+	}
+}
+
+func TestTokenParseRejects(t *testing.T) {
+	tokens := newTestTokenService()
+	userID := uuid.New()
+	pair, err := tokens.IssueTokens(userID, "0xabc")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	expired := newTestTokenService()
+	expired.AccessExpiry = -time.Minute
+	expiredPair, err := expired.IssueTokens(userID, "0xabc")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	otherIssuer := newTestTokenService()
+	otherIssuer.Issuer = "someone-else"
+	otherIssuerPair, err := otherIssuer.IssueTokens(userID, "0xabc")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	otherSecret := newTestTokenService()
+	otherSecret.Secret = []byte("another-secret-that-is-32-chars-long")
+	otherSecretPair, err := otherSecret.IssueTokens(userID, "0xabc")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, WalletClaims{
+		Wallet: "0xabc",
+		Type:   AccessToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "nftgenie",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign none: %v", err)
+	}
+
+	header, rest, _ := strings.Cut(pair.AccessToken, ".")
+	_, signature, _ := strings.Cut(rest, ".")
+	_, refreshRest, _ := strings.Cut(pair.RefreshToken, ".")
+	refreshPayload, _, _ := strings.Cut(refreshRest, ".")
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType string
+	}{
+		{"refresh token used as access", pair.RefreshToken, AccessToken},
+		{"access token used as refresh", pair.AccessToken, RefreshToken},
+		{"expired", expiredPair.AccessToken, AccessToken},
+		{"other issuer", otherIssuerPair.AccessToken, AccessToken},
+		{"other secret", otherSecretPair.AccessToken, AccessToken},
+		{"alg none", unsigned, AccessToken},
+		{"swapped payload", header + "." + refreshPayload + "." + signature, AccessToken},
+		{"garbage", "not-a-token", AccessToken},
+		{"empty", "", AccessToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tokens.Parse(tt.token, tt.tokenType); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestTokenServiceRequiresLongSecret(t *testing.T) {
+	tokens := newTestTokenService()
+	pair, err := tokens.IssueTokens(uuid.New(), "0xabc")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	if err := tokens.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	for _, secret := range []string{"", "short", strings.Repeat("x", 31)} {
+		weak := newTestTokenService()
+		weak.Secret = []byte(secret)
+		if err := weak.Validate(); !errors.Is(err, ErrWeakJWTSecret) {
+			t.Errorf("Validate with %d chars = %v, want ErrWeakJWTSecret", len(secret), err)
+		}
+		if _, err := weak.IssueTokens(uuid.New(), "0xabc"); !errors.Is(err, ErrWeakJWTSecret) {
+			t.Errorf("IssueTokens with %d chars = %v, want ErrWeakJWTSecret", len(secret), err)
+		}
+		if _, err := weak.Parse(pair.AccessToken, AccessToken); !errors.Is(err, ErrWeakJWTSecret) {
+			t.Errorf("Parse with %d chars = %v, want ErrWeakJWTSecret", len(secret), err)
+		}
+	}
+
+	t.Setenv("JWT_SECRET", "short")
+	if err := NewTokenService().Validate(); !errors.Is(err, ErrWeakJWTSecret) {
+		t.Errorf("Validate with a short JWT_SECRET = %v, want ErrWeakJWTSecret", err)
+	}
+}
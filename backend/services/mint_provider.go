@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"nftgenie/backend/config"
+	"strings"
+)
+
+// Mint provider names accepted in MINT_PROVIDER
+const (
+	ProviderVerbwire = "verbwire"
+	ProviderEVM      = "evm"
+	ProviderDryRun   = "dryrun"
+)
+
+// MintProvider mints and transfers NFTs and reads on-chain NFT data
+type MintProvider interface {
+	// ProviderName returns the provider's MINT_PROVIDER name
+	ProviderName() string
+	// ChainName returns the chain name stored on minted NFTs
+	ChainName() string
+	Mint(ctx context.Context, req MintNFTRequest) (*MintResult, error)
+	Transfer(ctx context.Context, req TransferRequest) (*TransferResult, error)
+	Metadata(ctx context.Context, contractAddress, tokenID string) (map[string]interface{}, error)
+	WalletNFTs(ctx context.Context, walletAddress string) ([]map[string]interface{}, error)
+}
+
+// MintResult is the outcome of a mint submitted by a provider
+type MintResult struct {
+	TransactionHash string `json:"transaction_hash"`
+	ContractAddress string `json:"contract_address"`
+	TokenID         string `json:"token_id"`
+	OpenseaURL      string `json:"opensea_url"`
+}
+
+// TransferRequest represents the request to transfer an NFT
+type TransferRequest struct {
+	ContractAddress string `json:"contract_address"`
+	TokenID         string `json:"token_id"`
+	FromAddress     string `json:"from_address"`
+	ToAddress       string `json:"to_address"`
+}
+
+// TransferResult is the outcome of a transfer submitted by a provider
+type TransferResult struct {
+	TransactionHash string `json:"transaction_hash"`
+}
+
+// NewMintProvider creates the provider selected by MINT_PROVIDER, defaulting
+// to Verbwire
+func NewMintProvider() (MintProvider, error) {
+	name := strings.ToLower(config.String("MINT_PROVIDER", ProviderVerbwire))
+	switch name {
+	case ProviderVerbwire:
+		return NewVerbwireService(), nil
+	case ProviderEVM:
+		return NewEVMProvider()
+	case ProviderDryRun:
+		return sharedDryRunProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown mint provider %q", name)
+}
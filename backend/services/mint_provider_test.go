@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testRecipient = "0x9c3a6e0b2d4f81a7c5e3b1d9f0a2c4e6b8d0f1a3"
+	testOther     = "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+	testContract  = "0x1b4cc6d6e8f3b0a5c77a2f0e9d1c3b5a79e8f6d4"
+	testMinter    = "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
+)
+
+func TestNewMintProvider(t *testing.T) {
+	tests := []struct {
+		env  string
+		want interface{}
+	}{
+		{"", &VerbwireService{}},
+		{"verbwire", &VerbwireService{}},
+		{"DryRun", &DryRunProvider{}},
+	}
+
+	for _, tc := range tests {
+		t.Setenv("MINT_PROVIDER", tc.env)
+		provider, err := NewMintProvider()
+		if err != nil {
+			t.Fatalf("MINT_PROVIDER=%q: %v", tc.env, err)
+		}
+		switch tc.want.(type) {
+		case *VerbwireService:
+			if _, ok := provider.(*VerbwireService); !ok {
+				t.Errorf("MINT_PROVIDER=%q: got %T", tc.env, provider)
+			}
+		case *DryRunProvider:
+			if _, ok := provider.(*DryRunProvider); !ok {
+				t.Errorf("MINT_PROVIDER=%q: got %T", tc.env, provider)
+			}
+		}
+	}
+
+	t.Setenv("MINT_PROVIDER", "evm")
+	t.Setenv("EVM_CONTRACT_ADDRESS", "")
+	if _, err := NewMintProvider(); err == nil {
+		t.Error("expected error for evm provider without a contract address")
+	}
+
+	t.Setenv("MINT_PROVIDER", "ipfs")
+	if _, err := NewMintProvider(); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestDryRunProviderIsDeterministic(t *testing.T) {
+	mint := func() *MintResult {
+		res, err := NewDryRunProvider("polygonAmoy").Mint(context.Background(), MintNFTRequest{
+			Name:             "Genie Lamp",
+			RecipientAddress: testRecipient,
+		})
+		if err != nil {
+			t.Fatalf("Mint: %v", err)
+		}
+		return res
+	}
+
+	a, b := mint(), mint()
+	if *a != *b {
+		t.Errorf("fresh providers minted %+v and %+v", a, b)
+	}
+	if len(a.TransactionHash) != 66 || !IsHexAddress(a.ContractAddress) || a.TokenID != "1" {
+		t.Errorf("unexpected mint result: %+v", a)
+	}
+}
+
+func TestDryRunProviderLedger(t *testing.T) {
+	ctx := context.Background()
+	p := NewDryRunProvider("polygonAmoy")
+
+	first, err := p.Mint(ctx, MintNFTRequest{Name: "one", RecipientAddress: testRecipient})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	second, err := p.Mint(ctx, MintNFTRequest{Name: "two", RecipientAddress: testRecipient})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if first.TransactionHash == second.TransactionHash || second.TokenID != "2" {
+		t.Errorf("second mint reused values: %+v %+v", first, second)
+	}
+
+	transfer, err := p.Transfer(ctx, TransferRequest{
+		ContractAddress: first.ContractAddress,
+		TokenID:         "1",
+		FromAddress:     testRecipient,
+		ToAddress:       testOther,
+	})
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if len(transfer.TransactionHash) != 66 {
+		t.Errorf("transfer hash = %q", transfer.TransactionHash)
+	}
+
+	metadata, err := p.Metadata(ctx, first.ContractAddress, "1")
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	if metadata["owner"] != testOther {
+		t.Errorf("owner = %v, want %s", metadata["owner"], testOther)
+	}
+
+	nfts, err := p.WalletNFTs(ctx, testRecipient)
+	if err != nil {
+		t.Fatalf("WalletNFTs: %v", err)
+	}
+	if len(nfts) != 1 || nfts[0]["token_id"] != "2" {
+		t.Errorf("wallet NFTs = %v, want token 2 only", nfts)
+	}
+
+	if _, err := p.Transfer(ctx, TransferRequest{ContractAddress: first.ContractAddress, TokenID: "1", FromAddress: testRecipient, ToAddress: testOther}); err == nil {
+		t.Error("expected error transferring a token the sender no longer owns")
+	}
+}
+
+// fakeNode is a minimal JSON-RPC node holding one ERC-721 contract
+type fakeNode struct {
+	mu       sync.Mutex
+	owners   map[int64]string
+	uris     map[int64]string
+	receipts map[string]map[string]interface{}
+	logs     []map[string]interface{}
+	pending  int // receipt polls answered with null before a mined receipt
+	sent     []map[string]string
+	// extraBlocks are mined on top of the block of the last transaction
+	extraBlocks int
+}
+
+func newFakeNode(t *testing.T) (*fakeNode, *EVMProvider) {
+	t.Helper()
+
+	node := &fakeNode{
+		owners:   make(map[int64]string),
+		uris:     make(map[int64]string),
+		receipts: make(map[string]map[string]interface{}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(node.serve))
+	t.Cleanup(server.Close)
+
+	config := testClientConfig()
+	config.Name = "evm"
+	return node, &EVMProvider{
+		ContractAddress: testContract,
+		From:            testMinter,
+		MintFunction:    "safeMint(address,string)",
+		Chain:           "anvil",
+		ReceiptTimeout:  time.Second,
+		PollInterval:    time.Millisecond,
+		rpc:             &RPCClient{URL: server.URL, client: NewResilientClient(config)},
+	}
+}
+
+func (n *fakeNode) serve(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	result, rpcErr := n.handle(req.Method, req.Params)
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": 1}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (n *fakeNode) handle(method string, params []json.RawMessage) (interface{}, *RPCError) {
+	switch method {
+	case "eth_sendTransaction":
+		var tx map[string]string
+		json.Unmarshal(params[0], &tx)
+		n.sent = append(n.sent, tx)
+		return n.execute(tx)
+
+	case "eth_blockNumber":
+		return fmt.Sprintf("0x%x", len(n.sent)+n.extraBlocks), nil
+
+	case "eth_getTransactionReceipt":
+		var hash string
+		json.Unmarshal(params[0], &hash)
+		if n.pending > 0 {
+			n.pending--
+			return nil, nil
+		}
+		return n.receipts[hash], nil
+
+	case "eth_call":
+		var call map[string]string
+		json.Unmarshal(params[0], &call)
+		data := strings.TrimPrefix(call["data"], "0x")
+		id, _ := new(big.Int).SetString(data[8:72], 16)
+		owner, ok := n.owners[id.Int64()]
+		if !ok {
+			return nil, &RPCError{Code: 3, Message: "execution reverted: invalid token ID"}
+		}
+		switch data[:8] {
+		case abiSelector("ownerOf(uint256)"):
+			return "0x" + abiAddress(owner), nil
+		case abiSelector("tokenURI(uint256)"):
+			return "0x" + abiUint(big.NewInt(32)) + abiString(n.uris[id.Int64()]), nil
+		}
+
+	case "eth_getLogs":
+		var filter struct {
+			Topics []*string `json:"topics"`
+		}
+		json.Unmarshal(params[0], &filter)
+		var logs []map[string]interface{}
+		for _, l := range n.logs {
+			if l["topics"].([]string)[2] == *filter.Topics[2] {
+				logs = append(logs, l)
+			}
+		}
+		return logs, nil
+	}
+	return nil, &RPCError{Code: -32601, Message: "method not found"}
+}
+
+// execute applies safeMint and transferFrom calls and records a receipt
+func (n *fakeNode) execute(tx map[string]string) (interface{}, *RPCError) {
+	data := strings.TrimPrefix(tx["data"], "0x")
+	hash := "0x" + abiUint(big.NewInt(int64(len(n.sent))))
+
+	var from, to string
+	var id int64
+	switch data[:8] {
+	case abiSelector("safeMint(address,string)"):
+		id = int64(len(n.owners) + 1)
+		from, to = strings.Repeat("0", 40), data[8+24:72]
+		uri, _ := decodeABIString("0x" + abiUint(big.NewInt(32)) + data[136:])
+		n.uris[id] = uri
+	case abiSelector("transferFrom(address,address,uint256)"):
+		from, to = data[8+24:72], data[72+24:136]
+		tokenID, _ := new(big.Int).SetString(data[136:200], 16)
+		id = tokenID.Int64()
+		if n.owners[id] != "0x"+from {
+			return nil, &RPCError{Code: 3, Message: "execution reverted: not owner"}
+		}
+	default:
+		return nil, &RPCError{Code: 3, Message: "execution reverted"}
+	}
+
+	n.owners[id] = "0x" + to
+	log := map[string]interface{}{
+		"address": tx["to"],
+		"topics": []string{
+			transferEventTopic,
+			"0x" + strings.Repeat("0", 24) + from,
+			"0x" + strings.Repeat("0", 24) + to,
+			"0x" + abiUint(big.NewInt(id)),
+		},
+	}
+	n.logs = append(n.logs, log)
+	n.receipts[hash] = map[string]interface{}{
+		"blockNumber":       fmt.Sprintf("0x%x", len(n.sent)),
+		"blockHash":         "0x" + abiUint(big.NewInt(int64(1000+len(n.sent)))),
+		"status":            "0x1",
+		"gasUsed":           "0x5208",     // 21000
+		"effectiveGasPrice": "0x3b9aca00", // 1 gwei
+		"logs":              []interface{}{log},
+	}
+	return hash, nil
+}
+
+func TestEVMProviderMint(t *testing.T) {
+	node, p := newFakeNode(t)
+	node.pending = 2
+
+	res, err := p.Mint(context.Background(), MintNFTRequest{
+		Name:             "Genie Lamp",
+		Description:      "A lamp",
+		ImageURL:         "https://example.com/lamp.png",
+		RecipientAddress: testRecipient,
+	})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if res.TokenID != "1" || res.ContractAddress != testContract || res.TransactionHash == "" {
+		t.Errorf("unexpected mint result: %+v", res)
+	}
+
+	sent := node.sent[0]
+	if sent["from"] != testMinter || sent["to"] != testContract {
+		t.Errorf("unexpected transaction: %v", sent)
+	}
+
+	uri := node.uris[1]
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, tokenURIDataPrefix))
+	if err != nil {
+		t.Fatalf("token URI %q is not base64 JSON: %v", uri, err)
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal(raw, &metadata); err != nil || metadata["name"] != "Genie Lamp" {
+		t.Errorf("metadata = %s", raw)
+	}
+}
+
+func TestEVMProviderMintRejected(t *testing.T) {
+	node, p := newFakeNode(t)
+	p.MintFunction = "mint(address,string)"
+
+	_, err := p.Mint(context.Background(), MintNFTRequest{Name: "n", RecipientAddress: testRecipient})
+	if _, ok := err.(*RPCError); !ok {
+		t.Errorf("err = %v, want RPCError", err)
+	}
+	if len(node.sent) != 1 {
+		t.Errorf("sent %d transactions, want 1", len(node.sent))
+	}
+}
+
+func TestEVMProviderMetadataAndInventory(t *testing.T) {
+	_, p := newFakeNode(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Mint(ctx, MintNFTRequest{Name: "n", RecipientAddress: testRecipient}); err != nil {
+			t.Fatalf("Mint: %v", err)
+		}
+	}
+	if _, err := p.Transfer(ctx, TransferRequest{
+		ContractAddress: testContract,
+		TokenID:         "1",
+		FromAddress:     testRecipient,
+		ToAddress:       testOther,
+	}); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+
+	metadata, err := p.Metadata(ctx, testContract, "1")
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	if metadata["owner"] != testOther {
+		t.Errorf("owner = %v, want %s", metadata["owner"], testOther)
+	}
+	if m, ok := metadata["metadata"].(map[string]interface{}); !ok || m["name"] != "n" {
+		t.Errorf("decoded metadata = %v", metadata["metadata"])
+	}
+
+	nfts, err := p.WalletNFTs(ctx, testRecipient)
+	if err != nil {
+		t.Fatalf("WalletNFTs: %v", err)
+	}
+	if len(nfts) != 1 || nfts[0]["token_id"] != "2" {
+		t.Errorf("wallet NFTs = %v, want token 2 only", nfts)
+	}
+}
+
+func TestEVMProviderTransactionReceipt(t *testing.T) {
+	node, p := newFakeNode(t)
+	ctx := context.Background()
+
+	res, err := p.Mint(ctx, MintNFTRequest{Name: "n", RecipientAddress: testRecipient})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	node.extraBlocks = 11
+
+	receipt, err := p.TransactionReceipt(ctx, res.TransactionHash)
+	if err != nil {
+		t.Fatalf("TransactionReceipt: %v", err)
+	}
+	if receipt.BlockNumber != 1 || !receipt.Success || receipt.Confirmations != 12 {
+		t.Errorf("unexpected receipt: %+v", receipt)
+	}
+	if receipt.GasFee < 0.000020999 || receipt.GasFee > 0.000021001 {
+		t.Errorf("gas fee = %v, want 0.000021", receipt.GasFee)
+	}
+
+	missing, err := p.TransactionReceipt(ctx, "0x"+strings.Repeat("f", 64))
+	if err != nil || missing != nil {
+		t.Errorf("unknown hash: receipt = %+v, err = %v", missing, err)
+	}
+}
+
+func TestDryRunProviderReceipts(t *testing.T) {
+	ctx := context.Background()
+	p := NewDryRunProvider("polygonAmoy")
+
+	res, err := p.Mint(ctx, MintNFTRequest{Name: "n", RecipientAddress: testRecipient})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	receipt, err := p.TransactionReceipt(ctx, res.TransactionHash)
+	if err != nil {
+		t.Fatalf("TransactionReceipt: %v", err)
+	}
+	if receipt == nil || !receipt.Success || receipt.Confirmations < dryRunFinality {
+		t.Errorf("unexpected receipt: %+v", receipt)
+	}
+	if missing, _ := p.TransactionReceipt(ctx, "0xabc"); missing != nil {
+		t.Errorf("unknown hash returned %+v", missing)
+	}
+}
+
+func TestNewReceiptSource(t *testing.T) {
+	dryRun := NewDryRunProvider("polygonAmoy")
+
+	t.Setenv("CHAIN_RPC_URL", "")
+	if source := NewReceiptSource(dryRun); source != ReceiptSource(dryRun) {
+		t.Errorf("got %T, want the provider", source)
+	}
+
+	t.Setenv("CHAIN_RPC_URL", "http://127.0.0.1:8545")
+	if source, ok := NewReceiptSource(dryRun).(*RPCClient); !ok || source.URL != "http://127.0.0.1:8545" {
+		t.Errorf("got %T, want RPCClient for CHAIN_RPC_URL", source)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"nftgenie/backend/config"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"nftgenie/backend/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLAlgorithmVersion tags recommendations scored by
+// NFTRepository.GetRecommendedForUser
+const SQLAlgorithmVersion = "sql-v2"
+
+// sqlModel is the registry entry of the SQL rules
+var sqlModel = &models.ModelVersion{Version: SQLAlgorithmVersion, Source: models.ModelSourceSQL}
+
+// Recommender serves a user's recommendations from the recommendations
+// cache, recomputing and caching them once they expire. They come from the
+// ai-engine while it is healthy and from the SQL rules otherwise.
+type Recommender struct {
+	CacheTTL time.Duration
+
+	engine        *AIEngineClient
+	nfts          *repository.NFTRepository
+	cache         *repository.RecommendationRepository
+	modelVersions *repository.ModelVersionRepository
+}
+
+// NewRecommender creates a recommender caching for RECOMMENDATION_CACHE_TTL
+func NewRecommender(engine *AIEngineClient) *Recommender {
+	return &Recommender{
+		CacheTTL:      config.Duration("RECOMMENDATION_CACHE_TTL", time.Hour),
+		engine:        engine,
+		nfts:          repository.NewNFTRepository(),
+		cache:         repository.NewRecommendationRepository(),
+		modelVersions: repository.NewModelVersionRepository(),
+	}
+}
+
+// Recommend returns up to limit recommendations for the user, best first
+func (r *Recommender) Recommend(ctx context.Context, user *models.User, limit int) ([]*models.Recommendation, error) {
+	if health, ok := r.engine.Healthy(ctx); ok {
+		recommendations, err := r.serve(user.ID, health.Model(), limit, func() ([]*models.Recommendation, error) {
+			return r.fromEngine(ctx, user, limit)
+		})
+		if err == nil {
+			return recommendations, nil
+		}
+		log.Printf("ai-engine recommendations for %s, falling back to SQL: %v", user.ID, err)
+	}
+
+	return r.serve(user.ID, sqlModel, limit, func() ([]*models.Recommendation, error) {
+		return r.nfts.GetRecommendedForUser(user.ID, limit)
+	})
+}
+
+// serve returns the fresh cached recommendations of the model, computing and
+// caching them when there are none. Cached rows name the model as their
+// algorithm_version, so it is registered first.
+func (r *Recommender) serve(userID uuid.UUID, model *models.ModelVersion, limit int, compute func() ([]*models.Recommendation, error)) ([]*models.Recommendation, error) {
+	cached, err := r.cache.GetFresh(userID, model.Version, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(cached) > 0 {
+		return cached, nil
+	}
+
+	recommendations, err := compute()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(r.CacheTTL)
+	err = database.Transaction(func(tx *sqlx.Tx) error {
+		if err := r.modelVersions.WithTx(tx).Register(model); err != nil {
+			return err
+		}
+		return r.cache.WithTx(tx).Replace(userID, model.Version, recommendations, expiresAt)
+	})
+	if err != nil {
+		// The scores are still good; the next request recomputes them
+		log.Printf("caching recommendations for %s: %v", userID, err)
+	}
+	return recommendations, nil
+}
+
+// fromEngine asks the ai-engine for recommendations and joins their NFTs.
+// NFTs the engine knows about but the database no longer has are dropped.
+func (r *Recommender) fromEngine(ctx context.Context, user *models.User, limit int) ([]*models.Recommendation, error) {
+	results, err := r.engine.Recommend(ctx, AIRecommendRequest{
+		UserID:       user.ID.String(),
+		Limit:        limit,
+		Strategy:     "hybrid",
+		ExcludeOwned: true,
+		Diversify:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(results))
+	for _, result := range results {
+		if id, err := uuid.Parse(result.NFTID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	nfts, err := r.nfts.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := make([]*models.Recommendation, 0, len(results))
+	for _, result := range results {
+		id, _ := uuid.Parse(result.NFTID)
+		nft, ok := nfts[id]
+		if !ok {
+			continue
+		}
+		reason := result.Reason
+		recommendations = append(recommendations, &models.Recommendation{
+			ID:     uuid.New(),
+			UserID: user.ID,
+			NFTID:  id,
+			Score:  result.Score,
+			Reason: &reason,
+			Rule:   models.RuleAIEngine,
+			NFT:    nft,
+		})
+	}
+	return recommendations, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"nftgenie/backend/config"
+	"strconv"
+	"strings"
+)
+
+// Receipt is a mined transaction as seen by a ReceiptSource
+type Receipt struct {
+	BlockNumber   int64
+	BlockHash     string
+	Success       bool
+	GasFee        float64 // in the chain's native token
+	Confirmations int64
+}
+
+// ReceiptSource looks up receipts of submitted transactions. It returns a nil
+// receipt while the transaction is not mined.
+type ReceiptSource interface {
+	TransactionReceipt(ctx context.Context, txHash string) (*Receipt, error)
+}
+
+// NewReceiptSource picks where confirmations are read from: CHAIN_RPC_URL
+// when set, otherwise the mint provider itself
+func NewReceiptSource(provider MintProvider) ReceiptSource {
+	if url := config.String("CHAIN_RPC_URL", ""); url != "" {
+		return NewRPCClient(url)
+	}
+	if source, ok := provider.(ReceiptSource); ok {
+		return source
+	}
+	return NewVerbwireService()
+}
+
+// RPCClient calls an EVM JSON-RPC node through the resilient client
+type RPCClient struct {
+	URL string
+
+	client *ResilientClient
+}
+
+// NewRPCClient creates a JSON-RPC client for the node at url
+func NewRPCClient(url string) *RPCClient {
+	return &RPCClient{
+		URL:    url,
+		client: NewResilientClient(ClientConfigFromEnv("rpc", "CHAIN_RPC")),
+	}
+}
+
+// Call performs a JSON-RPC call; only eth_sendTransaction is not retried
+func (c *RPCClient) Call(ctx context.Context, method string, out interface{}, params ...interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	respBody, err := c.client.Do(ctx, Request{
+		Method:      http.MethodPost,
+		URL:         c.URL,
+		Body:        body,
+		ContentType: "application/json",
+		Idempotent:  method != "eth_sendTransaction",
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("failed to parse %s result: %w", method, err)
+	}
+	return nil
+}
+
+// BlockNumber returns the node's latest block number
+func (c *RPCClient) BlockNumber(ctx context.Context) (int64, error) {
+	var head string
+	if err := c.Call(ctx, "eth_blockNumber", &head); err != nil {
+		return 0, err
+	}
+	return parseHexInt(head)
+}
+
+// TransactionReceipt implements ReceiptSource
+func (c *RPCClient) TransactionReceipt(ctx context.Context, txHash string) (*Receipt, error) {
+	var raw *struct {
+		BlockNumber       string `json:"blockNumber"`
+		BlockHash         string `json:"blockHash"`
+		Status            string `json:"status"`
+		GasUsed           string `json:"gasUsed"`
+		EffectiveGasPrice string `json:"effectiveGasPrice"`
+	}
+	if err := c.Call(ctx, "eth_getTransactionReceipt", &raw, txHash); err != nil {
+		return nil, err
+	}
+	if raw == nil || raw.BlockNumber == "" {
+		return nil, nil
+	}
+
+	block, err := parseHexInt(raw.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	head, err := c.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Receipt{
+		BlockNumber:   block,
+		BlockHash:     raw.BlockHash,
+		Success:       raw.Status == "0x1",
+		GasFee:        gasFee(raw.GasUsed, raw.EffectiveGasPrice),
+		Confirmations: head - block + 1,
+	}, nil
+}
+
+// parseHexInt parses a 0x-prefixed quantity
+func parseHexInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimPrefix(s, "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	return n, nil
+}
+
+// gasFee converts gas used times the gas price in wei to native units. Both
+// values may be hex quantities or decimal strings.
+func gasFee(gasUsed, gasPrice string) float64 {
+	used, ok1 := parseBigQuantity(gasUsed)
+	price, ok2 := parseBigQuantity(gasPrice)
+	if !ok1 || !ok2 {
+		return 0
+	}
+
+	wei := new(big.Float).SetInt(new(big.Int).Mul(used, price))
+	fee, _ := new(big.Float).Quo(wei, big.NewFloat(1e18)).Float64()
+	return fee
+}
+
+// parseBigQuantity parses a hex or decimal integer string
+func parseBigQuantity(s string) (*big.Int, bool) {
+	if strings.HasPrefix(s, "0x") {
+		return new(big.Int).SetString(s[2:], 16)
+	}
+	return new(big.Int).SetString(s, 10)
+}
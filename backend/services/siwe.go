@@ -0,0 +1,269 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"nftgenie/backend/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// Errors returned while verifying a Sign-In-With-Ethereum login
+var (
+	ErrInvalidSIWEMessage = errors.New("invalid SIWE message")
+	ErrInvalidSignature   = errors.New("invalid signature")
+	ErrSignerMismatch     = errors.New("signature does not match address")
+	ErrNonceMismatch      = errors.New("invalid or expired nonce")
+	ErrMessageExpired     = errors.New("SIWE message expired")
+)
+
+// siweHeaderSuffix ends the first line of an EIP-4361 message
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// siweMaxAge bounds how old an Issued At timestamp may be
+const siweMaxAge = 10 * time.Minute
+
+// SIWENonceTTL is how long an issued nonce stays valid for a login
+const SIWENonceTTL = siweMaxAge
+
+// SIWEMessage is a parsed EIP-4361 Sign-In-With-Ethereum message
+type SIWEMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// SIWEService verifies wallet logins against the configured domain and chain
+type SIWEService struct {
+	Domain  string
+	ChainID int64
+}
+
+// NewSIWEService creates a new SIWE service instance
+func NewSIWEService() *SIWEService {
+	return &SIWEService{
+		Domain:  config.String("SIWE_DOMAIN", "localhost:3000"),
+		ChainID: int64(config.Int("SIWE_CHAIN_ID", 80002)), // Polygon Amoy
+	}
+}
+
+// GenerateNonce returns a random alphanumeric nonce as required by EIP-4361
+func GenerateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Verify checks that the message was signed by its address for this domain,
+// chain and the expected nonce, and returns the parsed message
+func (s *SIWEService) Verify(message, signature, expectedNonce string) (*SIWEMessage, error) {
+	msg, err := ParseSIWEMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Domain != s.Domain {
+		return nil, fmt.Errorf("%w: unexpected domain %q", ErrInvalidSIWEMessage, msg.Domain)
+	}
+	if msg.ChainID != s.ChainID {
+		return nil, fmt.Errorf("%w: unexpected chain ID %d", ErrInvalidSIWEMessage, msg.ChainID)
+	}
+	if expectedNonce == "" || msg.Nonce != expectedNonce {
+		return nil, ErrNonceMismatch
+	}
+
+	now := time.Now()
+	if msg.ExpirationTime != nil && !now.Before(*msg.ExpirationTime) {
+		return nil, ErrMessageExpired
+	}
+	if msg.NotBefore != nil && now.Before(*msg.NotBefore) {
+		return nil, fmt.Errorf("%w: message not yet valid", ErrInvalidSIWEMessage)
+	}
+	if now.Sub(msg.IssuedAt) > siweMaxAge {
+		return nil, ErrMessageExpired
+	}
+
+	signer, err := RecoverPersonalSignAddress(message, signature)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(signer, msg.Address) {
+		return nil, ErrSignerMismatch
+	}
+
+	return msg, nil
+}
+
+// ParseSIWEMessage parses an EIP-4361 message
+func ParseSIWEMessage(message string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 7 || !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, ErrInvalidSIWEMessage
+	}
+
+	msg := &SIWEMessage{
+		Domain:  strings.TrimSuffix(lines[0], siweHeaderSuffix),
+		Address: strings.TrimSpace(lines[1]),
+	}
+	if !IsHexAddress(msg.Address) {
+		return nil, fmt.Errorf("%w: invalid address", ErrInvalidSIWEMessage)
+	}
+
+	// An optional statement sits between two blank lines after the address
+	i := 2
+	if i < len(lines) && lines[i] == "" {
+		i++
+	}
+	if i < len(lines) && !strings.HasPrefix(lines[i], "URI: ") {
+		msg.Statement = lines[i]
+		i++
+		if i < len(lines) && lines[i] == "" {
+			i++
+		}
+	}
+
+	inResources := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if inResources {
+			if strings.HasPrefix(line, "- ") {
+				msg.Resources = append(msg.Resources, strings.TrimPrefix(line, "- "))
+				continue
+			}
+			inResources = false
+		}
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			inResources = true
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected line %q", ErrInvalidSIWEMessage, line)
+		}
+
+		var err error
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Version":
+			msg.Version = value
+		case "Chain ID":
+			msg.ChainID, err = strconv.ParseInt(value, 10, 64)
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			msg.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			msg.ExpirationTime, err = parseSIWETime(value)
+		case "Not Before":
+			msg.NotBefore, err = parseSIWETime(value)
+		case "Request ID":
+			msg.RequestID = value
+		default:
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSIWEMessage, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s", ErrInvalidSIWEMessage, key)
+		}
+	}
+
+	if msg.URI == "" || msg.Version != "1" || msg.ChainID == 0 || len(msg.Nonce) < 8 || msg.IssuedAt.IsZero() {
+		return nil, fmt.Errorf("%w: missing required fields", ErrInvalidSIWEMessage)
+	}
+
+	return msg, nil
+}
+
+// RecoverPersonalSignAddress recovers the address that produced an EIP-191
+// personal_sign signature over the message
+func RecoverPersonalSignAddress(message, signature string) (string, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != 65 {
+		return "", ErrInvalidSignature
+	}
+
+	// Ethereum signatures are r || s || v with v in {0, 1} or {27, 28}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", ErrInvalidSignature
+	}
+
+	// Compact format expected by decred is <27 + recovery code> || r || s
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], sig[:64])
+
+	pubKey, _, err := ecdsa.RecoverCompact(compact, personalSignHash(message))
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	hash := keccak256(pubKey.SerializeUncompressed()[1:])
+	return "0x" + hex.EncodeToString(hash[12:]), nil
+}
+
+// IsHexAddress reports whether s is a 0x-prefixed 20 byte hex address
+func IsHexAddress(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// IsTxHash reports whether s is a 0x-prefixed 32 byte transaction hash
+func IsTxHash(s string) bool {
+	if len(s) != 66 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// personalSignHash hashes a message with the EIP-191 personal_sign prefix
+func personalSignHash(message string) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))
+	return keccak256([]byte(prefix), []byte(message))
+}
+
+// keccak256 returns the legacy Keccak-256 hash used by Ethereum
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// parseSIWETime parses an optional RFC 3339 timestamp
+func parseSIWETime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
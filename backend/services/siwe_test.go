@@ -0,0 +1,228 @@
+package services
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// The first well-known Hardhat/anvil development account
+const (
+	testKeyHex  = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+	testAddress = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+)
+
+// testMessage and testSignature are a login signed by the test account with
+// personal_sign, as a wallet would produce it
+const (
+	testMessage = "localhost:3000 wants you to sign in with your Ethereum account:\n" +
+		"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266\n\n" +
+		"Sign in to NFTGenie\n\n" +
+		"URI: http://localhost:3000\n" +
+		"Version: 1\n" +
+		"Chain ID: 80002\n" +
+		"Nonce: 6a1b0c5e9f2d4a7b\n" +
+		"Issued At: 2026-01-01T00:00:00Z"
+	testSignature = "0x741e87b3bdd87b03b8e59ce4bbdac69d4f372cd2f571cc1f5d9b6f869ea0f683" +
+		"652a104d81e86f622a599053d275147cf854ab176418f6323191faac4690b33c1b"
+)
+
+func testKey(t *testing.T) *secp256k1.PrivateKey {
+	t.Helper()
+	b, err := hex.DecodeString(testKeyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secp256k1.PrivKeyFromBytes(b)
+}
+
+// personalSign signs message as a wallet's personal_sign does
+func personalSign(key *secp256k1.PrivateKey, message string) string {
+	compact := ecdsa.SignCompact(key, personalSignHash(message), false)
+	// Reorder <v> || r || s into r || s || <v>
+	return "0x" + hex.EncodeToString(append(compact[1:65:65], compact[0]))
+}
+
+// siweMessage builds a login message for the test account with extra fields
+// appended
+func siweMessage(domain string, chainID int64, nonce string, issuedAt time.Time, extra ...string) string {
+	message := fmt.Sprintf("%s wants you to sign in with your Ethereum account:\n%s\n\nSign in to NFTGenie\n\n"+
+		"URI: http://%s\nVersion: 1\nChain ID: %d\nNonce: %s\nIssued At: %s",
+		domain, testAddress, domain, chainID, nonce, issuedAt.UTC().Format(time.RFC3339))
+	for _, field := range extra {
+		message += "\n" + field
+	}
+	return message
+}
+
+func TestRecoverPersonalSignAddress(t *testing.T) {
+	signer, err := RecoverPersonalSignAddress(testMessage, testSignature)
+	if err != nil {
+		t.Fatalf("RecoverPersonalSignAddress: %v", err)
+	}
+	if !strings.EqualFold(signer, testAddress) {
+		t.Errorf("signer = %s, want %s", signer, testAddress)
+	}
+
+	// Some wallets send v as 0/1 rather than 27/28
+	legacyV := testSignature[:len(testSignature)-2] + "00"
+	if signer, err := RecoverPersonalSignAddress(testMessage, legacyV); err != nil || !strings.EqualFold(signer, testAddress) {
+		t.Errorf("v = 0: signer = %s, %v, want %s", signer, err, testAddress)
+	}
+
+	// A signature over another message recovers another address
+	if signer, err := RecoverPersonalSignAddress(testMessage+" ", testSignature); err == nil && strings.EqualFold(signer, testAddress) {
+		t.Error("signature verified for a changed message")
+	}
+
+	for name, signature := range map[string]string{
+		"not hex":   "0xzz" + testSignature[4:],
+		"too short": testSignature[:len(testSignature)-2],
+		"bad v":     testSignature[:len(testSignature)-2] + "1d",
+		"empty":     "",
+	} {
+		if _, err := RecoverPersonalSignAddress(testMessage, signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
+
+func TestParseSIWEMessage(t *testing.T) {
+	msg, err := ParseSIWEMessage(testMessage)
+	if err != nil {
+		t.Fatalf("ParseSIWEMessage: %v", err)
+	}
+	if msg.Domain != "localhost:3000" || msg.Address != testAddress || msg.Statement != "Sign in to NFTGenie" ||
+		msg.URI != "http://localhost:3000" || msg.Version != "1" || msg.ChainID != 80002 ||
+		msg.Nonce != "6a1b0c5e9f2d4a7b" || !msg.IssuedAt.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("parsed %+v", msg)
+	}
+
+	// Optional fields, CRLF line endings and no statement
+	full := strings.ReplaceAll(strings.Replace(testMessage, "Sign in to NFTGenie\n\n", "", 1)+
+		"\nExpiration Time: 2026-01-02T00:00:00Z\nNot Before: 2025-12-31T00:00:00Z\nRequest ID: req-1"+
+		"\nResources:\n- ipfs://bafy\n- https://example.com/terms", "\n", "\r\n")
+	msg, err = ParseSIWEMessage(full)
+	if err != nil {
+		t.Fatalf("ParseSIWEMessage with optional fields: %v", err)
+	}
+	if msg.Statement != "" || msg.ExpirationTime == nil || msg.NotBefore == nil || msg.RequestID != "req-1" ||
+		len(msg.Resources) != 2 || msg.Resources[1] != "https://example.com/terms" {
+		t.Errorf("parsed %+v", msg)
+	}
+
+	malformed := map[string]string{
+		"empty":           "",
+		"no header":       strings.Replace(testMessage, " wants you to sign in", " asks you to sign in", 1),
+		"bad address":     strings.Replace(testMessage, testAddress, "0x1234", 1),
+		"unknown field":   testMessage + "\nColor: blue",
+		"no key":          testMessage + "\nsomething",
+		"bad chain ID":    strings.Replace(testMessage, "Chain ID: 80002", "Chain ID: amoy", 1),
+		"bad issued at":   strings.Replace(testMessage, "2026-01-01T00:00:00Z", "yesterday", 1),
+		"short nonce":     strings.Replace(testMessage, "6a1b0c5e9f2d4a7b", "abc", 1),
+		"missing nonce":   strings.Replace(testMessage, "\nNonce: 6a1b0c5e9f2d4a7b", "", 1),
+		"missing URI":     strings.Replace(testMessage, "URI: http://localhost:3000\n", "", 1),
+		"other version":   strings.Replace(testMessage, "Version: 1", "Version: 2", 1),
+		"bad expiration":  testMessage + "\nExpiration Time: soon",
+		"truncated lines": strings.Join(strings.Split(testMessage, "\n")[:5], "\n"),
+	}
+	for name, message := range malformed {
+		if _, err := ParseSIWEMessage(message); !errors.Is(err, ErrInvalidSIWEMessage) {
+			t.Errorf("%s: err = %v, want ErrInvalidSIWEMessage", name, err)
+		}
+	}
+}
+
+func TestSIWEVerify(t *testing.T) {
+	key := testKey(t)
+	service := &SIWEService{Domain: "localhost:3000", ChainID: 80002}
+	const nonce = "0123456789abcdef"
+	now := time.Now()
+
+	message := siweMessage("localhost:3000", 80002, nonce, now)
+	msg, err := service.Verify(message, personalSign(key, message), nonce)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if msg.Address != testAddress || msg.Nonce != nonce {
+		t.Errorf("verified %+v", msg)
+	}
+
+	other, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		message   string
+		signer    *secp256k1.PrivateKey
+		signature string
+		nonce     string
+		want      error
+	}{
+		{name: "wrong domain", message: siweMessage("evil.example", 80002, nonce, now), want: ErrInvalidSIWEMessage},
+		{name: "wrong chain", message: siweMessage("localhost:3000", 1, nonce, now), want: ErrInvalidSIWEMessage},
+		{name: "reused nonce", message: siweMessage("localhost:3000", 80002, "fedcba9876543210", now), want: ErrNonceMismatch},
+		{name: "no nonce issued", message: message, nonce: "-", want: ErrNonceMismatch},
+		{
+			name:    "expired",
+			message: siweMessage("localhost:3000", 80002, nonce, now, "Expiration Time: "+now.Add(-time.Second).UTC().Format(time.RFC3339)),
+			want:    ErrMessageExpired,
+		},
+		{name: "issued too long ago", message: siweMessage("localhost:3000", 80002, nonce, now.Add(-siweMaxAge-time.Minute)), want: ErrMessageExpired},
+		{
+			name:    "not yet valid",
+			message: siweMessage("localhost:3000", 80002, nonce, now, "Not Before: "+now.Add(time.Hour).UTC().Format(time.RFC3339)),
+			want:    ErrInvalidSIWEMessage,
+		},
+		{name: "malformed", message: "sign in please", want: ErrInvalidSIWEMessage},
+		{name: "wrong key", message: message, signer: other, want: ErrSignerMismatch},
+		{name: "garbage signature", message: message, signature: "0x1234", want: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := tt.signer
+			if signer == nil {
+				signer = key
+			}
+			signature := tt.signature
+			if signature == "" {
+				signature = personalSign(signer, tt.message)
+			}
+			expected := nonce
+			if tt.nonce == "-" {
+				expected = ""
+			}
+
+			if _, err := service.Verify(tt.message, signature, expected); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTxHash(t *testing.T) {
+	hash := "0x" + strings.Repeat("ab", 32)
+	tests := map[string]bool{
+		hash:                            true,
+		"0X" + hash[2:]:                 false,
+		"0x" + strings.Repeat("AB", 32): true,
+		hash[:65]:                       false,
+		hash + "00":                     false,
+		"0x" + strings.Repeat("zz", 32): false,
+		strings.Repeat("ab", 33):        false,
+		"":                              false,
+	}
+	for s, want := range tests {
+		if got := IsTxHash(s); got != want {
+			t.Errorf("IsTxHash(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -2,40 +2,51 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"net/url"
+	"nftgenie/backend/config"
+	"sync"
 )
 
+// sharedVerbwireClient is the one HTTP client used for all Verbwire calls so
+// that rate limiting and circuit breaking see the whole process's traffic
+var sharedVerbwireClient = sync.OnceValue(func() *ResilientClient {
+	return NewResilientClient(ClientConfigFromEnv("verbwire", "VERBWIRE"))
+})
+
 // VerbwireService handles all Verbwire API interactions
 type VerbwireService struct {
 	APIKey    string
 	PublicKey string
 	BaseURL   string
 	Chain     string
+
+	client *ResilientClient
 }
 
 // NewVerbwireService creates a new Verbwire service instance
 func NewVerbwireService() *VerbwireService {
 	return &VerbwireService{
-		APIKey:    os.Getenv("VERBWIRE_API_KEY"),
-		PublicKey: os.Getenv("VERBWIRE_PUBLIC_KEY"),
-		BaseURL:   os.Getenv("VERBWIRE_BASE_URL"),
-		Chain:     os.Getenv("CHAIN"),
+		APIKey:    config.String("VERBWIRE_API_KEY", ""),
+		PublicKey: config.String("VERBWIRE_PUBLIC_KEY", ""),
+		BaseURL:   config.String("VERBWIRE_BASE_URL", ""),
+		Chain:     config.String("CHAIN", ""),
+		client:    sharedVerbwireClient(),
 	}
 }
 
 // MintNFTRequest represents the request to mint an NFT
 type MintNFTRequest struct {
-	Name            string `json:"name"`
-	Description     string `json:"description"`
-	ImageURL        string `json:"imageUrl"`
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	ImageURL         string `json:"imageUrl"`
 	RecipientAddress string `json:"recipientAddress"`
-	Chain           string `json:"chain"`
-	Quantity        int    `json:"quantity"`
+	Chain            string `json:"chain"`
+	Quantity         int    `json:"quantity"`
 }
 
 // MintNFTResponse represents the response from minting an NFT
@@ -59,13 +70,7 @@ type MintNFTResponse struct {
 }
 
 // QuickMintNFT mints an NFT using Verbwire's Quick Mint API
-func (v *VerbwireService) QuickMintNFT(req MintNFTRequest) (*MintNFTResponse, error) {
-	url := fmt.Sprintf("%s/nft/mint/quickMintFromMetadata", v.BaseURL)
-	
-	// Create multipart form data
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	
+func (v *VerbwireService) QuickMintNFT(ctx context.Context, req MintNFTRequest) (*MintNFTResponse, error) {
 	// Create metadata object
 	metadata := map[string]interface{}{
 		"name":        req.Name,
@@ -73,237 +78,209 @@ func (v *VerbwireService) QuickMintNFT(req MintNFTRequest) (*MintNFTResponse, er
 		"image":       req.ImageURL,
 	}
 	metadataJSON, _ := json.Marshal(metadata)
-	
-	// Add form fields
-	writer.WriteField("chain", v.Chain)
-	writer.WriteField("data", string(metadataJSON))
-	writer.WriteField("recipientAddress", req.RecipientAddress)
-	
-	err := writer.Close()
-	if err != nil {
-		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
-	}
-	
-	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", url, body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	
-	// Set headers
-	httpReq.Header.Set("X-API-Key", v.APIKey)
-	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
-	httpReq.Header.Set("Accept", "application/json")
-	
-	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-	
-	// Parse response
+
 	var mintResp MintNFTResponse
-	if err := json.Unmarshal(respBody, &mintResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, mintResp.Message)
+	err := v.postForm(ctx, "/nft/mint/quickMintFromMetadata", map[string]string{
+		"chain":            v.Chain,
+		"data":             string(metadataJSON),
+		"recipientAddress": req.RecipientAddress,
+	}, &mintResp)
+	if err != nil {
+		return nil, err
 	}
-	
+
 	return &mintResp, nil
 }
 
 // GetNFTsByWallet retrieves all NFTs owned by a wallet address
-func (v *VerbwireService) GetNFTsByWallet(walletAddress string) ([]map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/data/nftsByWalletAddress", v.BaseURL)
-	
-	// Create request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	
-	// Add query parameters
-	q := req.URL.Query()
-	q.Add("walletAddress", walletAddress)
-	q.Add("chain", v.Chain)
-	req.URL.RawQuery = q.Encode()
-	
-	// Set headers
-	req.Header.Set("X-API-Key", v.APIKey)
-	req.Header.Set("Accept", "application/json")
-	
-	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-	
-	// Parse response
+func (v *VerbwireService) GetNFTsByWallet(ctx context.Context, walletAddress string) ([]map[string]interface{}, error) {
 	var result struct {
 		NFTs []map[string]interface{} `json:"nfts"`
 	}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	err := v.get(ctx, "/data/nftsByWalletAddress", url.Values{
+		"walletAddress": {walletAddress},
+		"chain":         {v.Chain},
+	}, &result)
+	if err != nil {
+		return nil, err
 	}
-	
+
 	return result.NFTs, nil
 }
 
 // GetNFTMetadata retrieves metadata for a specific NFT
-func (v *VerbwireService) GetNFTMetadata(contractAddress, tokenID string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/nft/data/nftDetails", v.BaseURL)
-	
-	// Create request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	
-	// Add query parameters
-	q := req.URL.Query()
-	q.Add("contractAddress", contractAddress)
-	q.Add("tokenId", tokenID)
-	q.Add("chain", v.Chain)
-	req.URL.RawQuery = q.Encode()
-	
-	// Set headers
-	req.Header.Set("X-API-Key", v.APIKey)
-	req.Header.Set("Accept", "application/json")
-	
-	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-	
-	// Parse response
+func (v *VerbwireService) GetNFTMetadata(ctx context.Context, contractAddress, tokenID string) (map[string]interface{}, error) {
 	var metadata map[string]interface{}
-	if err := json.Unmarshal(respBody, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	err := v.get(ctx, "/nft/data/nftDetails", url.Values{
+		"contractAddress": {contractAddress},
+		"tokenId":         {tokenID},
+		"chain":           {v.Chain},
+	}, &metadata)
+	if err != nil {
+		return nil, err
 	}
-	
+
 	return metadata, nil
 }
 
 // TransferNFT transfers an NFT from one wallet to another
-func (v *VerbwireService) TransferNFT(contractAddress, tokenID, fromAddress, toAddress string) (*map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/nft/transfer", v.BaseURL)
-	
-	// Create multipart form data
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	
-	// Add form fields
-	writer.WriteField("chain", v.Chain)
-	writer.WriteField("contractAddress", contractAddress)
-	writer.WriteField("tokenId", tokenID)
-	writer.WriteField("fromAddress", fromAddress)
-	writer.WriteField("toAddress", toAddress)
-	
-	err := writer.Close()
+func (v *VerbwireService) TransferNFT(ctx context.Context, contractAddress, tokenID, fromAddress, toAddress string) (*map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := v.postForm(ctx, "/nft/transfer", map[string]string{
+		"chain":           v.Chain,
+		"contractAddress": contractAddress,
+		"tokenId":         tokenID,
+		"fromAddress":     fromAddress,
+		"toAddress":       toAddress,
+	}, &result)
 	if err != nil {
-		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+		return nil, err
 	}
-	
-	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", url, body)
+
+	return &result, nil
+}
+
+// GetCollectionStats retrieves statistics for an NFT collection
+func (v *VerbwireService) GetCollectionStats(ctx context.Context, contractAddress string) (map[string]interface{}, error) {
+	var stats map[string]interface{}
+	err := v.get(ctx, "/nft/data/collectionStatistics", url.Values{
+		"contractAddress": {contractAddress},
+		"chain":           {v.Chain},
+	}, &stats)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
-	
-	// Set headers
-	httpReq.Header.Set("X-API-Key", v.APIKey)
-	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
-	httpReq.Header.Set("Accept", "application/json")
-	
-	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+
+	return stats, nil
+}
+
+// TransactionReceipt implements ReceiptSource using Verbwire's transaction
+// details; a transaction without a block number is not mined yet
+func (v *VerbwireService) TransactionReceipt(ctx context.Context, txHash string) (*Receipt, error) {
+	var result struct {
+		Details *struct {
+			BlockNumber       int64       `json:"blockNumber"`
+			BlockHash         string      `json:"blockHash"`
+			Status            json.Number `json:"status"`
+			GasUsed           string      `json:"gasUsed"`
+			EffectiveGasPrice string      `json:"effectiveGasPrice"`
+			Confirmations     int64       `json:"confirmations"`
+		} `json:"transaction_details"`
 	}
-	defer resp.Body.Close()
-	
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
+	err := v.get(ctx, "/nft/data/transactionDetails", url.Values{
+		"transactionHash": {txHash},
+		"chain":           {v.Chain},
+	}, &result)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
-	
-	// Parse response
-	var result map[string]interface{}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if result.Details == nil || result.Details.BlockNumber == 0 {
+		return nil, nil
 	}
-	
-	return &result, nil
+
+	return &Receipt{
+		BlockNumber:   result.Details.BlockNumber,
+		BlockHash:     result.Details.BlockHash,
+		Success:       result.Details.Status == "1",
+		GasFee:        gasFee(result.Details.GasUsed, result.Details.EffectiveGasPrice),
+		Confirmations: result.Details.Confirmations,
+	}, nil
 }
 
-// GetCollectionStats retrieves statistics for an NFT collection
-func (v *VerbwireService) GetCollectionStats(contractAddress string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/nft/data/collectionStatistics", v.BaseURL)
-	
-	// Create request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// get sends a GET request with query parameters and decodes the response
+func (v *VerbwireService) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return v.do(ctx, Request{
+		Method:     http.MethodGet,
+		URL:        v.BaseURL + path + "?" + query.Encode(),
+		Idempotent: true,
+	}, out)
+}
+
+// postForm sends a multipart form POST request and decodes the response
+func (v *VerbwireService) postForm(ctx context.Context, path string, fields map[string]string, out interface{}) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
 	}
-	
-	// Add query parameters
-	q := req.URL.Query()
-	q.Add("contractAddress", contractAddress)
-	q.Add("chain", v.Chain)
-	req.URL.RawQuery = q.Encode()
-	
-	// Set headers
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	return v.do(ctx, Request{
+		Method:      http.MethodPost,
+		URL:         v.BaseURL + path,
+		Body:        body.Bytes(),
+		ContentType: writer.FormDataContentType(),
+	}, out)
+}
+
+// do sends an authenticated request through the shared client
+func (v *VerbwireService) do(ctx context.Context, req Request, out interface{}) error {
+	req.Header = http.Header{}
 	req.Header.Set("X-API-Key", v.APIKey)
 	req.Header.Set("Accept", "application/json")
-	
-	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+
+	client := v.client
+	if client == nil {
+		client = sharedVerbwireClient()
+	}
+
+	respBody, err := client.Do(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return err
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
-	defer resp.Body.Close()
-	
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
+	return nil
+}
+
+// ProviderName implements MintProvider
+func (v *VerbwireService) ProviderName() string {
+	return ProviderVerbwire
+}
+
+// ChainName returns the configured Verbwire chain
+func (v *VerbwireService) ChainName() string {
+	return v.Chain
+}
+
+// Mint implements MintProvider using Quick Mint
+func (v *VerbwireService) Mint(ctx context.Context, req MintNFTRequest) (*MintResult, error) {
+	res, err := v.QuickMintNFT(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
-	
-	// Parse response
-	var stats map[string]interface{}
-	if err := json.Unmarshal(respBody, &stats); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	return &MintResult{
+		TransactionHash: res.TransactionHash,
+		ContractAddress: res.ContractAddress,
+		TokenID:         res.TokenID,
+		OpenseaURL:      res.OpenseaURL,
+	}, nil
+}
+
+// Transfer implements MintProvider
+func (v *VerbwireService) Transfer(ctx context.Context, req TransferRequest) (*TransferResult, error) {
+	res, err := v.TransferNFT(ctx, req.ContractAddress, req.TokenID, req.FromAddress, req.ToAddress)
+	if err != nil {
+		return nil, err
 	}
-	
-	return stats, nil
+
+	result := &TransferResult{}
+	if details, ok := (*res)["transaction_details"].(map[string]interface{}); ok {
+		result.TransactionHash, _ = details["transactionHash"].(string)
+	}
+	return result, nil
+}
+
+// Metadata implements MintProvider
+func (v *VerbwireService) Metadata(ctx context.Context, contractAddress, tokenID string) (map[string]interface{}, error) {
+	return v.GetNFTMetadata(ctx, contractAddress, tokenID)
+}
+
+// WalletNFTs implements MintProvider
+func (v *VerbwireService) WalletNFTs(ctx context.Context, walletAddress string) ([]map[string]interface{}, error) {
+	return v.GetNFTsByWallet(ctx, walletAddress)
 }
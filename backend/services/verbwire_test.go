@@ -0,0 +1,463 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"nftgenie/backend/services/verbwiretest"
+)
+
+// testClientConfig disables retries, rate limiting and circuit breaking so
+// each test opts into the behavior it exercises
+func testClientConfig() ClientConfig {
+	return ClientConfig{
+		Name:          "verbwire",
+		Timeout:       5 * time.Second,
+		BaseBackoff:   time.Millisecond,
+		MaxBackoff:    10 * time.Millisecond,
+		MaxRetryAfter: time.Second,
+	}
+}
+
+func newTestVerbwire(t *testing.T) (*VerbwireService, *verbwiretest.Server) {
+	t.Helper()
+	return newTestVerbwireWithConfig(t, testClientConfig())
+}
+
+func newTestVerbwireWithConfig(t *testing.T, config ClientConfig) (*VerbwireService, *verbwiretest.Server) {
+	t.Helper()
+
+	server := verbwiretest.NewServer()
+	server.RetryAfter = "0"
+	t.Cleanup(server.Close)
+
+	return &VerbwireService{
+		APIKey:  verbwiretest.APIKey,
+		BaseURL: server.URL,
+		Chain:   "polygonAmoy",
+		client:  NewResilientClient(config),
+	}, server
+}
+
+func TestQuickMintNFT(t *testing.T) {
+	vw, server := newTestVerbwire(t)
+
+	res, err := vw.QuickMintNFT(context.Background(), MintNFTRequest{
+		Name:             "Genie Lamp #42",
+		Description:      "A lamp",
+		ImageURL:         "https://example.com/lamp.png",
+		RecipientAddress: "0x9c3a6e0b2d4f81a7c5e3b1d9f0a2c4e6b8d0f1a3",
+		Quantity:         1,
+	})
+	if err != nil {
+		t.Fatalf("QuickMintNFT: %v", err)
+	}
+	if res.TokenID != "42" || res.ContractAddress == "" || res.TransactionHash == "" {
+		t.Errorf("unexpected mint response: %+v", res)
+	}
+
+	reqs := server.Requests(verbwiretest.PathQuickMint)
+	if len(reqs) != 1 {
+		t.Fatalf("got %d mint requests, want 1", len(reqs))
+	}
+	form := reqs[0].Form
+	if got := form.Get("chain"); got != "polygonAmoy" {
+		t.Errorf("chain = %q, want polygonAmoy", got)
+	}
+	if got := form.Get("recipientAddress"); got != "0x9c3a6e0b2d4f81a7c5e3b1d9f0a2c4e6b8d0f1a3" {
+		t.Errorf("recipientAddress = %q", got)
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(form.Get("data")), &metadata); err != nil {
+		t.Fatalf("metadata is not JSON: %v", err)
+	}
+	if metadata["name"] != "Genie Lamp #42" || metadata["image"] != "https://example.com/lamp.png" {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+}
+
+func TestQuickMintNFTFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure verbwiretest.Failure
+		wantErr string
+	}{
+		{"rate limited", verbwiretest.RateLimited, "429"},
+		{"server error", verbwiretest.ServerError, "500"},
+		{"malformed json", verbwiretest.MalformedJSON, "failed to parse response"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			vw, server := newTestVerbwire(t)
+			server.FailNext(verbwiretest.PathQuickMint, tc.failure)
+
+			_, err := vw.QuickMintNFT(context.Background(), MintNFTRequest{Name: "n", RecipientAddress: "0x0"})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuickMintNFTInvalidAPIKey(t *testing.T) {
+	vw, _ := newTestVerbwire(t)
+	vw.APIKey = "wrong"
+
+	_, err := vw.QuickMintNFT(context.Background(), MintNFTRequest{Name: "n", RecipientAddress: "0x0"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want 401 error", err)
+	}
+}
+
+func TestGetNFTsByWallet(t *testing.T) {
+	vw, server := newTestVerbwire(t)
+
+	nfts, err := vw.GetNFTsByWallet(context.Background(), "0x9c3a6e0b2d4f81a7c5e3b1d9f0a2c4e6b8d0f1a3")
+	if err != nil {
+		t.Fatalf("GetNFTsByWallet: %v", err)
+	}
+	if len(nfts) != 2 {
+		t.Errorf("got %d NFTs, want 2", len(nfts))
+	}
+
+	query := server.Requests(verbwiretest.PathNFTsByWallet)[0].Query
+	if query.Get("walletAddress") != "0x9c3a6e0b2d4f81a7c5e3b1d9f0a2c4e6b8d0f1a3" || query.Get("chain") != "polygonAmoy" {
+		t.Errorf("unexpected query: %v", query)
+	}
+}
+
+func TestGetNFTMetadata(t *testing.T) {
+	vw, server := newTestVerbwire(t)
+
+	metadata, err := vw.GetNFTMetadata(context.Background(), "0x1b4cc6d6e8f3b0a5c77a2f0e9d1c3b5a79e8f6d4", "42")
+	if err != nil {
+		t.Fatalf("GetNFTMetadata: %v", err)
+	}
+	if _, ok := metadata["nft_details"]; !ok {
+		t.Errorf("missing nft_details in %v", metadata)
+	}
+
+	query := server.Requests(verbwiretest.PathNFTDetails)[0].Query
+	if query.Get("tokenId") != "42" {
+		t.Errorf("tokenId = %q, want 42", query.Get("tokenId"))
+	}
+}
+
+func TestTransferNFT(t *testing.T) {
+	vw, server := newTestVerbwire(t)
+
+	res, err := vw.TransferNFT(context.Background(), "0x1b4cc6d6e8f3b0a5c77a2f0e9d1c3b5a79e8f6d4", "42", "0xfrom", "0xto")
+	if err != nil {
+		t.Fatalf("TransferNFT: %v", err)
+	}
+	if _, ok := (*res)["transaction_details"]; !ok {
+		t.Errorf("missing transaction_details in %v", *res)
+	}
+
+	form := server.Requests(verbwiretest.PathTransfer)[0].Form
+	if form.Get("fromAddress") != "0xfrom" || form.Get("toAddress") != "0xto" || form.Get("tokenId") != "42" {
+		t.Errorf("unexpected form: %v", form)
+	}
+}
+
+func TestGetCollectionStats(t *testing.T) {
+	vw, _ := newTestVerbwire(t)
+
+	stats, err := vw.GetCollectionStats(context.Background(), "0x1b4cc6d6e8f3b0a5c77a2f0e9d1c3b5a79e8f6d4")
+	if err != nil {
+		t.Fatalf("GetCollectionStats: %v", err)
+	}
+	if _, ok := stats["collection_stats"]; !ok {
+		t.Errorf("missing collection_stats in %v", stats)
+	}
+}
+
+func TestReadEndpointsRejectMalformedJSON(t *testing.T) {
+	vw, server := newTestVerbwire(t)
+	server.FailNext(verbwiretest.PathNFTsByWallet, verbwiretest.MalformedJSON)
+	server.FailNext(verbwiretest.PathNFTDetails, verbwiretest.MalformedJSON)
+	server.FailNext(verbwiretest.PathCollectionStats, verbwiretest.MalformedJSON)
+
+	if _, err := vw.GetNFTsByWallet(context.Background(), "0xabc"); err == nil {
+		t.Error("GetNFTsByWallet: expected error for malformed JSON")
+	}
+	if _, err := vw.GetNFTMetadata(context.Background(), "0xabc", "1"); err == nil {
+		t.Error("GetNFTMetadata: expected error for malformed JSON")
+	}
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err == nil {
+		t.Error("GetCollectionStats: expected error for malformed JSON")
+	}
+}
+
+func TestRetriesRateLimitedMint(t *testing.T) {
+	config := testClientConfig()
+	config.MaxRetries = 2
+	vw, server := newTestVerbwireWithConfig(t, config)
+	server.FailNext(verbwiretest.PathQuickMint, verbwiretest.RateLimited, verbwiretest.RateLimited)
+
+	res, err := vw.QuickMintNFT(context.Background(), MintNFTRequest{Name: "n", RecipientAddress: "0x0"})
+	if err != nil {
+		t.Fatalf("QuickMintNFT: %v", err)
+	}
+	if res.TokenID != "42" {
+		t.Errorf("token_id = %q, want 42", res.TokenID)
+	}
+	if got := len(server.Requests(verbwiretest.PathQuickMint)); got != 3 {
+		t.Errorf("got %d mint requests, want 3", got)
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	config := testClientConfig()
+	config.MaxRetries = 1
+	vw, server := newTestVerbwireWithConfig(t, config)
+	server.FailNext(verbwiretest.PathNFTDetails, verbwiretest.ServerError, verbwiretest.ServerError)
+
+	_, err := vw.GetNFTMetadata(context.Background(), "0xabc", "1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 500 {
+		t.Fatalf("err = %v, want 500 APIError", err)
+	}
+	if got := len(server.Requests(verbwiretest.PathNFTDetails)); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestMintNotRetriedOnServerError(t *testing.T) {
+	config := testClientConfig()
+	config.MaxRetries = 3
+	vw, server := newTestVerbwireWithConfig(t, config)
+	server.FailNext(verbwiretest.PathQuickMint, verbwiretest.ServerError)
+
+	if _, err := vw.QuickMintNFT(context.Background(), MintNFTRequest{Name: "n", RecipientAddress: "0x0"}); err == nil {
+		t.Fatal("expected error")
+	}
+	// A 500 may come after the mint went through, so it must not be repeated
+	if got := len(server.Requests(verbwiretest.PathQuickMint)); got != 1 {
+		t.Errorf("got %d mint requests, want 1", got)
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	config := testClientConfig()
+	config.MaxRetries = 3
+	vw, server := newTestVerbwireWithConfig(t, config)
+	server.RetryAfter = "120"
+	server.FailNext(verbwiretest.PathCollectionStats, verbwiretest.RateLimited)
+
+	start := time.Now()
+	_, err := vw.GetCollectionStats(context.Background(), "0xabc")
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("err = %v, want 429 error", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("waited %v for a Retry-After beyond the cap", time.Since(start))
+	}
+}
+
+func TestSlowResponseTimesOut(t *testing.T) {
+	config := testClientConfig()
+	config.Timeout = 50 * time.Millisecond
+	vw, server := newTestVerbwireWithConfig(t, config)
+	server.SlowDelay = time.Second
+	server.FailNext(verbwiretest.PathNFTsByWallet, verbwiretest.Slow)
+
+	start := time.Now()
+	_, err := vw.GetNFTsByWallet(context.Background(), "0xabc")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("call took %v, want it cut off by the timeout", time.Since(start))
+	}
+}
+
+func TestSlowResponseRetried(t *testing.T) {
+	config := testClientConfig()
+	config.Timeout = 50 * time.Millisecond
+	config.MaxRetries = 1
+	vw, server := newTestVerbwireWithConfig(t, config)
+	server.SlowDelay = time.Second
+	server.FailNext(verbwiretest.PathNFTsByWallet, verbwiretest.Slow)
+
+	nfts, err := vw.GetNFTsByWallet(context.Background(), "0xabc")
+	if err != nil {
+		t.Fatalf("GetNFTsByWallet: %v", err)
+	}
+	if len(nfts) != 2 {
+		t.Errorf("got %d NFTs, want 2", len(nfts))
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	config := testClientConfig()
+	config.FailureThreshold = 2
+	config.Cooldown = 50 * time.Millisecond
+	vw, server := newTestVerbwireWithConfig(t, config)
+	server.FailNext(verbwiretest.PathCollectionStats, verbwiretest.ServerError, verbwiretest.ServerError, verbwiretest.ServerError)
+
+	for i := 0; i < 2; i++ {
+		if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err == nil {
+			t.Fatalf("call %d: expected error", i)
+		}
+	}
+
+	_, err := vw.GetCollectionStats(context.Background(), "0xabc")
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want CircuitOpenError", err)
+	}
+	if got := len(server.Requests(verbwiretest.PathCollectionStats)); got != 2 {
+		t.Errorf("got %d requests, want 2 while open", got)
+	}
+
+	// After the cooldown a failing probe reopens the breaker
+	time.Sleep(60 * time.Millisecond)
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); errors.Is(err, ErrCircuitOpen) || err == nil {
+		t.Fatalf("probe err = %v, want upstream error", err)
+	}
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want breaker reopened", err)
+	}
+
+	// A successful probe closes it again
+	time.Sleep(60 * time.Millisecond)
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err != nil {
+		t.Fatalf("after close: %v", err)
+	}
+}
+
+func TestClientErrorsDoNotTripBreaker(t *testing.T) {
+	config := testClientConfig()
+	config.FailureThreshold = 1
+	vw, _ := newTestVerbwireWithConfig(t, config)
+	vw.APIKey = "wrong"
+
+	for i := 0; i < 3; i++ {
+		_, err := vw.GetCollectionStats(context.Background(), "0xabc")
+		if errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: breaker opened on a 401", i)
+		}
+	}
+}
+
+func TestCanceledCallsDoNotAffectBreaker(t *testing.T) {
+	config := testClientConfig()
+	config.FailureThreshold = 2
+	config.Cooldown = 50 * time.Millisecond
+	vw, server := newTestVerbwireWithConfig(t, config)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A cancellation between two failures neither resets the count...
+	server.FailNext(verbwiretest.PathCollectionStats, verbwiretest.ServerError, verbwiretest.ServerError)
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := vw.GetCollectionStats(canceled, "0xabc"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want canceled", err)
+	}
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want breaker open after two failures", err)
+	}
+
+	// ...nor closes the breaker when the probe is cancelled, and the next
+	// call may probe again
+	time.Sleep(60 * time.Millisecond)
+	if _, err := vw.GetCollectionStats(canceled, "0xabc"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("probe err = %v, want canceled", err)
+	}
+	server.FailNext(verbwiretest.PathCollectionStats, verbwiretest.ServerError)
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe err = %v, want upstream error", err)
+	}
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want breaker reopened", err)
+	}
+}
+
+func TestRateLimiterSpacesRequests(t *testing.T) {
+	config := testClientConfig()
+	config.RateLimit = 20
+	config.Burst = 1
+	vw, _ := newTestVerbwireWithConfig(t, config)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err != nil {
+			t.Fatalf("GetCollectionStats: %v", err)
+		}
+	}
+	// One token up front, then one every 50ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 100ms at 20 req/s", elapsed)
+	}
+}
+
+func TestCallerContextCancelsWait(t *testing.T) {
+	config := testClientConfig()
+	config.RateLimit = 0.1
+	config.Burst = 1
+	vw, _ := newTestVerbwireWithConfig(t, config)
+
+	if _, err := vw.GetCollectionStats(context.Background(), "0xabc"); err != nil {
+		t.Fatalf("GetCollectionStats: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := vw.GetCollectionStats(ctx, "0xabc"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded while waiting for a token", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %v", got)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 55*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v", date, got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("parseRetryAfter(soon) = %v", got)
+	}
+}
+
+func TestTransactionReceipt(t *testing.T) {
+	vw, server := newTestVerbwire(t)
+	hash := "0x8f6b1e3c2a4d5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8"
+
+	receipt, err := vw.TransactionReceipt(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("TransactionReceipt: %v", err)
+	}
+	if receipt.BlockNumber != 6137731 || !receipt.Success || receipt.Confirmations != 24 {
+		t.Errorf("unexpected receipt: %+v", receipt)
+	}
+	if receipt.GasFee <= 0.0055 || receipt.GasFee >= 0.0056 {
+		t.Errorf("gas fee = %v, want about 0.00554", receipt.GasFee)
+	}
+	if got := server.Requests(verbwiretest.PathTxDetails)[0].Query.Get("transactionHash"); got != hash {
+		t.Errorf("transactionHash = %q", got)
+	}
+
+	server.SetResponse(verbwiretest.PathTxDetails, []byte(`{"transaction_details": {"hash": "0xabc", "blockNumber": null}}`))
+	pending, err := vw.TransactionReceipt(context.Background(), "0xabc")
+	if err != nil || pending != nil {
+		t.Errorf("pending transaction: receipt = %+v, err = %v", pending, err)
+	}
+}
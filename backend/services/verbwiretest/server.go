@@ -0,0 +1,200 @@
+// Package verbwiretest provides an in-process fake of the Verbwire API for
+// tests. It replays recorded JSON responses and can inject failures.
+package verbwiretest
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed fixtures/*.json
+var fixtureFiles embed.FS
+
+// Verbwire endpoints served by the fake, relative to the base URL
+const (
+	PathQuickMint       = "/nft/mint/quickMintFromMetadata"
+	PathNFTsByWallet    = "/data/nftsByWalletAddress"
+	PathNFTDetails      = "/nft/data/nftDetails"
+	PathTransfer        = "/nft/transfer"
+	PathCollectionStats = "/nft/data/collectionStatistics"
+	PathTxDetails       = "/nft/data/transactionDetails"
+)
+
+// APIKey is the key the fake accepts in the X-API-Key header
+const APIKey = "test-verbwire-api-key"
+
+// Failure is a simulated failure mode for the next request to an endpoint
+type Failure int
+
+const (
+	// RateLimited responds 429 with a Retry-After header
+	RateLimited Failure = iota + 1
+	// ServerError responds 500
+	ServerError
+	// MalformedJSON responds 200 with a truncated JSON body
+	MalformedJSON
+	// Slow delays the response by SlowDelay before replaying the fixture
+	Slow
+)
+
+// routes maps each endpoint to its method and recorded fixture
+var routes = map[string]struct {
+	method  string
+	fixture string
+}{
+	PathQuickMint:       {http.MethodPost, "quick_mint.json"},
+	PathNFTsByWallet:    {http.MethodGet, "nfts_by_wallet.json"},
+	PathNFTDetails:      {http.MethodGet, "nft_details.json"},
+	PathTransfer:        {http.MethodPost, "transfer.json"},
+	PathCollectionStats: {http.MethodGet, "collection_stats.json"},
+	PathTxDetails:       {http.MethodGet, "transaction_details.json"},
+}
+
+// Request is a request received by the fake
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Form   url.Values
+	Header http.Header
+}
+
+// Server is a fake Verbwire API backed by httptest
+type Server struct {
+	*httptest.Server
+
+	// SlowDelay is how long a Slow failure waits before responding
+	SlowDelay time.Duration
+	// RetryAfter is the Retry-After value sent with RateLimited failures
+	RetryAfter string
+
+	mu        sync.Mutex
+	failures  map[string][]Failure
+	overrides map[string][]byte
+	requests  []Request
+}
+
+// NewServer starts a fake Verbwire server; callers must Close it
+func NewServer() *Server {
+	s := &Server{
+		SlowDelay:  2 * time.Second,
+		RetryAfter: "1",
+		failures:   make(map[string][]Failure),
+		overrides:  make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// FailNext queues failures for the next requests to path, in order
+func (s *Server) FailNext(path string, failures ...Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], failures...)
+}
+
+// SetResponse replaces the recorded response for path
+func (s *Server) SetResponse(path string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[path] = body
+}
+
+// Requests returns the requests received for path
+func (s *Server) Requests(path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []Request
+	for _, r := range s.requests {
+		if r.Path == path {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// Fixture returns a recorded response by file name
+func Fixture(name string) []byte {
+	b, err := fixtureFiles.ReadFile("fixtures/" + name)
+	if err != nil {
+		panic("verbwiretest: unknown fixture " + name)
+	}
+	return b
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	route, ok := routes[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != route.method {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	recorded := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(1 << 20); err == nil {
+			recorded.Form = r.MultipartForm.Value
+		}
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, recorded)
+	var failure Failure
+	if queued := s.failures[r.URL.Path]; len(queued) > 0 {
+		failure = queued[0]
+		s.failures[r.URL.Path] = queued[1:]
+	}
+	body, overridden := s.overrides[r.URL.Path]
+	s.mu.Unlock()
+
+	if !overridden {
+		body = Fixture(route.fixture)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Header.Get("X-API-Key") != APIKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(Fixture("unauthorized.json"))
+		return
+	}
+
+	switch failure {
+	case RateLimited:
+		w.Header().Set("Retry-After", s.RetryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(Fixture("rate_limited.json"))
+		return
+	case ServerError:
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(Fixture("server_error.json"))
+		return
+	case MalformedJSON:
+		w.WriteHeader(http.StatusOK)
+		w.Write(body[:len(body)/2])
+		return
+	case Slow:
+		select {
+		case <-time.After(s.SlowDelay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
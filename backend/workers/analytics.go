@@ -0,0 +1,77 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"nftgenie/backend/config"
+	"nftgenie/backend/repository"
+	"time"
+)
+
+// AnalyticsRollup fills the analytics table with one row per UTC day. Each
+// run recomputes the latest stored day, which may have been rolled up before
+// it ended, through today, so days missed while the backend was down are
+// filled in too, up to Backfill days back.
+type AnalyticsRollup struct {
+	Interval time.Duration
+	Backfill int // days
+
+	analytics *repository.AnalyticsRepository
+}
+
+// NewAnalyticsRollup creates a rollup configured from the ANALYTICS_*
+// environment
+func NewAnalyticsRollup() *AnalyticsRollup {
+	return &AnalyticsRollup{
+		Interval:  config.Duration("ANALYTICS_ROLLUP_INTERVAL", time.Hour),
+		Backfill:  config.Int("ANALYTICS_BACKFILL_DAYS", 30),
+		analytics: repository.NewAnalyticsRepository(),
+	}
+}
+
+// Start rolls up every Interval until ctx is cancelled
+func (a *AnalyticsRollup) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(a.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := a.RunOnce(time.Now()); err != nil {
+				log.Printf("analytics rollup: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce rolls up the days from the latest stored one through now's day
+func (a *AnalyticsRollup) RunOnce(now time.Time) error {
+	today := truncateDay(now)
+	from := today.AddDate(0, 0, -(a.Backfill - 1))
+
+	latest, err := a.analytics.LatestDate()
+	if err != nil {
+		return err
+	}
+	if latest != nil && truncateDay(*latest).After(from) {
+		from = truncateDay(*latest)
+	}
+
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if _, err := a.analytics.Rollup(day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateDay returns the start of t's UTC day
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
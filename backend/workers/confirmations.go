@@ -0,0 +1,202 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"nftgenie/backend/cache"
+	"nftgenie/backend/config"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"nftgenie/backend/repository"
+	"nftgenie/backend/services"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ConfirmationTracker follows pending chain transactions until they have
+// enough confirmations, then settles them and the NFT, mint job and listing
+// they belong to. Confirmed transactions are re-checked for ReorgWindow and
+// reopened when their block is no longer canonical.
+type ConfirmationTracker struct {
+	Confirmations  int64
+	PollInterval   time.Duration
+	ReorgWindow    time.Duration
+	PendingTimeout time.Duration
+	BatchSize      int
+	Cache          *cache.Cache // drops the reads of NFTs whose state changes
+
+	source       services.ReceiptSource
+	transactions *repository.TransactionRepository
+}
+
+// NewConfirmationTracker creates a tracker configured from the CONFIRMATION*
+// environment
+func NewConfirmationTracker(source services.ReceiptSource) *ConfirmationTracker {
+	return &ConfirmationTracker{
+		Confirmations:  int64(config.Int("CONFIRMATIONS_REQUIRED", 12)),
+		PollInterval:   config.Duration("CONFIRMATION_POLL_INTERVAL", 15*time.Second),
+		ReorgWindow:    config.Duration("CONFIRMATION_REORG_WINDOW", time.Hour),
+		PendingTimeout: config.Duration("CONFIRMATION_PENDING_TIMEOUT", 24*time.Hour),
+		BatchSize:      config.Int("CONFIRMATION_BATCH_SIZE", 100),
+		source:         source,
+		transactions:   repository.NewTransactionRepository(),
+	}
+}
+
+// Start polls until ctx is cancelled
+func (t *ConfirmationTracker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(t.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := t.RunOnce(ctx); err != nil {
+				log.Printf("confirmation tracker: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce checks one batch of pending transactions and re-checks recently
+// confirmed ones. Lookup failures of single transactions are logged and
+// retried on the next run.
+func (t *ConfirmationTracker) RunOnce(ctx context.Context) error {
+	pending, err := t.transactions.GetPending(t.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, tx := range pending {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := t.check(ctx, tx); err != nil {
+			log.Printf("confirmation tracker: transaction %s: %v", tx.ID, err)
+		}
+	}
+
+	confirmed, err := t.transactions.GetConfirmedSince(time.Now().Add(-t.ReorgWindow), t.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, tx := range confirmed {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := t.recheck(ctx, tx); err != nil {
+			log.Printf("confirmation tracker: transaction %s: %v", tx.ID, err)
+		}
+	}
+	return nil
+}
+
+// check records the receipt of a pending transaction and settles it once it
+// is deep enough
+func (t *ConfirmationTracker) check(ctx context.Context, tx *models.Transaction) error {
+	receipt, err := t.source.TransactionReceipt(ctx, *tx.TransactionHash)
+	if err != nil {
+		return err
+	}
+
+	if receipt == nil {
+		if tx.BlockNumber != nil {
+			// Its block was reorganised away; wait for it to be mined again
+			log.Printf("confirmation tracker: transaction %s left block %d", tx.ID, *tx.BlockNumber)
+			return t.transactions.ClearReceipt(tx.ID)
+		}
+		if time.Since(tx.CreatedAt) > t.PendingTimeout {
+			log.Printf("confirmation tracker: transaction %s not mined after %s", tx.ID, t.PendingTimeout)
+			return t.finalize(tx, models.TransactionFailed)
+		}
+		return nil
+	}
+
+	if err := t.transactions.RecordReceipt(tx.ID, receipt.BlockNumber, receipt.BlockHash, receipt.GasFee); err != nil {
+		return err
+	}
+	if receipt.Confirmations < t.Confirmations {
+		return nil
+	}
+
+	status := models.TransactionConfirmed
+	if !receipt.Success {
+		status = models.TransactionFailed
+	}
+	return t.finalize(tx, status)
+}
+
+// recheck reopens a confirmed transaction whose block is no longer canonical
+func (t *ConfirmationTracker) recheck(ctx context.Context, tx *models.Transaction) error {
+	receipt, err := t.source.TransactionReceipt(ctx, *tx.TransactionHash)
+	if err != nil {
+		return err
+	}
+	if receipt != nil && (tx.BlockHash == nil || receipt.BlockHash == *tx.BlockHash) {
+		return nil
+	}
+
+	log.Printf("confirmation tracker: transaction %s reorganised, reopening", tx.ID)
+	defer t.Cache.InvalidateNFTs(ctx, tx.NFTID)
+	return database.Transaction(func(sqlTx *sqlx.Tx) error {
+		reopened, err := t.transactions.WithTx(sqlTx).Reopen(tx.ID)
+		if err != nil || !reopened || tx.Type != "mint" {
+			return err
+		}
+		if err := repository.NewNFTRepository().WithTx(sqlTx).UpdateMintStatus(tx.NFTID, models.TransactionPending); err != nil {
+			return err
+		}
+		return repository.NewMintJobRepository().WithTx(sqlTx).SetStatusByNFT(tx.NFTID, models.MintJobSubmitted, "")
+	})
+}
+
+// finalize settles a pending transaction and applies the outcome to the
+// records that depend on it
+func (t *ConfirmationTracker) finalize(tx *models.Transaction, status string) error {
+	defer t.Cache.InvalidateNFTs(context.Background(), tx.NFTID)
+	return database.Transaction(func(sqlTx *sqlx.Tx) error {
+		settled, err := t.transactions.WithTx(sqlTx).Finalize(tx.ID, status)
+		if err != nil || !settled {
+			return err
+		}
+
+		nfts := repository.NewNFTRepository().WithTx(sqlTx)
+		listings := repository.NewMarketplaceRepository().WithTx(sqlTx)
+
+		switch tx.Type {
+		case "mint":
+			if err := nfts.UpdateMintStatus(tx.NFTID, status); err != nil {
+				return err
+			}
+			jobs := repository.NewMintJobRepository().WithTx(sqlTx)
+			if status == models.TransactionConfirmed {
+				return jobs.SetStatusByNFT(tx.NFTID, models.MintJobConfirmed, "")
+			}
+			if err := jobs.SetStatusByNFT(tx.NFTID, models.MintJobFailed, "mint transaction failed on chain"); err != nil {
+				return err
+			}
+			// A token that was never minted can't be sold
+			return listings.CancelByNFT(tx.NFTID)
+
+		case "purchase":
+			if status == models.TransactionConfirmed || tx.FromUserID == nil || tx.ToUserID == nil {
+				return nil
+			}
+			// The payment never went through: undo the sale, dropping any
+			// listing the buyer created in the meantime
+			if err := listings.CancelByNFT(tx.NFTID); err != nil {
+				return err
+			}
+			if err := listings.RevertSale(tx.NFTID, *tx.ToUserID); err != nil {
+				return err
+			}
+			return nfts.UpdateOwner(tx.NFTID, *tx.FromUserID)
+		}
+		return nil
+	})
+}
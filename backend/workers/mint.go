@@ -0,0 +1,263 @@
+// Package workers runs the backend's background jobs
+package workers
+
+import (
+	"context"
+	"log"
+	"nftgenie/backend/cache"
+	"nftgenie/backend/config"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"nftgenie/backend/repository"
+	"nftgenie/backend/services"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MintWorker processes queued mint jobs with a pool of goroutines. Jobs live
+// in the mint_jobs table, so pending work survives a restart: expired leases
+// are claimed again on the next poll.
+//
+// A job reclaimed after a crash during the provider call is minted again, as
+// there is no way to know whether the upstream accepted the first attempt.
+// Saving the provider's result is retried until the lease runs out so that a
+// brief database outage does not lead to the same.
+type MintWorker struct {
+	Workers      int
+	PollInterval time.Duration
+	Lease        time.Duration
+	MaxAttempts  int
+	MaxBackoff   time.Duration
+	Cache        *cache.Cache // drops the marketplace stats a new NFT changes
+
+	provider services.MintProvider
+	jobs     *repository.MintJobRepository
+	wake     chan struct{}
+}
+
+// NewMintWorker creates a mint worker configured from the MINT_* environment
+func NewMintWorker(provider services.MintProvider) *MintWorker {
+	workers := config.Int("MINT_WORKERS", 4)
+	return &MintWorker{
+		Workers:      workers,
+		PollInterval: config.Duration("MINT_JOB_POLL_INTERVAL", 2*time.Second),
+		Lease:        config.Duration("MINT_JOB_LEASE", 5*time.Minute),
+		MaxAttempts:  config.Int("MINT_JOB_MAX_ATTEMPTS", 5),
+		MaxBackoff:   config.Duration("MINT_JOB_MAX_BACKOFF", 5*time.Minute),
+		provider:     provider,
+		jobs:         repository.NewMintJobRepository(),
+		wake:         make(chan struct{}, workers),
+	}
+}
+
+// Start runs the worker pool until ctx is cancelled
+func (w *MintWorker) Start(ctx context.Context) {
+	for i := 0; i < w.Workers; i++ {
+		go w.loop(ctx)
+	}
+}
+
+// Notify wakes an idle worker to pick up a newly queued job
+func (w *MintWorker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunOnce claims and processes a single job. It reports whether a job was
+// found.
+func (w *MintWorker) RunOnce(ctx context.Context) (bool, error) {
+	// Measured before claiming so the worker never outlives the lease
+	leaseEnd := time.Now().Add(w.Lease)
+	job, err := w.jobs.ClaimNext(w.Lease)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	w.process(ctx, job, leaseEnd)
+	return true, nil
+}
+
+// loop processes jobs until none are runnable, then waits for a poll or a
+// notification
+func (w *MintWorker) loop(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			found, err := w.RunOnce(ctx)
+			if err != nil {
+				log.Printf("mint worker: claiming job: %v", err)
+			}
+			if !found || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// process mints a queued job and records the NFT for a minted one
+func (w *MintWorker) process(ctx context.Context, job *models.MintJob, leaseEnd time.Time) {
+	if job.Status == models.MintJobQueued {
+		if !w.mint(ctx, job, leaseEnd) {
+			return
+		}
+	}
+
+	if err := w.record(job); err != nil {
+		// The token is minted and its hash is on the job; only the NFT row
+		// is missing, so keep retrying without minting again
+		log.Printf("mint job %s: recording NFT: %v", job.ID, err)
+		w.retry(job, err)
+	}
+}
+
+// mint calls the provider and stores its result on the job. It reports
+// whether the job was submitted.
+func (w *MintWorker) mint(ctx context.Context, job *models.MintJob, leaseEnd time.Time) bool {
+	description := ""
+	if job.Description != nil {
+		description = *job.Description
+	}
+
+	res, err := w.provider.Mint(ctx, services.MintNFTRequest{
+		Name:             job.Name,
+		Description:      description,
+		ImageURL:         job.ImageURL,
+		RecipientAddress: job.RecipientAddress,
+		Chain:            w.provider.ChainName(),
+		Quantity:         1,
+	})
+	if err != nil {
+		log.Printf("mint job %s: %v", job.ID, err)
+		if services.IsTemporary(err) && job.Attempts < w.MaxAttempts {
+			w.retry(job, err)
+		} else if err := w.jobs.Fail(job.ID, err.Error()); err != nil {
+			log.Printf("mint job %s: marking failed: %v", job.ID, err)
+		}
+		return false
+	}
+
+	provider := w.provider.ProviderName()
+	chain := w.provider.ChainName()
+	job.Status = models.MintJobSubmitted
+	job.Provider = &provider
+	job.Chain = &chain
+	job.TransactionHash = &res.TransactionHash
+	job.ContractAddress = &res.ContractAddress
+	job.TokenID = &res.TokenID
+
+	if err := w.markSubmitted(ctx, job, leaseEnd); err != nil {
+		// Once the lease expires the job is claimed and minted again
+		log.Printf("mint job %s: recording result %s, giving up: %v", job.ID, res.TransactionHash, err)
+		return false
+	}
+	return true
+}
+
+// markSubmitted saves the provider's result, retrying with a backoff while
+// the lease lasts. The token is already minted, so the provider is not called
+// again.
+func (w *MintWorker) markSubmitted(ctx context.Context, job *models.MintJob, leaseEnd time.Time) error {
+	delay := markSubmittedBackoff
+	for {
+		err := w.jobs.MarkSubmitted(job)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(delay).After(leaseEnd) {
+			return err
+		}
+		log.Printf("mint job %s: recording result %s, retrying in %v: %v", job.ID, *job.TransactionHash, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// record creates the NFT and its mint transaction and links them to the job
+func (w *MintWorker) record(job *models.MintJob) error {
+	nft := models.NewNFT(job.Name, job.ImageURL, job.CreatorID, job.CreatorID)
+	nft.Description = job.Description
+	nft.CollectionID = job.CollectionID
+	nft.ContractAddress = job.ContractAddress
+	nft.TokenID = job.TokenID
+	nft.TransactionHash = job.TransactionHash
+	if job.Chain != nil {
+		nft.Chain = *job.Chain
+	}
+	now := time.Now()
+	nft.MintedAt = &now
+	if len(job.Tags) > 0 {
+		nft.Tags = job.Tags
+	}
+
+	transaction := models.NewTransaction("mint", nft.ID)
+	transaction.ToUserID = &job.CreatorID
+	transaction.TransactionHash = job.TransactionHash
+
+	// The confirmation tracker settles the mint once it has a hash to follow;
+	// without one there is nothing to wait for
+	tracked := job.TransactionHash != nil && *job.TransactionHash != ""
+	if tracked {
+		nft.MintStatus = models.TransactionPending
+	} else {
+		transaction.TransactionHash = nil
+		transaction.Status = models.TransactionConfirmed
+	}
+
+	err := database.Transaction(func(tx *sqlx.Tx) error {
+		if err := repository.NewNFTRepository().WithTx(tx).Create(nft); err != nil {
+			return err
+		}
+		if err := repository.NewTransactionRepository().WithTx(tx).Create(transaction); err != nil {
+			return err
+		}
+		jobs := w.jobs.WithTx(tx)
+		if err := jobs.LinkNFT(job.ID, nft.ID); err != nil {
+			return err
+		}
+		if !tracked {
+			return jobs.SetStatusByNFT(nft.ID, models.MintJobConfirmed, "")
+		}
+		return nil
+	})
+	if err == nil {
+		w.Cache.Delete(context.Background(), cache.StatsKey)
+	}
+	return err
+}
+
+// retry releases the job with an exponential backoff
+func (w *MintWorker) retry(job *models.MintJob, cause error) {
+	runAfter := time.Now().Add(retryBackoff(job.Attempts, w.MaxBackoff))
+	if err := w.jobs.Retry(job.ID, cause.Error(), runAfter); err != nil {
+		log.Printf("mint job %s: scheduling retry: %v", job.ID, err)
+	}
+}
+
+// markSubmittedBackoff is the first delay before saving a provider result again
+const markSubmittedBackoff = 250 * time.Millisecond
+
+// retryBackoff doubles the delay with each attempt up to max
+func retryBackoff(attempts int, max time.Duration) time.Duration {
+	backoff := time.Duration(1<<uint(min(attempts, 16))) * time.Second
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
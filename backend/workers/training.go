@@ -0,0 +1,270 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"nftgenie/backend/config"
+	"nftgenie/backend/database"
+	"nftgenie/backend/models"
+	"nftgenie/backend/repository"
+	"nftgenie/backend/services"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TrainingWorker runs queued recommendation trainings. A job exports a
+// snapshot of the training data, triggers the ai-engine's /train and is
+// completed once the engine reports a newer model, which is added to the
+// model registry.
+//
+// The engine reads its training data from the database itself; the snapshot
+// is an audit record of what the data held when the job ran, kept in
+// DatasetDir for DatasetRetention.
+type TrainingWorker struct {
+	PollInterval     time.Duration
+	Lease            time.Duration
+	Timeout          time.Duration // how long the engine may take to train
+	MaxAttempts      int
+	MaxBackoff       time.Duration
+	DatasetDir       string
+	DatasetRetention time.Duration
+
+	engine        *services.AIEngineClient
+	jobs          *repository.TrainingJobRepository
+	modelVersions *repository.ModelVersionRepository
+	wake          chan struct{}
+}
+
+// NewTrainingWorker creates a training worker configured from the TRAINING_*
+// environment
+func NewTrainingWorker(engine *services.AIEngineClient) *TrainingWorker {
+	return &TrainingWorker{
+		PollInterval:     config.Duration("TRAINING_POLL_INTERVAL", 30*time.Second),
+		Lease:            config.Duration("TRAINING_JOB_LEASE", 10*time.Minute),
+		Timeout:          config.Duration("TRAINING_TIMEOUT", time.Hour),
+		MaxAttempts:      config.Int("TRAINING_JOB_MAX_ATTEMPTS", 5),
+		MaxBackoff:       config.Duration("TRAINING_JOB_MAX_BACKOFF", 10*time.Minute),
+		DatasetDir:       config.String("TRAINING_DATASET_DIR", filepath.Join(os.TempDir(), "nftgenie-training")),
+		DatasetRetention: config.Duration("TRAINING_DATASET_RETENTION", 30*24*time.Hour),
+		engine:           engine,
+		jobs:             repository.NewTrainingJobRepository(),
+		modelVersions:    repository.NewModelVersionRepository(),
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+// Start polls until ctx is cancelled
+func (w *TrainingWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.RunOnce(ctx); err != nil {
+				log.Printf("training worker: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-w.wake:
+			}
+		}
+	}()
+}
+
+// Notify wakes the worker to pick up a newly queued job
+func (w *TrainingWorker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunOnce settles the trainings the engine finished, then starts the next
+// queued job
+func (w *TrainingWorker) RunOnce(ctx context.Context) error {
+	if err := w.checkTraining(ctx); err != nil {
+		return err
+	}
+
+	job, err := w.jobs.ClaimNext(w.Lease)
+	if err != nil || job == nil {
+		return err
+	}
+	w.start(ctx, job)
+	return nil
+}
+
+// start exports the dataset and triggers the engine's training
+func (w *TrainingWorker) start(ctx context.Context, job *models.TrainingJob) {
+	health, err := w.engine.Health(ctx)
+	if err != nil {
+		w.retry(job, fmt.Errorf("ai-engine unavailable: %w", err))
+		return
+	}
+
+	if err := w.export(job); err != nil {
+		w.retry(job, fmt.Errorf("exporting dataset: %w", err))
+		return
+	}
+	if err := w.pruneDatasets(); err != nil {
+		log.Printf("training job %s: pruning old datasets: %v", job.ID, err)
+	}
+	job.PreviousTrainedAt = health.LastTrained
+
+	res, err := w.engine.Train(ctx, job.ForceRetrain)
+	if err != nil {
+		w.retry(job, fmt.Errorf("triggering training: %w", err))
+		return
+	}
+	job.Message = &res.Message
+
+	err = database.Transaction(func(tx *sqlx.Tx) error {
+		jobs := w.jobs.WithTx(tx)
+		if err := jobs.MarkTraining(job); err != nil {
+			return err
+		}
+		if res.Status != "skipped" {
+			return nil
+		}
+		// The engine keeps serving its recent model
+		model := health.Model()
+		if err := w.modelVersions.WithTx(tx).Register(model); err != nil {
+			return err
+		}
+		return jobs.Complete(job.ID, model.Version, "")
+	})
+	if err != nil {
+		log.Printf("training job %s: recording trigger: %v", job.ID, err)
+	}
+}
+
+// export writes the job's dataset snapshot to DatasetDir and records where
+// it is and what it holds on the job
+func (w *TrainingWorker) export(job *models.TrainingJob) error {
+	var dataset *models.TrainingDataset
+	err := database.Transaction(func(tx *sqlx.Tx) error {
+		var err error
+		dataset, err = w.jobs.WithTx(tx).ExportDataset()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(dataset)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(w.DatasetDir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(w.DatasetDir, job.ID.String()+".json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+
+	interactions, nfts, preferences := len(dataset.Interactions), len(dataset.NFTs), len(dataset.Preferences)
+	job.DatasetPath = &path
+	job.InteractionCount = &interactions
+	job.NFTCount = &nfts
+	job.PreferenceCount = &preferences
+	return nil
+}
+
+// pruneDatasets deletes the snapshots older than DatasetRetention
+func (w *TrainingWorker) pruneDatasets() error {
+	entries, err := os.ReadDir(w.DatasetDir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-w.DatasetRetention)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(w.DatasetDir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkTraining completes the running jobs once the engine reports a model
+// trained after they were triggered, and fails those that took too long
+func (w *TrainingWorker) checkTraining(ctx context.Context) error {
+	running, err := w.jobs.GetTraining()
+	if err != nil || len(running) == 0 {
+		return err
+	}
+
+	health, healthErr := w.engine.Health(ctx)
+	for _, job := range running {
+		if healthErr == nil && trainedSince(health, job) {
+			model := health.Model()
+			model.TrainingJobID = &job.ID
+			err := database.Transaction(func(tx *sqlx.Tx) error {
+				if err := w.modelVersions.WithTx(tx).Register(model); err != nil {
+					return err
+				}
+				return w.jobs.WithTx(tx).Complete(job.ID, model.Version, "")
+			})
+			if err != nil {
+				log.Printf("training job %s: recording model %s: %v", job.ID, model.Version, err)
+			}
+			continue
+		}
+
+		if job.TriggeredAt != nil && time.Since(*job.TriggeredAt) > w.Timeout {
+			message := fmt.Sprintf("ai-engine did not finish training within %s", w.Timeout)
+			if err := w.jobs.Fail(job.ID, message); err != nil {
+				log.Printf("training job %s: marking failed: %v", job.ID, err)
+			}
+		}
+	}
+	if healthErr != nil {
+		return fmt.Errorf("checking ai-engine: %w", healthErr)
+	}
+	return nil
+}
+
+// trainedSince reports whether the engine trained a model after the job
+// triggered it
+func trainedSince(health *services.AIEngineHealth, job *models.TrainingJob) bool {
+	if health.LastTrained == nil {
+		return false
+	}
+	return job.PreviousTrainedAt == nil || *health.LastTrained != *job.PreviousTrainedAt
+}
+
+// retry releases the job with an exponential backoff, failing it once it
+// used up its attempts
+func (w *TrainingWorker) retry(job *models.TrainingJob, cause error) {
+	log.Printf("training job %s: %v", job.ID, cause)
+
+	if job.Attempts >= w.MaxAttempts {
+		if err := w.jobs.Fail(job.ID, cause.Error()); err != nil {
+			log.Printf("training job %s: marking failed: %v", job.ID, err)
+		}
+		return
+	}
+
+	runAfter := time.Now().Add(retryBackoff(job.Attempts, w.MaxBackoff))
+	if err := w.jobs.Retry(job.ID, cause.Error(), runAfter); err != nil {
+		log.Printf("training job %s: scheduling retry: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"nftgenie/backend/cache"
+	"nftgenie/backend/config"
+	"nftgenie/backend/database"
+	"nftgenie/backend/repository"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TrendingRefresher recomputes the trending ranks of every window on a
+// schedule, so requests read precomputed ranks instead of scoring all NFTs
+type TrendingRefresher struct {
+	Interval time.Duration
+	Scoring  repository.TrendingScoring
+	Cache    *cache.Cache // drops a window's cached ranks once refreshed
+
+	trending *repository.TrendingRepository
+}
+
+// NewTrendingRefresher creates a refresher configured from the TRENDING_*
+// environment
+func NewTrendingRefresher() *TrendingRefresher {
+	return &TrendingRefresher{
+		Interval: config.Duration("TRENDING_REFRESH_INTERVAL", 5*time.Minute),
+		Scoring: repository.TrendingScoring{
+			Gravity:      config.Float("TRENDING_GRAVITY", 1.8),
+			VolumeWeight: config.Float("TRENDING_VOLUME_WEIGHT", 10),
+			Size:         config.Int("TRENDING_SIZE", 100),
+		},
+		trending: repository.NewTrendingRepository(),
+	}
+}
+
+// Start refreshes every Interval until ctx is cancelled
+func (r *TrendingRefresher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := r.RunOnce(); err != nil {
+				log.Printf("trending refresher: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce recomputes every window, each in its own transaction
+func (r *TrendingRefresher) RunOnce() error {
+	for _, window := range repository.TrendingWindows {
+		err := database.Transaction(func(tx *sqlx.Tx) error {
+			return r.trending.WithTx(tx).Refresh(window, r.Scoring)
+		})
+		if err != nil {
+			return err
+		}
+		r.Cache.Delete(context.Background(), cache.TrendingKey(window.Period))
+	}
+	return nil
+}
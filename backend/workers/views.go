@@ -0,0 +1,201 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"nftgenie/backend/config"
+	"nftgenie/backend/database"
+	"nftgenie/backend/repository"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ViewPipeline buffers NFT view events and writes them in batches. A viewer,
+// a wallet or client address, counts once per NFT per Window; views by known
+// wallets also become view interactions.
+//
+// The dedupe state lives in memory, so each backend instance dedupes on its
+// own and a restart forgets who viewed what. It holds at most MaxViewers
+// viewers; past that the longest-remembered are forgotten early, so they may
+// count again within the window. Views buffered when the process dies
+// without a final Flush are lost.
+type ViewPipeline struct {
+	Window        time.Duration
+	FlushInterval time.Duration
+	MaxBuffered   int // buffered events that trigger an early flush
+	MaxViewers    int
+
+	nfts         *repository.NFTRepository
+	interactions *repository.InteractionRepository
+	wake         chan struct{}
+
+	flushMu sync.Mutex // serialises flushes
+
+	mu      sync.Mutex
+	seen    map[viewKey]time.Time
+	order   []seenView // seen oldest first, plus stale entries of keys seen again
+	counts  map[uuid.UUID]int
+	wallets map[viewKey]repository.WalletView
+}
+
+// seenView is when a viewer was remembered
+type seenView struct {
+	key viewKey
+	at  time.Time
+}
+
+// viewKey identifies a viewer of an NFT
+type viewKey struct {
+	nftID  uuid.UUID
+	viewer string
+}
+
+// NewViewPipeline creates a view pipeline configured from the VIEW_*
+// environment
+func NewViewPipeline() *ViewPipeline {
+	return &ViewPipeline{
+		Window:        config.Duration("VIEW_DEDUPE_WINDOW", 30*time.Minute),
+		FlushInterval: config.Duration("VIEW_FLUSH_INTERVAL", 10*time.Second),
+		MaxBuffered:   config.Int("VIEW_MAX_BUFFERED", 1000),
+		MaxViewers:    config.Int("VIEW_MAX_VIEWERS", 100000),
+		nfts:          repository.NewNFTRepository(),
+		interactions:  repository.NewInteractionRepository(),
+		wake:          make(chan struct{}, 1),
+		seen:          make(map[viewKey]time.Time),
+		counts:        make(map[uuid.UUID]int),
+		wallets:       make(map[viewKey]repository.WalletView),
+	}
+}
+
+// Start flushes every FlushInterval, or sooner once MaxBuffered events are
+// waiting, until ctx is cancelled, then flushes what is left
+func (p *ViewPipeline) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				if err := p.Flush(); err != nil {
+					log.Printf("view pipeline: final flush: %v", err)
+				}
+				return
+			case <-ticker.C:
+			case <-p.wake:
+			}
+
+			if err := p.Flush(); err != nil {
+				log.Printf("view pipeline: %v", err)
+			}
+		}
+	}()
+}
+
+// Record buffers a view of an NFT by viewer. wallet is the viewer's wallet
+// when they are signed in, else empty. It reports whether the view counts,
+// i.e. the viewer did not view the NFT within the window.
+func (p *ViewPipeline) Record(nftID uuid.UUID, viewer, wallet string) bool {
+	key := viewKey{nftID: nftID, viewer: viewer}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Taken under mu so that order stays sorted
+	now := time.Now()
+	if last, ok := p.seen[key]; ok && now.Sub(last) < p.Window {
+		return false
+	}
+	p.seen[key] = now
+	p.order = append(p.order, seenView{key: key, at: now})
+	p.prune(now)
+	p.counts[nftID]++
+	if wallet != "" {
+		p.wallets[viewKey{nftID: nftID, viewer: wallet}] = repository.WalletView{
+			Wallet:   wallet,
+			NFTID:    nftID,
+			ViewedAt: now,
+		}
+	}
+
+	if len(p.counts)+len(p.wallets) >= p.MaxBuffered {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// Flush writes the buffered views to nfts.views and user_interactions in one
+// transaction. On failure the views are buffered again for the next flush.
+func (p *ViewPipeline) Flush() error {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+
+	p.mu.Lock()
+	counts, wallets := p.counts, p.wallets
+	p.counts = make(map[uuid.UUID]int)
+	p.wallets = make(map[viewKey]repository.WalletView)
+	p.prune(time.Now())
+	p.mu.Unlock()
+
+	if len(counts) == 0 && len(wallets) == 0 {
+		return nil
+	}
+
+	views := make([]repository.WalletView, 0, len(wallets))
+	for _, view := range wallets {
+		views = append(views, view)
+	}
+
+	err := database.Transaction(func(tx *sqlx.Tx) error {
+		if len(counts) > 0 {
+			if err := p.nfts.WithTx(tx).AddViews(counts); err != nil {
+				return err
+			}
+		}
+		if len(views) > 0 {
+			return p.interactions.WithTx(tx).RecordViews(views)
+		}
+		return nil
+	})
+	if err != nil {
+		p.requeue(counts, wallets)
+	}
+	return err
+}
+
+// prune forgets viewers whose window passed, and the longest-remembered ones
+// while there are more than MaxViewers. Call it with mu held.
+func (p *ViewPipeline) prune(now time.Time) {
+	for len(p.order) > 0 {
+		oldest := p.order[0]
+		if now.Sub(oldest.at) < p.Window && (p.MaxViewers <= 0 || len(p.seen) <= p.MaxViewers) {
+			return
+		}
+		p.order[0] = seenView{}
+		p.order = p.order[1:]
+		if last, ok := p.seen[oldest.key]; ok && last.Equal(oldest.at) {
+			delete(p.seen, oldest.key)
+		}
+	}
+}
+
+// requeue merges views that failed to flush back into the buffers
+func (p *ViewPipeline) requeue(counts map[uuid.UUID]int, wallets map[viewKey]repository.WalletView) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, n := range counts {
+		p.counts[id] += n
+	}
+	for key, view := range wallets {
+		if newer, ok := p.wallets[key]; !ok || newer.ViewedAt.Before(view.ViewedAt) {
+			p.wallets[key] = view
+		}
+	}
+}
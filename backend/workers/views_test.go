@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestViewPipelineDedupes(t *testing.T) {
+	p := NewViewPipeline()
+	p.Window = 20 * time.Millisecond
+	nftID, other := uuid.New(), uuid.New()
+
+	if !p.Record(nftID, "ip:198.51.100.1", "") {
+		t.Error("first view not counted")
+	}
+	if p.Record(nftID, "ip:198.51.100.1", "") {
+		t.Error("repeat view within the window counted")
+	}
+	if !p.Record(other, "ip:198.51.100.1", "") || !p.Record(nftID, "ip:198.51.100.2", "") {
+		t.Error("view of another NFT or by another viewer not counted")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !p.Record(nftID, "ip:198.51.100.1", "") {
+		t.Error("view after the window not counted")
+	}
+	// Only the viewer remembered after the window is left
+	if len(p.seen) != 1 || len(p.order) != 1 {
+		t.Errorf("remembering %d viewers in %d entries, want 1", len(p.seen), len(p.order))
+	}
+	if p.counts[nftID] != 3 || p.counts[other] != 1 {
+		t.Errorf("counts = %v", p.counts)
+	}
+}
+
+func TestViewPipelineMaxViewers(t *testing.T) {
+	p := NewViewPipeline()
+	p.Window = time.Hour
+	p.MaxViewers = 3
+	nftID := uuid.New()
+
+	for i := 0; i < 10; i++ {
+		p.Record(nftID, fmt.Sprintf("ip:198.51.100.%d", i), "")
+	}
+	if len(p.seen) != 3 || len(p.order) != 3 {
+		t.Fatalf("remembering %d viewers in %d entries, want 3", len(p.seen), len(p.order))
+	}
+
+	// The most recent viewers are still deduped; the oldest were forgotten
+	if p.Record(nftID, "ip:198.51.100.9", "") {
+		t.Error("recent viewer counted again")
+	}
+	if !p.Record(nftID, "ip:198.51.100.0", "") {
+		t.Error("forgotten viewer not counted")
+	}
+}
@@ -65,7 +65,7 @@ export default function Home() {
             <ol className="space-y-3 text-slate-300">
               <li className="flex items-start gap-3">
                 <span className="w-6 h-6 rounded-full bg-blue-500/20 text-blue-400 text-sm flex items-center justify-center mt-0.5 font-semibold">1</span>
-                <span>Connect your wallet using the button above</span>
+                <span>Connect your wallet using the button above and sign the sign-in message when asked</span>
               </li>
               <li className="flex items-start gap-3">
                 <span className="w-6 h-6 rounded-full bg-green-500/20 text-green-400 text-sm flex items-center justify-center mt-0.5 font-semibold">2</span>
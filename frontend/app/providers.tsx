@@ -11,6 +11,7 @@ import {
 } from "@rainbow-me/rainbowkit";
 import "@rainbow-me/rainbowkit/styles.css";
 import { ThemeProvider } from "@/contexts/ThemeContext";
+import { AuthProvider } from "@/contexts/AuthContext";
 
 const APP_NAME = process.env.NEXT_PUBLIC_APP_NAME || "NFTGenie";
 
@@ -44,7 +45,7 @@ export default function Providers({ children }: { children: ReactNode }) {
               fontStack: "system"
             })}
           >
-            {children}
+            <AuthProvider>{children}</AuthProvider>
           </RainbowKitProvider>
         </QueryClientProvider>
       </WagmiProvider>
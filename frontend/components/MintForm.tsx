@@ -1,19 +1,63 @@
 "use client";
 
-import { useState } from "react";
+import { useEffect, useRef, useState } from "react";
 import { useAccount } from "wagmi";
 import Logo from "@/components/Logo";
 import { useNotifications } from "@/contexts/NotificationContext";
+import { errorMessage, useAuth } from "@/contexts/AuthContext";
+
+const POLL_INTERVAL_MS = 2000;
+// Stop polling after this long; the worker keeps retrying in the background
+const MAX_POLL_MS = 2 * 60_000;
+
+// sleep waits for ms, or rejects as soon as signal aborts
+function sleep(ms: number, signal: AbortSignal) {
+  return new Promise<void>((resolve, reject) => {
+    const timer = setTimeout(resolve, ms);
+    signal.addEventListener("abort", () => {
+      clearTimeout(timer);
+      reject(signal.reason);
+    }, { once: true });
+  });
+}
+
+// waitForMint polls the mint job until the provider has accepted it and it
+// has a transaction hash. Block confirmation can take minutes, so it does not
+// wait for that. It returns the last job seen, which is still queued if
+// MAX_POLL_MS passed first.
+async function waitForMint(jobId: string, signal: AbortSignal) {
+  const deadline = Date.now() + MAX_POLL_MS;
+  for (;;) {
+    const res = await fetch(`/backend/api/mints/${jobId}`, { signal });
+    const job = await res.json();
+    if (!res.ok) {
+      throw new Error(errorMessage(job, "Failed to check mint status"));
+    }
+    if (job.status === "failed") {
+      throw new Error(job.error || "Mint failed");
+    }
+    if (job.transaction_hash || job.status === "confirmed" || Date.now() >= deadline) {
+      return job;
+    }
+    await sleep(POLL_INTERVAL_MS, signal);
+  }
+}
 
 export default function MintForm() {
   const { address, isConnected } = useAccount();
   const { addNotification } = useNotifications();
+  const { authFetch } = useAuth();
   const [name, setName] = useState("");
   const [description, setDescription] = useState("");
   const [imageUrl, setImageUrl] = useState("");
   const [loading, setLoading] = useState(false);
   const [tx, setTx] = useState<string | null>(null);
+  const [queuedJob, setQueuedJob] = useState<string | null>(null);
   const [error, setError] = useState<string | null>(null);
+  const polling = useRef<AbortController | null>(null);
+
+  // Leaving the page stops polling; the mint itself carries on server-side
+  useEffect(() => () => polling.current?.abort(), []);
 
   async function onMint(e: React.FormEvent) {
     e.preventDefault();
@@ -24,6 +68,10 @@ export default function MintForm() {
     setLoading(true);
     setError(null);
     setTx(null);
+    setQueuedJob(null);
+    polling.current?.abort();
+    const controller = new AbortController();
+    polling.current = controller;
 
     // Add "minting started" notification
     addNotification({
@@ -33,7 +81,8 @@ export default function MintForm() {
     });
 
     try {
-      const res = await fetch(`/backend/api/nfts/mint`, {
+      // Signs the wallet in first if it has no session yet
+      const res = await authFetch(`/api/nfts/mint`, {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({
@@ -46,17 +95,30 @@ export default function MintForm() {
       });
       const data = await res.json();
       if (!res.ok || data.success === false) {
-        throw new Error(data.message || "Mint failed");
+        throw new Error(errorMessage(data, "Mint failed"));
       }
-      setTx(data.transaction_hash || "");
+      const job = await waitForMint(data.job_id, controller.signal);
+      if (!job.transaction_hash && job.status !== "confirmed") {
+        setQueuedJob(job.id);
+        addNotification({
+          type: 'info',
+          title: 'NFT Mint Still Queued',
+          message: `"${name}" is taking longer than usual; it will be minted in the background.`
+        });
+        return;
+      }
+      setTx(job.transaction_hash || "");
       
       // Add success notification
       addNotification({
         type: 'success',
         title: 'NFT Minted Successfully! 🎉',
-        message: `"${name}" has been created and is now live on the blockchain.`
+        message: `"${name}" has been submitted to the blockchain and will show up once the transaction confirms.`
       });
     } catch (err: any) {
+      if (controller.signal.aborted) {
+        return;
+      }
       setError(err.message);
       
       // Add error notification
@@ -66,7 +128,9 @@ export default function MintForm() {
         message: `Failed to mint "${name}": ${err.message}`
       });
     } finally {
-      setLoading(false);
+      if (!controller.signal.aborted) {
+        setLoading(false);
+      }
     }
   }
 
@@ -139,13 +203,31 @@ export default function MintForm() {
           )}
         </button>
 
+        {loading && (
+          <p className="text-xs text-center text-slate-400">
+            Minting continues in the background; you can leave this page.
+          </p>
+        )}
+
+        {queuedJob && (
+          <div className="glass rounded-xl p-4 border border-amber-500/30 bg-amber-500/10">
+            <div className="flex items-center gap-2 mb-2">
+              <span className="text-amber-400">⏳</span>
+              <span className="font-semibold text-amber-400">Still Queued</span>
+            </div>
+            <p className="text-sm text-slate-300">
+              Your mint (job {queuedJob}) is queued and will be retried in the background.
+            </p>
+          </div>
+        )}
+
         {tx && (
           <div className="glass rounded-xl p-4 border border-green-500/30 bg-green-500/10">
             <div className="flex items-center gap-2 mb-2">
               <span className="text-green-400">✓</span>
               <span className="font-semibold text-green-400">Successfully Minted!</span>
             </div>
-            <p className="text-sm text-slate-300 mb-3">Your NFT has been created on Polygon Amoy</p>
+            <p className="text-sm text-slate-300 mb-3">Your NFT was submitted to Polygon Amoy and appears once the transaction confirms</p>
             <a 
               className="inline-flex items-center gap-2 px-4 py-2 rounded-lg bg-green-500/20 hover:bg-green-500/30 text-green-400 text-sm font-medium transition-colors" 
               target="_blank" 
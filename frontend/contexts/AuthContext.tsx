@@ -0,0 +1,178 @@
+"use client";
+
+import { createContext, useCallback, useContext, useEffect, useRef, useState, ReactNode } from "react";
+import { useAccount, useSignMessage } from "wagmi";
+
+interface Session {
+  address: string;
+  accessToken: string;
+  refreshToken: string;
+  expiresAt: number;
+}
+
+interface TokenPair {
+  access_token: string;
+  refresh_token: string;
+  expires_in: number;
+}
+
+interface AuthContextType {
+  isSignedIn: boolean;
+  signIn: () => Promise<void>;
+  signOut: () => void;
+  authFetch: (path: string, init?: RequestInit) => Promise<Response>;
+}
+
+const STORAGE_KEY = "nftgenie.session";
+
+// Refresh access tokens this long before they expire
+const REFRESH_MARGIN_MS = 60_000;
+
+const AuthContext = createContext<AuthContextType | undefined>(undefined);
+
+// errorMessage reads the message out of a backend error body: middleware
+// rejections are {"error": {...}}, handler errors gofr's {"errors": [...]}
+export function errorMessage(data: any, fallback: string): string {
+  return data?.error?.message || data?.errors?.[0]?.reason || data?.message || fallback;
+}
+
+function loadSession(): Session | null {
+  try {
+    const stored = localStorage.getItem(STORAGE_KEY);
+    return stored ? (JSON.parse(stored) as Session) : null;
+  } catch {
+    return null;
+  }
+}
+
+function storeSession(session: Session | null) {
+  if (session) {
+    localStorage.setItem(STORAGE_KEY, JSON.stringify(session));
+  } else {
+    localStorage.removeItem(STORAGE_KEY);
+  }
+}
+
+function toSession(address: string, tokens: TokenPair): Session {
+  return {
+    address: address.toLowerCase(),
+    accessToken: tokens.access_token,
+    refreshToken: tokens.refresh_token,
+    expiresAt: Date.now() + tokens.expires_in * 1000,
+  };
+}
+
+async function postJSON(path: string, body?: unknown) {
+  const res = await fetch(`/backend${path}`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) {
+    throw new Error(errorMessage(data, `Request to ${path} failed`));
+  }
+  return data;
+}
+
+// AuthProvider signs the connected wallet in with Sign-In-With-Ethereum and
+// keeps its session tokens, so mutating calls can send a bearer token
+export function AuthProvider({ children }: { children: ReactNode }) {
+  const { address, status } = useAccount();
+  const { signMessageAsync } = useSignMessage();
+  const sessionRef = useRef<Session | null>(null);
+  const [isSignedIn, setIsSignedIn] = useState(false);
+
+  const setSession = useCallback((session: Session | null) => {
+    sessionRef.current = session;
+    storeSession(session);
+    setIsSignedIn(session !== null);
+  }, []);
+
+  // A stored session only counts for the wallet that is connected now; wait
+  // for wagmi to reconnect on page load before deciding
+  useEffect(() => {
+    if (status === "connecting" || status === "reconnecting") {
+      return;
+    }
+    const stored = loadSession();
+    if (address && stored && stored.address === address.toLowerCase()) {
+      sessionRef.current = stored;
+      setIsSignedIn(true);
+    } else {
+      setSession(null);
+    }
+  }, [address, status, setSession]);
+
+  const signIn = useCallback(async () => {
+    if (!address) {
+      throw new Error("Connect your wallet first");
+    }
+
+    const challenge = await postJSON(`/api/users/${address}/nonce`);
+    const message =
+      `${challenge.domain} wants you to sign in with your Ethereum account:\n${address}\n\n` +
+      `Sign in to NFTGenie\n\n` +
+      `URI: ${window.location.origin}\nVersion: 1\nChain ID: ${challenge.chain_id}\n` +
+      `Nonce: ${challenge.nonce}\nIssued At: ${challenge.issued_at}`;
+    const signature = await signMessageAsync({ message });
+
+    const data = await postJSON("/api/users/connect", { address, message, signature });
+    setSession(toSession(address, data.tokens));
+  }, [address, signMessageAsync, setSession]);
+
+  const signOut = useCallback(() => setSession(null), [setSession]);
+
+  // accessToken returns a live access token, refreshing or signing in again
+  // as needed
+  const accessToken = useCallback(async () => {
+    const session = sessionRef.current;
+    if (session && session.expiresAt - REFRESH_MARGIN_MS > Date.now()) {
+      return session.accessToken;
+    }
+    if (session) {
+      try {
+        const data = await postJSON("/api/auth/refresh", { refresh_token: session.refreshToken });
+        setSession(toSession(session.address, data.tokens));
+        return sessionRef.current!.accessToken;
+      } catch {
+        setSession(null);
+      }
+    }
+    await signIn();
+    return sessionRef.current!.accessToken;
+  }, [signIn, setSession]);
+
+  const authFetch = useCallback(
+    async (path: string, init: RequestInit = {}) => {
+      const send = async (token: string) => {
+        const headers = new Headers(init.headers);
+        headers.set("Authorization", `Bearer ${token}`);
+        return fetch(`/backend${path}`, { ...init, headers });
+      };
+
+      const res = await send(await accessToken());
+      if (res.status !== 401) {
+        return res;
+      }
+      // The session was revoked or the secret rotated; sign in once more
+      setSession(null);
+      return send(await accessToken());
+    },
+    [accessToken, setSession]
+  );
+
+  return (
+    <AuthContext.Provider value={{ isSignedIn, signIn, signOut, authFetch }}>
+      {children}
+    </AuthContext.Provider>
+  );
+}
+
+export function useAuth() {
+  const context = useContext(AuthContext);
+  if (context === undefined) {
+    throw new Error("useAuth must be used within an AuthProvider");
+  }
+  return context;
+}
@@ -22,7 +22,9 @@ if ($LASTEXITCODE -eq 0) {
 
 # Run schema
 Write-Host "Running database schema..." -ForegroundColor Yellow
-psql -U postgres -h localhost -d nftgenie -f "backend/database/schema.sql"
+Push-Location backend
+go run main.go migrate up
+Pop-Location
 
 if ($LASTEXITCODE -eq 0) {
     Write-Host "Database schema applied successfully!" -ForegroundColor Green